@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
-	"syscall"
 	"time"
 	config "vServer/Backend/config"
 	tools "vServer/Backend/tools"
 )
 
-var mysqlProcess *exec.Cmd
+var mysqlProcess *tools.ManagedProcess
+
+// Сколько ждать завершения mysqld после SIGTERM
+const mysqlStopTimeout = 15 * time.Second
+
 var mysql_status bool = false
 var mysql_secure bool = false
 
@@ -34,7 +37,7 @@ func AbsPathMySQL() {
 
 	var err error
 
-	mysqldPath, err = tools.AbsPath(filepath.Join("WebServer/soft/MySQL/bin", "mysqld.exe"))
+	mysqldPath, err = tools.AbsPath(filepath.Join("WebServer/soft/MySQL/bin", tools.ExeName("mysqld")))
 	tools.CheckError(err)
 
 	configPath, err = tools.AbsPath("WebServer/soft/MySQL/my.ini")
@@ -88,11 +91,12 @@ func config_patch(secures bool) (string, []string, string) {
 // StartMySQLServer запускает MySQL сервер
 func StartMySQLServer(secure bool) {
 
-	mysql_port = config.ConfigData.Soft_Settings.Mysql_port
-	mysql_ip = config.ConfigData.Soft_Settings.Mysql_host
+	settings := config.Current().Soft_Settings
+	mysql_port = settings.Mysql_port
+	mysql_ip = settings.Mysql_host
 
 	if mysql_status {
-		tools.Logs_file(1, "MySQL", "Сервер MySQL уже запущен", "logs_mysql.log", false)
+		mysqlLog.Warn("Сервер MySQL уже запущен")
 		return
 	}
 
@@ -102,17 +106,22 @@ func StartMySQLServer(secure bool) {
 
 	// Выбор сообщения
 	if secure {
-		tools.Logs_file(0, "MySQL", "Запуск сервера MySQL в режиме безопасности", "logs_mysql.log", false)
+		mysqlLog.Info("Запуск сервера MySQL в режиме безопасности")
 	} else {
-		tools.Logs_file(0, "MySQL", "Запуск сервера MySQL в обычном режиме", "logs_mysql.log", false)
+		mysqlLog.Info("Запуск сервера MySQL в обычном режиме")
 	}
 
 	// Общая логика запуска
-	mysqlProcess = exec.Command(mysqldPath, args...)
-	mysqlProcess.Dir = binDirAbs
-	tools.Logs_console(mysqlProcess, console_mysql)
+	cmd := exec.Command(mysqldPath, args...)
+	cmd.Dir = binDirAbs
+	tools.Logs_console(cmd, console_mysql)
+	if cmd.Process == nil {
+		mysqlLog.Console().Error("Не удалось запустить MySQL", "path", mysqldPath)
+		return
+	}
+	mysqlProcess = tools.WatchProcess(cmd)
 
-	tools.Logs_file(0, "MySQL", fmt.Sprintf("Сервер MySQL запущен на %s:%d", mysql_ip, mysql_port), "logs_mysql.log", false)
+	mysqlLog.Info("Сервер MySQL запущен", "host", mysql_ip, "port", mysql_port)
 
 	mysql_status = true
 
@@ -125,24 +134,15 @@ func StopMySQLServer() {
 		return // Уже остановлен
 	}
 
-	// Сначала пробуем завершить процесс корректно
-	if mysqlProcess != nil && mysqlProcess.Process != nil {
-		mysqlProcess.Process.Kill()
+	// Останавливаем только запущенный нами mysqld: чужие серверы MySQL на машине не трогаем
+	if mysqlProcess != nil {
+		if mysqlProcess.Stop(mysqlStopTimeout) {
+			mysqlLog.Warn("MySQL не завершился вовремя, процесс остановлен принудительно", "timeout", mysqlStopTimeout)
+		}
 		mysqlProcess = nil
 	}
 
-	// Дополнительно убиваем все mysqld.exe процессы
-	cmd := exec.Command("taskkill", "/F", "/IM", "mysqld.exe")
-
-	// Скрываем окно taskkill
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow:    true,
-		CreationFlags: 0x08000000,
-	}
-
-	cmd.Run()
-
-	tools.Logs_file(0, "MySQL", "Сервер MySQL остановлен", "logs_mysql.log", false)
+	mysqlLog.Info("Сервер MySQL остановлен")
 	mysql_status = false
 
 }
@@ -158,7 +158,7 @@ func ResetPasswordMySQL() {
 	time.Sleep(2 * time.Second)
 	query := "FLUSH PRIVILEGES; ALTER USER 'root'@'%' IDENTIFIED BY '" + NewPasswordMySQL + "';"
 	СheckMySQLPassword(query)
-	tools.Logs_file(0, "MySQL", "Новый пароль: "+NewPasswordMySQL, "logs_mysql.log", true)
+	mysqlLog.Console().Info("Пароль root сброшен", "password", NewPasswordMySQL)
 	println()
 	StopMySQLServer()
 	StartMySQLServer(false)
@@ -173,16 +173,16 @@ func СheckMySQLPassword(query string) {
 	if mysql_secure {
 
 		// В безопасном режиме подключаемся без пароля
-		cmd := exec.Command(filepath.Join(binPathAbs, "mysql.exe"), "-u", "root", "-pRoot", "-e", query)
+		cmd := exec.Command(filepath.Join(binPathAbs, tools.ExeName("mysql")), "-u", "root", "-pRoot", "-e", query)
 		cmd.Dir = binPathAbs
 
 		// Захватываем вывод для логирования
 		err := tools.Logs_console(cmd, false)
 
 		if err != nil {
-			tools.Logs_file(1, "MySQL", "Вывод MySQL (stdout/stderr):", "logs_mysql.log", true)
+			mysqlLog.Console().Error("Ошибка выполнения команды MySQL", "error", err)
 		} else {
-			tools.Logs_file(0, "MySQL", "Команда выполнена успешно", "logs_mysql.log", true)
+			mysqlLog.Console().Info("Команда MySQL выполнена успешно")
 		}
 
 	}
@@ -0,0 +1,214 @@
+package webserver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	tools "vServer/Backend/tools"
+)
+
+// Файлы access-лога (в WebServer/tools/logs)
+const (
+	accessLogCombined = "access.log"      // Apache combined (GoAccess: --log-format=COMBINED)
+	accessLogJSON     = "access_json.log" // JSON lines
+)
+
+// Клиент закрыл соединение, не дождавшись ответа (как в nginx)
+const statusClientClosedRequest = 499
+
+// accessLogWriter оборачивает ResponseWriter и запоминает данные для access-лога
+type accessLogWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	upstream string // Адрес PHP FastCGI или бэкенда прокси
+	site     string // Сайт или домен прокси, обработавший запрос
+	kind     string // site или proxy ("" - домен не найден)
+	format   string // Формат лога сайта/прокси
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush нужен прокси для потоковых ответов (SSE)
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack нужен для Upgrade-соединений
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack не поддерживается")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap для http.ResponseController
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// accessLogHandler пишет строку access-лога после обработки каждого запроса
+func accessLogHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &accessLogWriter{ResponseWriter: w}
+		ensureRequestID(recorder, r)
+
+		// Обрыв передачи ответа (http.ErrAbortHandler от прокси) - запрос всё равно попадает в лог
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				finishAccessLog(recorder, r, start)
+				panic(recovered)
+			}
+		}()
+
+		next(recorder, r)
+		finishAccessLog(recorder, r, start)
+	}
+}
+
+func finishAccessLog(recorder *accessLogWriter, r *http.Request, start time.Time) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	observeRequest(recorder, time.Since(start))
+	writeAccessLog(recorder, r, start)
+}
+
+// setAccessLog отмечает, какой сайт/прокси обработал запрос и в каком формате его логировать
+func setAccessLog(w http.ResponseWriter, kind string, site string, format string) {
+	if recorder, ok := w.(*accessLogWriter); ok {
+		recorder.kind = kind
+		recorder.site = site
+		recorder.format = format
+	}
+}
+
+// setUpstream запоминает адрес, куда был передан запрос (PHP или бэкенд прокси)
+func setUpstream(w http.ResponseWriter, upstream string) {
+	if recorder, ok := w.(*accessLogWriter); ok {
+		recorder.upstream = upstream
+	}
+}
+
+// setAccessStatus записывает статус в access-лог и метрики, когда ответ клиенту не отправляется
+func setAccessStatus(w http.ResponseWriter, code int) {
+	if recorder, ok := w.(*accessLogWriter); ok && recorder.status == 0 {
+		recorder.status = code
+	}
+}
+
+// addAccessLogBytes учитывает байты, переданные клиенту в обход ResponseWriter (upgrade-соединения)
+func addAccessLogBytes(w http.ResponseWriter, n int64) {
+	if recorder, ok := w.(*accessLogWriter); ok {
+		recorder.bytes += n
+	}
+}
+
+// writeAccessLog форматирует запись в combined или JSON
+func writeAccessLog(w *accessLogWriter, r *http.Request, start time.Time) {
+	duration := time.Since(start)
+
+	switch w.format {
+	case "off":
+		return
+
+	case "json":
+		entry := map[string]interface{}{
+			"time":        start.Format(time.RFC3339Nano),
+			"request_id":  r.Header.Get(requestIDHeader),
+			"remote_addr": clientIP(r),
+			"host":        r.Host,
+			"site":        w.site,
+			"method":      r.Method,
+			"uri":         r.RequestURI,
+			"proto":       r.Proto,
+			"status":      w.status,
+			"bytes":       w.bytes,
+			"duration_ms": float64(duration.Microseconds()) / 1000,
+			"referer":     r.Referer(),
+			"user_agent":  r.UserAgent(),
+			"tls":         tlsVersionName(r.TLS),
+			"upstream":    w.upstream,
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		tools.Logs_line(accessLogJSON, string(line))
+
+	default:
+		// Apache combined + время обработки (сек), версия TLS и upstream
+		size := "-"
+		if w.bytes > 0 {
+			size = strconv.FormatInt(w.bytes, 10)
+		}
+		line := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s "%s" "%s" %.3f %s %s`,
+			clientIP(r),
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method, escapeLogField(r.RequestURI), r.Proto,
+			w.status, size,
+			escapeLogField(orDash(r.Referer())), escapeLogField(orDash(r.UserAgent())),
+			duration.Seconds(),
+			orDash(tlsVersionName(r.TLS)),
+			orDash(w.upstream),
+		)
+		tools.Logs_line(accessLogCombined, line)
+	}
+}
+
+// clientIP возвращает IP клиента без порта
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tlsVersionName возвращает версию TLS соединения ("" для HTTP)
+func tlsVersionName(state *tls.ConnectionState) string {
+	if state == nil {
+		return ""
+	}
+	return tls.VersionName(state.Version)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// escapeLogField экранирует кавычки и управляющие символы, чтобы строку лога нельзя было подделать
+func escapeLogField(value string) string {
+	quoted := strconv.Quote(value)
+	return quoted[1 : len(quoted)-1]
+}
@@ -27,6 +27,8 @@ var (
 	// Let's Encrypt URLs
 	LetsEncryptProduction = "https://acme-v02.api.letsencrypt.org/directory"
 	LetsEncryptStaging    = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+	acmeLog = tools.NewLogger("acme", "logs_acme.log")
 )
 
 // Init инициализирует ACME менеджер
@@ -55,7 +57,7 @@ func Init(production bool) error {
 	if production {
 		mode = "PRODUCTION"
 	}
-	tools.Logs_file(0, "ACME", "✅ ACME менеджер инициализирован ("+mode+")", "logs_acme.log", true)
+	acmeLog.Console().Info("ACME менеджер инициализирован", "mode", mode)
 	
 	return nil
 }
@@ -65,7 +67,7 @@ func CollectDomainsForSSL() []string {
 	domains := make(map[string]bool)
 	
 	// Из Site_www
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
 		if site.AutoCreateSSL && site.Status == "active" {
 			if isValidDomain(site.Host) {
 				domains[site.Host] = true
@@ -79,11 +81,16 @@ func CollectDomainsForSSL() []string {
 	}
 	
 	// Из Proxy_Service
-	for _, proxy := range config.ConfigData.Proxy_Service {
+	for _, proxy := range config.Current().Proxy_Service {
 		if proxy.AutoCreateSSL && proxy.Enable {
 			if isValidDomain(proxy.ExternalDomain) {
 				domains[proxy.ExternalDomain] = true
 			}
+			for _, alias := range proxy.Alias {
+				if isValidDomain(alias) && !strings.Contains(alias, "*") && !strings.HasPrefix(alias, "~") {
+					domains[alias] = true
+				}
+			}
 		}
 	}
 	
@@ -106,7 +113,9 @@ func ObtainCertificate(domain string) ObtainResult {
 		}
 	}
 	
-	return DefaultManager.obtainCertificate(domain)
+	result := DefaultManager.obtainCertificate(domain)
+	countRenewal(result)
+	return result
 }
 
 // ObtainAllCertificates получает сертификаты для всех доменов с AutoCreateSSL
@@ -140,7 +149,7 @@ func CheckAndRenewCertificates() []ObtainResult {
 		
 		// Обновляем если до истечения менее 30 дней
 		if daysLeft >= 0 && daysLeft < 30 {
-			tools.Logs_file(0, "ACME", "🔄 Обновление сертификата для "+domain+" (осталось "+fmt.Sprintf("%d", daysLeft)+" дней)", "logs_acme.log", true)
+			acmeLog.Console().Info("Обновление сертификата", "domain", domain, "days_left", daysLeft)
 			result := ObtainCertificate(domain)
 			results = append(results, result)
 			time.Sleep(time.Second * 2)
@@ -157,14 +166,14 @@ func StartBackgroundRenewal(interval time.Duration) {
 		defer ticker.Stop()
 		
 		for range ticker.C {
-			tools.Logs_file(0, "ACME", "🔍 Проверка сертификатов...", "logs_acme.log", false)
+			acmeLog.Debug("Проверка сертификатов")
 			results := CheckAndRenewCertificates()
 			
 			for _, r := range results {
 				if r.Success {
-					tools.Logs_file(0, "ACME", "✅ Обновлён: "+r.Domain, "logs_acme.log", true)
+					acmeLog.Console().Info("Сертификат обновлён", "domain", r.Domain)
 				} else {
-					tools.Logs_file(1, "ACME", "❌ Ошибка обновления "+r.Domain+": "+r.Error, "logs_acme.log", true)
+					acmeLog.Console().Error("Ошибка обновления сертификата", "domain", r.Domain, "error", r.Error)
 				}
 			}
 			
@@ -178,7 +187,7 @@ func StartBackgroundRenewal(interval time.Duration) {
 
 // obtainCertificate внутренний метод получения сертификата
 func (m *Manager) obtainCertificate(domain string) ObtainResult {
-	tools.Logs_file(0, "ACME", "🔐 Получение сертификата для: "+domain, "logs_acme.log", true)
+	acmeLog.Console().Info("Получение сертификата", "domain", domain)
 	
 	// Определяем ACME сервер
 	acmeURL := LetsEncryptStaging
@@ -331,7 +340,7 @@ func (m *Manager) obtainCertificate(domain string) ObtainResult {
 		}
 	}
 	
-	tools.Logs_file(0, "ACME", "✅ Сертификат получен для: "+domain, "logs_acme.log", true)
+	acmeLog.Console().Info("Сертификат получен", "domain", domain)
 	
 	return ObtainResult{
 		Success: true,
@@ -390,7 +399,7 @@ func (m *Manager) loadOrCreateAccountKey() error {
 			key, err := x509.ParseECPrivateKey(block.Bytes)
 			if err == nil {
 				m.accountKey = key
-				tools.Logs_file(0, "ACME", "🔑 Account key загружен", "logs_acme.log", false)
+				acmeLog.Debug("Account key загружен", "path", keyPath)
 				return nil
 			}
 		}
@@ -417,7 +426,7 @@ func (m *Manager) loadOrCreateAccountKey() error {
 	pem.Encode(keyFile, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
 	
 	m.accountKey = key
-	tools.Logs_file(0, "ACME", "🔑 Новый account key создан", "logs_acme.log", true)
+	acmeLog.Console().Info("Создан новый account key", "path", keyPath)
 	
 	return nil
 }
@@ -540,7 +549,7 @@ func DeleteCertificate(domain string) error {
 		return fmt.Errorf("ошибка удаления сертификата: %w", err)
 	}
 	
-	tools.Logs_file(0, "ACME", "🗑️ Сертификат удалён для: "+domain, "logs_acme.log", true)
+	acmeLog.Console().Info("Сертификат удалён", "domain", domain)
 	return nil
 }
 
@@ -3,7 +3,6 @@ package acme
 import (
 	"net/http"
 	"strings"
-	tools "vServer/Backend/tools"
 )
 
 // HandleChallenge обрабатывает HTTP-01 ACME challenge
@@ -29,13 +28,13 @@ func (m *Manager) HandleChallenge(w http.ResponseWriter, r *http.Request) bool {
 	m.mu.RUnlock()
 	
 	if !exists {
-		tools.Logs_file(1, "ACME", "⚠️ Challenge не найден для token: "+token, "logs_acme.log", false)
+		acmeLog.Warn("Challenge не найден", "token", token)
 		http.Error(w, "Challenge not found", http.StatusNotFound)
 		return true
 	}
 	
 	// Отдаём KeyAuth для подтверждения владения доменом
-	tools.Logs_file(0, "ACME", "✅ Challenge ответ для домена: "+challenge.Domain, "logs_acme.log", true)
+	acmeLog.Console().Info("Отдан ответ на challenge", "domain", challenge.Domain)
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(challenge.KeyAuth))
 	
@@ -54,7 +53,7 @@ func (m *Manager) addChallenge(token, keyAuth, domain string) {
 		Created: getCurrentTimestamp(),
 	}
 	
-	tools.Logs_file(0, "ACME", "📝 Challenge добавлен для: "+domain, "logs_acme.log", false)
+	acmeLog.Debug("Challenge добавлен", "domain", domain)
 }
 
 // removeChallenge удаляет challenge из хранилища
@@ -63,7 +62,7 @@ func (m *Manager) removeChallenge(token string) {
 	defer m.mu.Unlock()
 	
 	if challenge, exists := m.challenges[token]; exists {
-		tools.Logs_file(0, "ACME", "🗑️ Challenge удалён для: "+challenge.Domain, "logs_acme.log", false)
+		acmeLog.Debug("Challenge удалён", "domain", challenge.Domain)
 		delete(m.challenges, token)
 	}
 }
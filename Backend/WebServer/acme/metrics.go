@@ -0,0 +1,34 @@
+package acme
+
+import (
+	"time"
+	"vServer/Backend/metrics"
+)
+
+// Метрики сертификатов и ACME
+var (
+	renewalsTotal = metrics.NewCounter("vserver_acme_renewals_total",
+		"Попытки получения и обновления сертификатов через ACME (result: success, error)", "domain", "result")
+	lastRenewal = metrics.NewGauge("vserver_acme_last_renewal_timestamp_seconds",
+		"Время последней попытки получения сертификата (unix)", "domain", "result")
+)
+
+func init() {
+	metrics.NewGaugeFunc("vserver_cert_days_left", "Дней до истечения сертификата (отрицательное - истёк)", []string{"domain"}, func() []metrics.Sample {
+		var samples []metrics.Sample
+		for _, cert := range GetAllCertsInfo() {
+			samples = append(samples, metrics.Sample{Labels: []string{cert.Domain}, Value: float64(cert.DaysLeft)})
+		}
+		return samples
+	})
+}
+
+// countRenewal учитывает результат получения сертификата
+func countRenewal(result ObtainResult) {
+	status := "error"
+	if result.Success {
+		status = "success"
+	}
+	renewalsTotal.Inc(result.Domain, status)
+	lastRenewal.Set(float64(time.Now().Unix()), result.Domain, status)
+}
@@ -0,0 +1,205 @@
+package webserver
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed templates/autoindex.tmpl
+var autoindexSource string
+
+var autoindexTemplate = template.Must(template.New("autoindex").Parse(autoindexSource))
+
+// Элемент листинга директории
+type autoindexEntry struct {
+	Name     string    `json:"name"`
+	IsDir    bool      `json:"is_dir"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	URL      string    `json:"url"`
+	SizeText string    `json:"-"`
+}
+
+// Ссылка навигационной цепочки
+type autoindexCrumb struct {
+	Name string
+	URL  string
+}
+
+// Проверяет, включён ли листинг для директории сайта
+// Правила autoindex задаются как path_access в vAccess: "/*", "/downloads/*", "/releases"
+func isAutoindexEnabled(host string, dirPath string) bool {
+	site, ok := findSite(host)
+	if !ok {
+		return false
+	}
+
+	trimmed := strings.TrimSuffix(dirPath, "/")
+	for _, rule := range site.Autoindex {
+		if matchPath(rule, dirPath) || matchPath(rule, trimmed) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveAutoindex отдаёт листинг директории в HTML или JSON (?format=json или Accept: application/json)
+// Скрытые файлы, vAccess.conf и запрещённые vAccess элементы в листинг не попадают
+func serveAutoindex(w http.ResponseWriter, r *http.Request, host string, dirPath string, fsPath string) {
+	files, err := os.ReadDir(fsPath)
+	if err != nil {
+		httpLog.Error("Ошибка чтения директории для листинга", "path", fsPath, "error", err)
+		serveErrorPage(w, r, http.StatusInternalServerError, host)
+		return
+	}
+
+	entries := make([]autoindexEntry, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasPrefix(name, ".") || isVAccessFile(name) {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		entryPath := dirPath + name
+		if info.IsDir() {
+			entryPath += "/"
+		}
+		if allowed, _ := checkSiteVAccess(entryPath, host, r, false); !allowed {
+			continue
+		}
+
+		entries = append(entries, autoindexEntry{
+			Name:     name,
+			IsDir:    info.IsDir(),
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			URL:      escapeURLPath(entryPath),
+			SizeText: formatSize(info.Size()),
+		})
+	}
+
+	sortKey := r.URL.Query().Get("sort")
+	desc := r.URL.Query().Get("order") == "desc"
+	sortAutoindex(entries, sortKey, desc)
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"host":    host,
+			"path":    dirPath,
+			"entries": entries,
+		})
+		return
+	}
+
+	// Ссылки сортировки: повторный клик по активной колонке меняет порядок
+	sortLinks := make(map[string]string)
+	for _, key := range []string{"name", "size", "mtime"} {
+		order := "asc"
+		if key == sortKey && !desc {
+			order = "desc"
+		}
+		sortLinks[key] = "?sort=" + key + "&order=" + order
+	}
+
+	parent := ""
+	if dirPath != "/" {
+		parent = escapeURLPath(dirPath[:strings.LastIndex(strings.TrimSuffix(dirPath, "/"), "/")+1])
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = autoindexTemplate.Execute(w, map[string]interface{}{
+		"Host":        host,
+		"Path":        dirPath,
+		"Breadcrumbs": breadcrumbs(host, dirPath),
+		"Parent":      parent,
+		"Entries":     entries,
+		"SortLinks":   sortLinks,
+	})
+	if err != nil {
+		httpLog.Error("Ошибка шаблона листинга", "error", err)
+	}
+}
+
+// Сортирует элементы: директории всегда первыми, затем по выбранному полю
+func sortAutoindex(entries []autoindexEntry, key string, desc bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+
+		// Для обратного порядка меняем элементы местами: !less нарушил бы порядок для равных
+		if desc {
+			a, b = b, a
+		}
+		switch key {
+		case "size":
+			return a.Size < b.Size
+		case "mtime":
+			return a.ModTime.Before(b.ModTime)
+		default:
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	})
+}
+
+// Навигационная цепочка: host / dir / subdir
+func breadcrumbs(host string, dirPath string) []autoindexCrumb {
+	crumbs := []autoindexCrumb{{Name: host, URL: "/"}}
+	current := "/"
+	for _, part := range strings.Split(strings.Trim(dirPath, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current += part + "/"
+		crumbs = append(crumbs, autoindexCrumb{Name: part, URL: escapeURLPath(current)})
+	}
+	return crumbs
+}
+
+// Экранирует каждый сегмент пути для использования в ссылке
+func escapeURLPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Клиент запросил JSON
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// Размер в читаемом виде
+func formatSize(size int64) string {
+	units := []string{"Б", "КБ", "МБ", "ГБ", "ТБ"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,137 @@
+package webserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	config "vServer/Backend/config"
+)
+
+// Стандартная страница ошибки (используется, если у сайта/прокси нет своей)
+var defaultErrorPage = "WebServer/tools/error_page/index.html"
+
+// Папка, от которой считаются относительные пути error_pages у прокси
+var proxyErrorPageDir = "WebServer/tools/error_page"
+
+// Заголовок с идентификатором запроса
+const requestIDHeader = "X-Request-ID"
+
+// ensureRequestID присваивает запросу идентификатор (или берёт пришедший от клиента)
+// и возвращает его в ответе, чтобы ошибку можно было найти в логах
+func ensureRequestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > 128 {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+		r.Header.Set(requestIDHeader, id)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+// Ищет сайт в конфигурации по host
+func findSite(host string) (config.Site_www, bool) {
+	for _, site := range config.Current().Site_www {
+		if site.Host == host {
+			return site, true
+		}
+	}
+	return config.Site_www{}, false
+}
+
+// serveErrorPage отдаёт страницу ошибки сайта с правильным статусом
+// Относительные пути error_pages считаются от корня документов сайта
+func serveErrorPage(w http.ResponseWriter, r *http.Request, code int, host string) {
+	var pages map[string]string
+	if site, ok := findSite(host); ok {
+		pages = site.Error_pages
+	}
+	writeErrorPage(w, r, code, host, pages, config.DocumentRoot(host))
+}
+
+// serveProxyErrorPage отдаёт страницу ошибки прокси с правильным статусом
+// Относительные пути error_pages считаются от WebServer/tools/error_page
+func serveProxyErrorPage(w http.ResponseWriter, r *http.Request, code int, proxy config.Proxy_Service) {
+	writeErrorPage(w, r, code, proxy.ExternalDomain, proxy.Error_pages, proxyErrorPageDir)
+}
+
+// writeErrorPage выбирает страницу по коду: точный код, класс (4xx/5xx), default, стандартная
+func writeErrorPage(w http.ResponseWriter, r *http.Request, code int, host string, pages map[string]string, baseDir string) {
+	page := lookupErrorPage(pages, code)
+
+	// Внешний адрес - редирект, как url_error в vAccess
+	if strings.HasPrefix(page, "http://") || strings.HasPrefix(page, "https://") {
+		http.Redirect(w, r, page, http.StatusFound)
+		return
+	}
+
+	var body []byte
+	if page != "" {
+		pagePath := page
+		if !filepath.IsAbs(pagePath) {
+			pagePath = filepath.Join(baseDir, page)
+		}
+
+		var err error
+		if body, err = os.ReadFile(pagePath); err != nil {
+			errorPageLog.Error("Страница ошибки не найдена", "code", code, "path", pagePath)
+			body = nil
+		}
+	}
+
+	if body == nil {
+		var err error
+		if body, err = os.ReadFile(defaultErrorPage); err != nil {
+			// Нет даже стандартной страницы - отдаём текст статуса
+			body = []byte(strconv.Itoa(code) + " " + http.StatusText(code))
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	header.Del("Content-Length")
+	w.WriteHeader(code)
+
+	if r.Method != http.MethodHead {
+		w.Write(renderErrorPage(body, code, host, r))
+	}
+}
+
+// lookupErrorPage возвращает путь/URL страницы для кода ("" - стандартная страница)
+func lookupErrorPage(pages map[string]string, code int) string {
+	if len(pages) == 0 {
+		return ""
+	}
+	codeStr := strconv.Itoa(code)
+	for _, key := range []string{codeStr, codeStr[:1] + "xx", "default"} {
+		if page := strings.TrimSpace(pages[key]); page != "" {
+			return page
+		}
+	}
+	return ""
+}
+
+// renderErrorPage подставляет переменные шаблона в страницу ошибки
+// Поддерживаются {{code}}, {{status}}, {{host}}, {{path}}, {{method}} и {{request_id}}
+func renderErrorPage(body []byte, code int, host string, r *http.Request) []byte {
+	if !strings.Contains(string(body), "{{") {
+		return body
+	}
+
+	replacer := strings.NewReplacer(
+		"{{code}}", strconv.Itoa(code),
+		"{{status}}", html.EscapeString(http.StatusText(code)),
+		"{{host}}", html.EscapeString(host),
+		"{{path}}", html.EscapeString(r.URL.Path),
+		"{{method}}", html.EscapeString(r.Method),
+		"{{request_id}}", html.EscapeString(r.Header.Get(requestIDHeader)),
+	)
+	return []byte(replacer.Replace(string(body)))
+}
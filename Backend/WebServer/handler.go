@@ -1,12 +1,12 @@
 package webserver
 
 import (
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
 	"vServer/Backend/config"
-	tools "vServer/Backend/tools"
 	"vServer/Backend/WebServer/acme"
 )
 
@@ -16,7 +16,7 @@ var (
 )
 
 func StartHandler() {
-	http.HandleFunc("/", handler)
+	http.HandleFunc("/", accessLogHandler(handler))
 	updateSiteStatusCache()
 }
 
@@ -25,7 +25,7 @@ func updateSiteStatusCache() {
 	defer statusMutex.Unlock()
 
 	siteStatusCache = make(map[string]bool)
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
 		siteStatusCache[site.Host] = site.Status == "active"
 	}
 }
@@ -86,46 +86,74 @@ func matchWildcardAlias(pattern, host string) bool {
 	return true
 }
 
-func Alias_Run(r *http.Request) (rhost string) {
+// Уровни совпадения домена: чем меньше, тем выше приоритет (общие для сайтов и прокси)
+const (
+	matchNone     = iota
+	matchHost     // site.Host или ExternalDomain
+	matchAlias    // Точный alias
+	matchWildcard // Wildcard alias
+	matchRegex    // Regex alias (только у прокси)
+)
+
+// requestHostname возвращает имя хоста запроса без порта
+func requestHostname(r *http.Request) string {
 	requestHost := r.Host
 
-	// Убираем порт если есть (например :80 или :443)
-	if colonIndex := strings.Index(requestHost, ":"); colonIndex != -1 {
-		requestHost = requestHost[:colonIndex]
+	// Убираем порт если есть (например :80 или :443, в том числе у IPv6 [::1]:80)
+	if h, _, err := net.SplitHostPort(requestHost); err == nil {
+		requestHost = strings.Trim(h, "[]")
 	}
+	return requestHost
+}
+
+func Alias_Run(r *http.Request) (rhost string) {
+	host, _ := matchSiteHost(r)
+	return host
+}
+
+// matchSiteHost ищет сайт для запроса и возвращает его host и уровень совпадения
+// Без совпадений возвращает хост запроса и matchNone
+func matchSiteHost(r *http.Request) (string, int) {
+	requestHost := requestHostname(r)
 
 	// Приоритет 1: Проверяем точное совпадение с site.Host
-	for _, site := range config.ConfigData.Site_www {
-		if site.Host == requestHost {
-			return site.Host
+	for _, site := range config.Current().Site_www {
+		if site.Host == requestHost && listenAllowed(site.Listen, r) {
+			return site.Host, matchHost
 		}
 	}
 
 	// Приоритет 2: Проверяем точные alias (без wildcard)
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
+		if !listenAllowed(site.Listen, r) {
+			continue
+		}
 		for _, alias := range site.Alias {
 			if !strings.Contains(alias, "*") && alias == requestHost {
-				return site.Host
+				return site.Host, matchAlias
 			}
 		}
 	}
 
 	// Приоритет 3: Проверяем wildcard alias
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
+		if !listenAllowed(site.Listen, r) {
+			continue
+		}
 		for _, alias := range site.Alias {
 			if strings.Contains(alias, "*") && matchWildcardAlias(alias, requestHost) {
-				return site.Host
+				return site.Host, matchWildcard
 			}
 		}
 	}
 
 	// Не нашли совпадений - возвращаем как есть
-	return requestHost
+	return requestHost, matchNone
 }
 
 // Получает список root_file для сайта из конфигурации
 func getRootFiles(host string) []string {
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
 		if site.Host == host {
 			if site.Root_file != "" {
 				// Разделяем по запятой и убираем пробелы
@@ -152,7 +180,7 @@ func getRootFiles(host string) []string {
 // Находит первый существующий root файл из списка
 func findExistingRootFile(host string, dirPath string) (string, bool) {
 	rootFiles := getRootFiles(host)
-	basePath := "WebServer/www/" + host + "/public_www" + dirPath
+	basePath := config.DocumentRoot(host) + dirPath
 
 	for _, rootFile := range rootFiles {
 		fullPath := basePath + rootFile
@@ -165,7 +193,7 @@ func findExistingRootFile(host string, dirPath string) (string, bool) {
 
 // Проверяет включен ли роутинг через root файл для сайта
 func isRootFileRoutingEnabled(host string) bool {
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
 		if site.Host == host {
 			return site.Root_file_routing
 		}
@@ -180,12 +208,22 @@ func checkVAccessAndHandle(w http.ResponseWriter, r *http.Request, filePath stri
 	accessAllowed, errorPage := CheckVAccess(filePath, host, r)
 	if !accessAllowed {
 		HandleVAccessError(w, r, errorPage, host)
-		tools.Logs_file(2, "vAccess", "🚫 Доступ запрещён vAccess: "+r.RemoteAddr+" → "+r.Host+filePath+" (error: "+errorPage+")", "logs_vaccess.log", false)
+		vaccessLog.Debug("Запрос отклонён vAccess", "ip", clientIP(r), "host", r.Host, "path", filePath, "error_page", errorPage)
 		return false
 	}
 	return true
 }
 
+// Проверяет, отвечает ли сайт на порту, куда пришёл запрос
+func isSiteListening(host string, r *http.Request) bool {
+	for _, site := range config.Current().Site_www {
+		if site.Host == host {
+			return listenAllowed(site.Listen, r)
+		}
+	}
+	return true
+}
+
 // Проверяет включен ли сайт (оптимизировано через кэш)
 func isSiteActive(host string) bool {
 	statusMutex.RLock()
@@ -207,19 +245,32 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	host := Alias_Run(r)           // Получаем хост из запроса
-	https_check := !(r.TLS == nil) // Проверяем, по HTTPS ли запрос
-	root_url := r.URL.Path == "/"  // Проверяем, является ли запрос корневым URL
-
 	// Проверяем, обработал ли прокси запрос
 	if StartHandlerProxy(w, r) {
 		return // Если прокси обработал запрос, прерываем выполнение
 	}
 
+	serveSite(w, r, Alias_Run(r))
+}
+
+// serveSite отдаёт запрос сайту host (из Site_www или маршрута прокси с site)
+func serveSite(w http.ResponseWriter, r *http.Request, host string) {
+	https_check := !(r.TLS == nil) // Проверяем, по HTTPS ли запрос
+	root_url := r.URL.Path == "/"  // Проверяем, является ли запрос корневым URL
+
 	// Проверяем статус сайта
+	site, exists := findSite(host)
+	if exists {
+		setAccessLog(w, "site", site.Host, site.Access_log)
+	}
+	if !exists || !isSiteListening(host, r) {
+		serveErrorPage(w, r, http.StatusNotFound, "")
+		httpLog.Debug("Сайт не найден", "host", host)
+		return
+	}
 	if !isSiteActive(host) {
-		http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
-		tools.Logs_file(2, "H503", "🚫 Сайт отключен: "+host, "logs_http.log", false)
+		serveErrorPage(w, r, http.StatusServiceUnavailable, host)
+		httpLog.Debug("Сайт отключен", "host", host)
 		return
 	}
 
@@ -228,18 +279,13 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if https_check {
-
-		tools.Logs_file(0, "HTTPS", "🔍 IP клиента: "+r.RemoteAddr+" Обработка запроса: https://"+r.Host+r.URL.Path, "logs_https.log", false)
-
-	} else {
-
-		tools.Logs_file(0, "HTTP", "🔍 IP клиента: "+r.RemoteAddr+" Обработка запроса: http://"+r.Host+r.URL.Path, "logs_http.log", false)
+	// Сам запрос попадает в access-лог (см. access_log.go)
+	if !https_check {
 
 		// Если сертификат для домена существует в папке cert, перенаправляем на HTTPS
 		if checkHostCert(r) {
 			// Если запрос не по HTTPS, перенаправляем на HTTPS
-			httpsURL := "https://" + r.Host + r.URL.RequestURI()
+			httpsURL := httpsRedirectURL(r)
 			http.Redirect(w, r, httpsURL, http.StatusMovedPermanently)
 			return // Прерываем выполнение после редиректа
 		}
@@ -247,9 +293,10 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверяем существование директории сайта
-	if _, err := os.Stat("WebServer/www/" + host + "/public_www"); err != nil {
-		http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
-		tools.Logs_file(2, "H404", "🔍 IP клиента: "+r.RemoteAddr+" Директория сайта не найдена: "+host, "logs_http.log", false)
+	documentRoot := config.DocumentRoot(host)
+	if _, err := os.Stat(documentRoot); err != nil {
+		serveErrorPage(w, r, http.StatusNotFound, host)
+		httpLog.Warn("Корень документов сайта не найден", "host", host, "document_root", documentRoot)
 		return
 	}
 
@@ -259,17 +306,21 @@ func handler(w http.ResponseWriter, r *http.Request) {
 			// Обрабатываем найденный root файл (статический или PHP)
 			HandlePHPRequest(w, r, host, "/"+rootFile, r.URL.RequestURI(), r.URL.Path)
 		} else {
-			// Ни один root файл не найден - показываем ошибку
+			// Ни один root файл не найден - листинг (если включён) или ошибка
+			if isAutoindexEnabled(host, "/") {
+				serveAutoindex(w, r, host, "/", documentRoot)
+				return
+			}
 			rootFiles := getRootFiles(host)
-			tools.Logs_file(2, "H404", "🔍 IP клиента: "+r.RemoteAddr+" Root файлы не найдены: "+strings.Join(rootFiles, ", "), "logs_http.log", false)
-			http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
+			httpLog.Warn("Root файлы не найдены", "host", host, "root_file", strings.Join(rootFiles, ","))
+			serveErrorPage(w, r, http.StatusNotFound, host)
 		}
 	}
 
 	if !root_url {
 
 		// Проверяем существование запрашиваемого файла
-		filePath := "WebServer/www/" + host + "/public_www" + r.URL.Path
+		filePath := documentRoot + r.URL.Path
 
 		if fileInfo, err := os.Stat(filePath); err == nil {
 			// Путь существует - проверяем что это
@@ -288,10 +339,14 @@ func handler(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 
-				// Если никаких индексных файлов нет - показываем ошибку (запрещаем листинг)
+				// Если никаких индексных файлов нет - листинг (если включён для пути) или ошибка
+				if isAutoindexEnabled(host, dirPath) {
+					serveAutoindex(w, r, host, dirPath, filePath)
+					return
+				}
 				rootFiles := getRootFiles(host)
-				tools.Logs_file(2, "H404", "🔍 IP клиента: "+r.RemoteAddr+" Индексные файлы не найдены в директории "+r.Host+r.URL.Path+": "+strings.Join(rootFiles, ", "), "logs_http.log", false)
-				http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
+				httpLog.Debug("Индексный файл не найден в директории", "host", host, "path", r.URL.Path, "root_file", strings.Join(rootFiles, ","))
+				serveErrorPage(w, r, http.StatusForbidden, host)
 
 			} else {
 				// Это файл - обрабатываем через HandlePHPRequest
@@ -308,13 +363,13 @@ func handler(w http.ResponseWriter, r *http.Request) {
 				} else {
 					// Root файлы не найдены
 					rootFiles := getRootFiles(host)
-					tools.Logs_file(2, "H404", "🔍 IP клиента: "+r.RemoteAddr+" Root файлы не найдены для роутинга: "+strings.Join(rootFiles, ", "), "logs_http.log", false)
-					http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
+					httpLog.Warn("Root файлы для роутинга не найдены", "host", host, "root_file", strings.Join(rootFiles, ","))
+					serveErrorPage(w, r, http.StatusNotFound, host)
 				}
 			} else {
 				// Роутинг отключен - показываем обычную 404
-				http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
-				tools.Logs_file(2, "H404", "🔍 IP клиента: "+r.RemoteAddr+" Файл не найден: "+r.Host+r.URL.Path, "logs_http.log", false)
+				serveErrorPage(w, r, http.StatusNotFound, host)
+				httpLog.Debug("Файл не найден", "host", host, "path", r.URL.Path)
 			}
 		}
 	}
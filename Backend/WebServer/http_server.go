@@ -2,45 +2,104 @@ package webserver
 
 import (
 	"net/http"
-	tools "vServer/Backend/tools"
+	"sync"
+	config "vServer/Backend/config"
 )
 
 var httpServer *http.Server
-var port_http string = "80"
+var httpListeners []*sharedListener
+var httpBindings string // Адреса, на которых открыты сокеты (см. listenBindings)
+var httpWanted bool     // Сервер запущен и не остановлен вручную, даже если порты не открылись
+var httpMutex sync.Mutex
 
 // GetHTTPStatus возвращает статус HTTP сервера
 func GetHTTPStatus() bool {
+	httpMutex.Lock()
+	defer httpMutex.Unlock()
 	return httpServer != nil
 }
 
+// HTTPWanted сообщает, должен ли HTTP сервер работать: он запущен или не смог открыть порты
+func HTTPWanted() bool {
+	httpMutex.Lock()
+	defer httpMutex.Unlock()
+	return httpWanted
+}
+
 // Запуск HTTP сервера
 func StartHTTP() {
 
-	if tools.Port_check("HTTP", "localhost", port_http) {
+	httpMutex.Lock()
+	httpWanted = true
+	listeners := openListeners("HTTP", config.HTTPPorts(), httpLog)
+	if len(listeners) == 0 {
+		httpMutex.Unlock()
 		return
 	}
 
 	// Создаем HTTP сервер
-	httpServer = &http.Server{
-		Addr:    ":" + port_http,
+	server := &http.Server{
 		Handler: nil,
 	}
+	httpServer = server
+	httpListeners = listeners
+	httpBindings = listenBindings(config.HTTPPorts())
+	httpMutex.Unlock()
 
-	tools.Logs_file(0, "HTTP ", "💻 HTTP сервер запущен на порту 80", "logs_http.log", true)
+	httpLog.Console().Info("HTTP сервер запущен", "listen", listenersString(listeners))
+
+	serveListeners(server, listeners, false, httpLog)
+}
 
-	if err := httpServer.ListenAndServe(); err != nil {
-		// Игнорируем нормальную ошибку при остановке сервера
-		if err.Error() != "http: Server closed" {
-			tools.Logs_file(1, "HTTP", "❌ Ошибка запуска сервера: "+err.Error(), "logs_http.log", true)
+// RestartHTTPServer перезапускает HTTP сервер без закрытия портов:
+// новый сервер начинает принимать соединения на тех же сокетах, а старый
+// дорабатывает активные запросы. Если изменились адреса или порты - полный перезапуск,
+// если сервер не работает (в том числе не смог открыть порты) - запуск
+func RestartHTTPServer() {
+	httpMutex.Lock()
+
+	if httpServer == nil || httpBindings != listenBindings(config.HTTPPorts()) {
+		// Адреса изменились - закрываем старые сокеты, доработку запросов ведём в фоне
+		oldServer, oldListeners := httpServer, httpListeners
+		httpServer, httpListeners = nil, nil
+		httpMutex.Unlock()
+
+		if oldServer != nil {
+			closeListeners(oldListeners)
+			go shutdownServer(oldServer, httpLog)
 		}
+		go StartHTTP()
+		return
 	}
+
+	oldServer := httpServer
+	server := &http.Server{
+		Handler: nil,
+	}
+	httpServer = server
+	listeners := httpListeners
+	httpMutex.Unlock()
+
+	go serveListeners(server, listeners, false, httpLog)
+	go shutdownServer(oldServer, httpLog)
+
+	httpLog.Console().Info("HTTP сервер перезапущен без закрытия портов")
 }
 
 // StopHTTPServer останавливает HTTP сервер
+// Порты закрываются сразу, активные запросы дорабатывают до shutdown_timeout
 func StopHTTPServer() {
-	if httpServer != nil {
-		httpServer.Close()
-		httpServer = nil
-		tools.Logs_file(0, "HTTP", "HTTP сервер остановлен", "logs_http.log", true)
+	httpMutex.Lock()
+	server := httpServer
+	listeners := httpListeners
+	httpServer = nil
+	httpListeners = nil
+	httpWanted = false
+	httpMutex.Unlock()
+
+	if server != nil {
+		closeListeners(listeners)
+		shutdownServer(server, httpLog)
+		httpLog.Console().Info("HTTP сервер остановлен")
 	}
 }
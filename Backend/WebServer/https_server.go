@@ -2,87 +2,140 @@ package webserver
 
 import (
 	"crypto/tls"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	tools "vServer/Backend/tools"
+	"sync"
+	config "vServer/Backend/config"
 )
 
 var certDir = "WebServer/cert/"
 var certMap map[string]*tls.Certificate
 var fallbackCert *tls.Certificate
 var httpsServer *http.Server
-var port_https string = "443"
+var httpsListeners []*sharedListener
+var httpsBindings string
+var httpsWanted bool
+var httpsMutex sync.Mutex
 
 // GetHTTPSStatus возвращает статус HTTPS сервера
 func GetHTTPSStatus() bool {
+	httpsMutex.Lock()
+	defer httpsMutex.Unlock()
 	return httpsServer != nil
 }
 
+// HTTPSWanted сообщает, должен ли HTTPS сервер работать (см. HTTPWanted)
+func HTTPSWanted() bool {
+	httpsMutex.Lock()
+	defer httpsMutex.Unlock()
+	return httpsWanted
+}
+
+// newHTTPSServer создаёт HTTPS сервер с выбором сертификата по SNI
+func newHTTPSServer() *http.Server {
+	// Конфигурация TLS
+	tlsConfig := &tls.Config{
+		GetCertificate: selectCertificate,
+	}
+
+	return &http.Server{
+		TLSConfig: tlsConfig,
+		Handler:   nil,
+	}
+}
+
+// selectCertificate выбирает сертификат по SNI: домен, родительский домен или fallback
+// (общий для HTTPS сервера и Stream_Service с tls)
+func selectCertificate(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	serverName := chi.ServerName
+
+	if serverName == "" {
+		httpsLog.Debug("Подключение без SNI", "remote_addr", chi.Conn.RemoteAddr().String())
+
+	} else if cert, ok := certMap[serverName]; ok {
+		// Найден точный сертификат для домена
+		return cert, nil
+
+	} else {
+		// Пробуем найти сертификат для родительского домена
+		parentDomain := getParentDomain(serverName)
+		if parentDomain != "" {
+			if cert, ok := certMap[parentDomain]; ok {
+				httpsLog.Debug("Используем сертификат родительского домена", "server_name", serverName, "cert", parentDomain)
+				return cert, nil
+			}
+		}
+
+		httpsLog.Warn("Нет сертификата для домена", "server_name", serverName)
+	}
+
+	if fallbackCert != nil {
+		httpsLog.Debug("Используем fallback-сертификат", "server_name", serverName)
+		return fallbackCert, nil
+	}
+
+	httpsLog.Console().Error("Нет fallback-сертификата, соединение отклонено", "server_name", serverName)
+	return nil, nil
+}
+
 // Запуск https сервера
 func StartHTTPS() {
 
-	if tools.Port_check("HTTPS", "localhost", port_https) {
+	httpsMutex.Lock()
+	httpsWanted = true
+	listeners := openListeners("HTTPS", config.HTTPSPorts(), httpsLog)
+	if len(listeners) == 0 {
+		httpsMutex.Unlock()
 		return
 	}
 
 	// Отключаем вывод ошибок TLS в консоль
 	log.SetOutput(io.Discard)
 
-	// Конфигурация TLS
-	tlsConfig := &tls.Config{
-		GetCertificate: func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			serverName := chi.ServerName
+	// Запуск сервера
+	server := newHTTPSServer()
+	httpsServer = server
+	httpsListeners = listeners
+	httpsBindings = listenBindings(config.HTTPSPorts())
+	httpsMutex.Unlock()
 
-			if serverName == "" {
-				tools.Logs_file(1, "HTTPS", "⚠️ Подключение без SNI (возможно по IP)", "logs_https.log", false)
+	httpsLog.Console().Info("HTTPS сервер запущен", "listen", listenersString(listeners))
 
-			} else if cert, ok := certMap[serverName]; ok {
-				// Найден точный сертификат для домена
-				return cert, nil
+	serveListeners(server, listeners, true, httpsLog)
+}
 
-			} else {
-				// Пробуем найти сертификат для родительского домена
-				parentDomain := getParentDomain(serverName)
-				if parentDomain != "" {
-					if cert, ok := certMap[parentDomain]; ok {
-						tools.Logs_file(1, "HTTPS", "✅ Используем сертификат родительского домена "+parentDomain+" для "+serverName, "logs_https.log", false)
-						return cert, nil
-					}
-				}
-
-				tools.Logs_file(1, "HTTPS", "⚠️ Нет сертификата для: "+serverName, "logs_https.log", false)
-			}
+// RestartHTTPSServer перезапускает HTTPS сервер без закрытия портов (см. RestartHTTPServer)
+func RestartHTTPSServer() {
+	httpsMutex.Lock()
 
-			if fallbackCert != nil {
-				tools.Logs_file(1, "HTTPS", "⚠️ Используем fallback-сертификат", "logs_https.log", false)
-				return fallbackCert, nil
-			}
+	if httpsServer == nil || httpsBindings != listenBindings(config.HTTPSPorts()) {
+		// Адреса изменились - закрываем старые сокеты, доработку запросов ведём в фоне
+		oldServer, oldListeners := httpsServer, httpsListeners
+		httpsServer, httpsListeners = nil, nil
+		httpsMutex.Unlock()
 
-			tools.Logs_file(1, "HTTPS", "❌ Нет fallback-сертификата — соединение будет отклонено", "logs_https.log", true)
-			return nil, nil
-		},
+		if oldServer != nil {
+			closeListeners(oldListeners)
+			go shutdownServer(oldServer, httpsLog)
+		}
+		go StartHTTPS()
+		return
 	}
 
-	// Запуск сервера
-	httpsServer = &http.Server{
-		Addr:      ":" + port_https,
-		TLSConfig: tlsConfig,
-		Handler:   nil,
-	}
+	oldServer := httpsServer
+	server := newHTTPSServer()
+	httpsServer = server
+	listeners := httpsListeners
+	httpsMutex.Unlock()
 
-	tools.Logs_file(0, "HTTPS", "✅ HTTPS сервер запущен на порту "+port_https, "logs_https.log", true)
+	go serveListeners(server, listeners, true, httpsLog)
+	go shutdownServer(oldServer, httpsLog)
 
-	if err := httpsServer.ListenAndServeTLS("", ""); err != nil {
-		// Игнорируем нормальную ошибку при остановке сервера
-		if err.Error() != "http: Server closed" {
-			tools.Logs_file(1, "HTTPS", "❌ Ошибка запуска сервера: "+err.Error(), "logs_https.log", true)
-		}
-	}
+	httpsLog.Console().Info("HTTPS сервер перезапущен без закрытия портов")
 }
 
 // Извлекает родительский домен из поддомена
@@ -131,7 +184,7 @@ func loadCertificates(certDir string) map[string]*tls.Certificate {
 
 	entries, err := os.ReadDir(certDir)
 	if err != nil {
-		tools.Logs_file(1, "HTTPS", "📁 Ошибка чтения каталога сертификатов: "+err.Error(), "logs_https.log", true)
+		httpsLog.Console().Error("Ошибка чтения каталога сертификатов", "path", certDir, "error", err)
 	}
 
 	for _, entry := range entries {
@@ -145,12 +198,12 @@ func loadCertificates(certDir string) map[string]*tls.Certificate {
 
 		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
 		if err != nil {
-			tools.Logs_file(1, "HTTPS", "⚠️ Ошибка загрузки сертификата для "+domain+": "+err.Error(), "logs_https.log", true)
+			httpsLog.Console().Warn("Ошибка загрузки сертификата", "domain", domain, "error", err)
 			continue
 		}
 
 		certMap[domain] = &cert
-		tools.Logs_file(0, "HTTPS", "✅ Загрузили сертификат для: "+tools.Color(domain, tools.Голубой), "logs_https.log", true)
+		httpsLog.Console().Info("Сертификат загружен", "domain", domain)
 	}
 
 	return certMap
@@ -162,38 +215,44 @@ func loadFallbackCertificate(fallbackDir string) *tls.Certificate {
 
 	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
 	if err != nil {
-		tools.Logs_file(1, "HTTPS", "⚠️ Не удалось загрузить fallback-сертификат: "+err.Error(), "logs_https.log", true)
+		httpsLog.Console().Warn("Не удалось загрузить fallback-сертификат", "error", err)
 		return nil
 	}
 
-	tools.Logs_file(0, "HTTPS", "✅ Fallback-сертификат загружен", "logs_https.log", true)
+	httpsLog.Console().Info("Fallback-сертификат загружен")
 	return &cert
 }
 
 func ReloadCertificates() {
-	fmt.Println("")
-	fmt.Println("🔒 Перезагружаем SSL сертификаты...")
-	fmt.Println("")
+	httpsLog.Console().Info("Перезагружаем SSL сертификаты")
 
 	// Выгружаем старые сертификаты
 	certMap = make(map[string]*tls.Certificate)
 	fallbackCert = nil
 
-	fmt.Println("⏹️ Старые сертификаты выгружены")
+	httpsLog.Debug("Старые сертификаты выгружены")
 
 	// Загружаем сертификаты заново
 	Cert_start()
 
-	fmt.Println("✅ SSL сертификаты успешно перезагружены!")
-	fmt.Println("")
+	httpsLog.Console().Info("SSL сертификаты перезагружены", "certificates", len(certMap))
 }
 
 // StopHTTPSServer останавливает HTTPS сервер
+// Порты закрываются сразу, активные запросы дорабатывают до shutdown_timeout
 func StopHTTPSServer() {
+	httpsMutex.Lock()
+	server := httpsServer
+	listeners := httpsListeners
+	httpsServer = nil
+	httpsListeners = nil
+	httpsWanted = false
+	httpsMutex.Unlock()
+
 	// Останавливаем HTTPS сервер
-	if httpsServer != nil {
-		httpsServer.Close()
-		httpsServer = nil
-		tools.Logs_file(0, "HTTPS", "HTTPS сервер остановлен", "logs_https.log", true)
+	if server != nil {
+		closeListeners(listeners)
+		shutdownServer(server, httpsLog)
+		httpsLog.Console().Info("HTTPS сервер остановлен")
 	}
 }
@@ -0,0 +1,269 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	config "vServer/Backend/config"
+	tools "vServer/Backend/tools"
+)
+
+// sharedListener владеет сокетом и раздаёт принятые соединения текущему серверу
+// Благодаря этому сокет можно передать новому http.Server без закрытия порта
+type sharedListener struct {
+	net.Listener
+	conns      chan net.Conn
+	done       chan struct{} // Закрывается вместе с сокетом
+	once       sync.Once
+	sniRouting bool // Порт HTTPS: TLS-соединения по SNI могут уйти в Stream_Service
+}
+
+// listenerHandle - представление sharedListener для одного http.Server
+// Close останавливает приём только для этого сервера, сокет остаётся открытым
+type listenerHandle struct {
+	shared *sharedListener
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newSharedListener(listener net.Listener, sniRouting bool) *sharedListener {
+	shared := &sharedListener{
+		Listener:   listener,
+		conns:      make(chan net.Conn),
+		done:       make(chan struct{}),
+		sniRouting: sniRouting,
+	}
+	go shared.acceptLoop()
+	return shared
+}
+
+// acceptLoop принимает соединения до закрытия сокета
+func (s *sharedListener) acceptLoop() {
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Временная ошибка (например, кончились дескрипторы) - пробуем снова
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+
+		// ClientHello читаем в отдельной горутине: медленный клиент не должен задерживать остальных
+		if s.sniRouting && sniStreamsActive() {
+			go s.routeSNI(conn)
+			continue
+		}
+		s.deliver(conn)
+	}
+}
+
+// deliver передаёт соединение текущему серверу; после закрытия сокета - закрывает его
+func (s *sharedListener) deliver(conn net.Conn) {
+	select {
+	case s.conns <- conn:
+	case <-s.done:
+		conn.Close()
+	}
+}
+
+// Close закрывает сокет и отпускает соединение, которое ждёт сервера в deliver
+func (s *sharedListener) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.Listener.Close()
+}
+
+// routeSNI отправляет TLS-соединение в Stream_Service по SNI, остальные - HTTPS серверу
+func (s *sharedListener) routeSNI(conn net.Conn) {
+	serverName, peeked, err := peekServerName(conn)
+	if err == nil {
+		if stream, state, ok := sniStream(serverName); ok {
+			serveStreamConn(peeked, stream, state)
+			return
+		}
+	}
+	s.deliver(peeked)
+}
+
+// handle создаёт новое представление сокета для очередного сервера
+func (s *sharedListener) handle() *listenerHandle {
+	return &listenerHandle{shared: s, closed: make(chan struct{})}
+}
+
+func (h *listenerHandle) Accept() (net.Conn, error) {
+	select {
+	case conn := <-h.shared.conns:
+		return conn, nil
+	case <-h.shared.done:
+		return nil, net.ErrClosed
+	case <-h.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (h *listenerHandle) Close() error {
+	h.once.Do(func() { close(h.closed) })
+	return nil
+}
+
+func (h *listenerHandle) Addr() net.Addr {
+	return h.shared.Addr()
+}
+
+// openListeners открывает слушатели на всех настроенных адресах для списка портов
+// Занятые порты пропускаются с записью в лог
+func openListeners(service string, ports []int, logger *tools.Logger) []*sharedListener {
+	var listeners []*sharedListener
+
+	for _, port := range ports {
+		checkHost := config.Current().Soft_Settings.Listen_address
+		if checkHost == "" {
+			checkHost = "localhost"
+		}
+		if tools.Port_check(service, checkHost, strconv.Itoa(port)) {
+			continue
+		}
+
+		for _, addr := range config.ListenAddresses(port) {
+			listener, err := net.Listen(addr[0], addr[1])
+			if err != nil {
+				logger.Console().Error("Не удалось открыть адрес", "addr", addr[1], "error", err)
+				continue
+			}
+			listeners = append(listeners, newSharedListener(listener, service == "HTTPS"))
+		}
+	}
+
+	return listeners
+}
+
+// closeListeners закрывает сокеты - новые соединения больше не принимаются
+func closeListeners(listeners []*sharedListener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
+
+// listenersString возвращает адреса слушателей для логов
+func listenersString(listeners []*sharedListener) string {
+	addrs := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		addrs = append(addrs, listener.Addr().String())
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// serveListeners запускает сервер на всех слушателях и блокируется, пока сервер их не отпустит
+func serveListeners(server *http.Server, listeners []*sharedListener, useTLS bool, logger *tools.Logger) {
+	var wg sync.WaitGroup
+	for _, listener := range listeners {
+		wg.Add(1)
+		go func(l net.Listener) {
+			defer wg.Done()
+
+			var err error
+			if useTLS {
+				err = server.ServeTLS(l, "", "")
+			} else {
+				err = server.Serve(l)
+			}
+
+			// Игнорируем нормальную ошибку при остановке сервера (сокеты могли закрыться раньше Shutdown)
+			if err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+				logger.Console().Error("Ошибка работы сервера", "addr", l.Addr().String(), "error", err)
+			}
+		}(listener.handle())
+	}
+	wg.Wait()
+}
+
+// shutdownServer корректно останавливает сервер: ждёт завершения активных запросов
+// не дольше shutdown_timeout, после чего принудительно закрывает оставшиеся соединения
+func shutdownServer(server *http.Server, logger *tools.Logger) {
+	timeout := config.ShutdownTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Console().Warn("Активные запросы не завершились, закрываем принудительно", "timeout", timeout)
+		server.Close()
+	}
+}
+
+// listenBindings возвращает все адреса, которые нужно слушать для списка портов, например
+// "tcp4 0.0.0.0:80, tcp6 [::]:80" - по нему видно, что сокеты пора переоткрыть
+func listenBindings(ports []int) string {
+	var bindings []string
+	for _, port := range ports {
+		for _, addr := range config.ListenAddresses(port) {
+			bindings = append(bindings, addr[0]+" "+addr[1])
+		}
+	}
+	return strings.Join(bindings, ", ")
+}
+
+// portsString возвращает список портов через запятую
+func portsString(ports []int) string {
+	parts := make([]string, 0, len(ports))
+	for _, port := range ports {
+		parts = append(parts, strconv.Itoa(port))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GetHTTPPorts возвращает настроенные порты HTTP сервера
+func GetHTTPPorts() string {
+	return portsString(config.HTTPPorts())
+}
+
+// GetHTTPSPorts возвращает настроенные порты HTTPS сервера
+func GetHTTPSPorts() string {
+	return portsString(config.HTTPSPorts())
+}
+
+// requestLocalPort возвращает порт слушателя, на который пришёл запрос
+func requestLocalPort(r *http.Request) int {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return 0
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.Port
+	}
+	return 0
+}
+
+// listenAllowed проверяет, отвечает ли сайт/прокси на порту, куда пришёл запрос
+func listenAllowed(listen []int, r *http.Request) bool {
+	if len(listen) == 0 {
+		return true
+	}
+
+	port := requestLocalPort(r)
+	for _, allowed := range listen {
+		if allowed == port {
+			return true
+		}
+	}
+	return false
+}
+
+// httpsRedirectURL формирует адрес для редиректа HTTP → HTTPS с учётом нестандартного порта
+func httpsRedirectURL(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if port := config.HTTPSPorts()[0]; port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	return "https://" + host + r.URL.RequestURI()
+}
@@ -0,0 +1,16 @@
+package webserver
+
+import tools "vServer/Backend/tools"
+
+// Логгеры подсистем веб-сервера (уровни задаются в Soft_Settings.log_levels)
+var (
+	httpLog         = tools.NewLogger("http", "logs_http.log")
+	httpsLog        = tools.NewLogger("https", "logs_https.log")
+	phpLog          = tools.NewLogger("php", "logs_php.log")
+	mysqlLog        = tools.NewLogger("mysql", "logs_mysql.log")
+	proxyLog        = tools.NewLogger("proxy", "logs_proxy.log")
+	streamLog       = tools.NewLogger("stream", "logs_stream.log")
+	vaccessLog      = tools.NewLogger("vaccess", "logs_vaccess.log")
+	vaccessProxyLog = tools.NewLogger("vaccess-proxy", "logs_vaccess_proxy.log")
+	errorPageLog    = tools.NewLogger("errpage", "logs_error.log")
+)
@@ -0,0 +1,109 @@
+package webserver
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+	config "vServer/Backend/config"
+	"vServer/Backend/metrics"
+)
+
+// Метрики веб-сервера (отдаются на Soft_Settings.metrics_listen)
+var (
+	requestsTotal = metrics.NewCounter("vserver_http_requests_total",
+		"HTTP запросы по сайтам и прокси (kind: site, proxy, none - домен не найден)", "kind", "site", "status")
+	requestDuration = metrics.NewHistogram("vserver_http_request_duration_seconds",
+		"Время обработки HTTP запросов, сек (status - класс ответа 2xx..5xx)", nil, "kind", "site", "status")
+	vaccessDenied = metrics.NewCounter("vserver_vaccess_denied_total",
+		"Запросы, запрещённые правилами vAccess (rule - номер действующего правила в файле и его тип)", "site", "file", "rule")
+	phpWorkerRestarts = metrics.NewCounter("vserver_php_worker_restarts_total",
+		"Перезапуски упавших FastCGI процессов PHP")
+	fastCGIErrors = metrics.NewCounter("vserver_php_fastcgi_errors_total",
+		"Ошибки FastCGI (type: connect, response)", "type")
+	proxyUpstreamErrors = metrics.NewCounter("vserver_proxy_upstream_errors_total",
+		"Ошибки запросов к бэкендам прокси", "proxy", "upstream")
+	proxyTunnels = metrics.NewGauge("vserver_proxy_upgraded_connections",
+		"Открытые upgrade-соединения (WebSocket) через прокси", "proxy")
+	streamConnections = metrics.NewCounter("vserver_stream_connections_total",
+		"Соединения Stream_Service, для UDP - сессии клиентов (result: accepted, rejected - превышен max_connections)", "stream", "result")
+	streamBytes = metrics.NewCounter("vserver_stream_bytes_total",
+		"Байты через Stream_Service (direction: in - от клиентов, out - клиентам)", "stream", "direction")
+)
+
+// Запросы, которые сейчас обрабатывает пул PHP
+var phpBusy atomic.Int64
+
+func init() {
+	metrics.NewGaugeFunc("vserver_php_workers", "FastCGI процессы PHP (state: busy, idle)", []string{"state"}, func() []metrics.Sample {
+		workers := int64(0)
+		if GetPHPStatus() {
+			workers = int64(maxWorkers)
+		}
+		busy := min(phpBusy.Load(), workers)
+		return []metrics.Sample{
+			{Labels: []string{"busy"}, Value: float64(busy)},
+			{Labels: []string{"idle"}, Value: float64(workers - busy)},
+		}
+	})
+
+	metrics.NewGaugeFunc("vserver_service_up", "Состояние сервисов (1 - работает)", []string{"service"}, func() []metrics.Sample {
+		return []metrics.Sample{
+			{Labels: []string{"http"}, Value: boolValue(GetHTTPStatus())},
+			{Labels: []string{"https"}, Value: boolValue(GetHTTPSStatus())},
+			{Labels: []string{"php"}, Value: boolValue(GetPHPStatus())},
+			{Labels: []string{"mysql"}, Value: boolValue(GetMySQLStatus())},
+			{Labels: []string{"proxy"}, Value: boolValue(config.Current().Soft_Settings.Proxy_enabled)},
+			{Labels: []string{"stream"}, Value: boolValue(GetStreamStatus())},
+		}
+	})
+
+	metrics.NewGaugeFunc("vserver_stream_active_connections", "Открытые соединения Stream_Service (для UDP - сессии клиентов)",
+		[]string{"stream"}, func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, state := range StreamStates() {
+				if state.Running {
+					samples = append(samples, metrics.Sample{Labels: []string{state.Name}, Value: float64(state.Active)})
+				}
+			}
+			return samples
+		})
+
+	metrics.NewGaugeFunc("vserver_proxy_upstream_up", "Состояние бэкендов прокси (1 - получает запросы, 0 - исключён или не прошёл проверку)",
+		[]string{"proxy", "upstream"}, proxyUpstreamUp)
+}
+
+// proxyUpstreamUp возвращает состояние бэкендов включённых прокси (1 - принимает запросы)
+func proxyUpstreamUp() []metrics.Sample {
+	proxies := config.Current().Proxy_Service
+
+	var samples []metrics.Sample
+	for _, proxy := range proxies {
+		if !proxy.Enable {
+			continue
+		}
+		for _, state := range ProxyUpstreamStates(proxy) {
+			samples = append(samples, metrics.Sample{
+				Labels: []string{proxy.ExternalDomain, state.Address},
+				Value:  boolValue(state.Status != healthDown),
+			})
+		}
+	}
+	return samples
+}
+
+// observeRequest учитывает запрос в счётчике и гистограмме времени ответа
+func observeRequest(w *accessLogWriter, duration time.Duration) {
+	kind := w.kind
+	if kind == "" {
+		kind = "none"
+	}
+	requestsTotal.Inc(kind, w.site, strconv.Itoa(w.status))
+	requestDuration.Observe(duration.Seconds(), kind, w.site, strconv.Itoa(w.status/100)+"xx")
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -13,21 +13,23 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 	config "vServer/Backend/config"
 	tools "vServer/Backend/tools"
 )
 
 var (
-	phpProcesses []*exec.Cmd
+	phpProcesses []*tools.ManagedProcess
 	fcgiPorts    []int
 	portIndex    int
 	portMutex    sync.Mutex
-	maxWorkers   = 4
+	maxWorkers   = 4     // Размер пула, задаётся php_workers при запуске
 	stopping     = false // Флаг остановки
 )
 
+// Сколько ждать завершения php-cgi после SIGTERM
+const phpStopTimeout = 5 * time.Second
+
 var address_php string
 var Сonsole_php bool = false
 
@@ -83,21 +85,23 @@ func PHP_Start() {
 	stopping = false
 
 	// Читаем настройки из конфига
-	address_php = config.ConfigData.Soft_Settings.Php_host
+	settings := config.Current().Soft_Settings
+	address_php = settings.Php_host
+	maxWorkers = config.PHPWorkers()
 
 	// Запускаем FastCGI процессы
 	for i := 0; i < maxWorkers; i++ {
-		port := config.ConfigData.Soft_Settings.Php_port + i
+		port := settings.Php_port + i
 		fcgiPorts = append(fcgiPorts, port)
 		go startFastCGIWorker(port, i)
 		time.Sleep(200 * time.Millisecond) // Задержка между запусками
 	}
 
-	tools.Logs_file(0, "PHP  ", fmt.Sprintf("💻 PHP FastCGI пул запущен (%d процессов на портах %d-%d)", maxWorkers, config.ConfigData.Soft_Settings.Php_port, config.ConfigData.Soft_Settings.Php_port+maxWorkers-1), "logs_php.log", true)
+	phpLog.Console().Info("PHP FastCGI пул запущен", "workers", maxWorkers, "ports", fmt.Sprintf("%d-%d", settings.Php_port, settings.Php_port+maxWorkers-1))
 }
 
 func startFastCGIWorker(port int, workerID int) {
-	phpPath := "WebServer/soft/PHP/php_v_8/php-cgi.exe"
+	phpPath := "WebServer/soft/PHP/php_v_8/" + tools.ExeName("php-cgi")
 
 	cmd := exec.Command(phpPath, "-b", fmt.Sprintf("%s:%d", address_php, port))
 	cmd.Env = append(os.Environ(),
@@ -106,10 +110,7 @@ func startFastCGIWorker(port int, workerID int) {
 	)
 
 	// Скрываем консольное окно
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow:    true,
-		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-	}
+	tools.HideProcessWindow(cmd)
 
 	if !Сonsole_php {
 		cmd.Stdout = nil
@@ -118,23 +119,25 @@ func startFastCGIWorker(port int, workerID int) {
 
 	err := cmd.Start()
 	if err != nil {
-		tools.Logs_file(1, "PHP", fmt.Sprintf("❌ Ошибка запуска FastCGI worker %d на порту %d: %v", workerID, port, err), "logs_php.log", true)
+		phpLog.Console().Error("Ошибка запуска FastCGI worker", "worker", workerID, "port", port, "error", err)
 		return
 	}
 
-	phpProcesses = append(phpProcesses, cmd)
-	tools.Logs_file(0, "PHP", fmt.Sprintf("✅ PHP FastCGI %d запущен на %s:%d", workerID, address_php, port), "logs_php.log", false)
+	process := tools.WatchProcess(cmd)
+	phpProcesses = append(phpProcesses, process)
+	phpLog.Info("FastCGI worker запущен", "worker", workerID, "addr", fmt.Sprintf("%s:%d", address_php, port))
 
 	// Ждём завершения процесса и перезапускаем
 	go func() {
-		cmd.Wait()
+		<-process.Exited()
 
 		// Проверяем, не останавливается ли сервер
 		if stopping {
 			return // Не перезапускаем если сервер останавливается
 		}
 
-		tools.Logs_file(1, "PHP", fmt.Sprintf("⚠️ FastCGI worker %d завершился, перезапускаем...", workerID), "logs_php.log", true)
+		phpLog.Console().Warn("FastCGI worker завершился, перезапускаем", "worker", workerID, "port", port)
+		phpWorkerRestarts.Inc()
 		time.Sleep(1 * time.Second)
 		startFastCGIWorker(port, workerID) // Перезапуск
 	}()
@@ -227,7 +230,12 @@ func HandlePHPRequest(w http.ResponseWriter, r *http.Request, host string, fileP
 		return true
 	} else {
 		// Это не PHP файл - обрабатываем как статический
-		fullPath := "WebServer/www/" + host + "/public_www" + filePath
+		if isVAccessFile(filePath) {
+			serveErrorPage(w, r, http.StatusNotFound, host)
+			vaccessLog.Debug("Запрос файла правил vAccess отклонён", "ip", clientIP(r), "host", host, "path", filePath)
+			return true
+		}
+		fullPath := config.DocumentRoot(host) + filePath
 		http.ServeFile(w, r, fullPath)
 		return true
 	}
@@ -235,34 +243,43 @@ func HandlePHPRequest(w http.ResponseWriter, r *http.Request, host string, fileP
 
 // PHPHandler с FastCGI
 func PHPHandler(w http.ResponseWriter, r *http.Request, host string, originalURI string, originalPath string) {
-	phpPath := "WebServer/www/" + host + "/public_www" + r.URL.Path
+	documentRoot := config.DocumentRoot(host)
+	phpPath := documentRoot + r.URL.Path
 
 	// Проверяем существование файла
 	if _, err := os.Stat(phpPath); os.IsNotExist(err) {
-		http.ServeFile(w, r, "WebServer/tools/error_page/index.html")
-		tools.Logs_file(2, "PHP_404", "🔍 PHP файл не найден: "+phpPath, "logs_php.log", false)
+		serveErrorPage(w, r, http.StatusNotFound, host)
+		phpLog.Debug("PHP файл не найден", "path", phpPath)
 		return
 	}
 
 	// Получаем абсолютный путь для SCRIPT_FILENAME
 	absPath, err := filepath.Abs(phpPath)
 	if err != nil {
-		tools.Logs_file(1, "PHP", "❌ Ошибка получения абсолютного пути: "+err.Error(), "logs_php.log", false)
+		phpLog.Error("Ошибка получения абсолютного пути", "path", phpPath, "error", err)
 		absPath = phpPath
 	}
+	if absRoot, err := filepath.Abs(documentRoot); err == nil {
+		documentRoot = absRoot
+	}
 
 	// Получаем порт FastCGI
 	port := getNextFCGIPort()
+	setUpstream(w, fmt.Sprintf("%s:%d", address_php, port))
 
 	// Подключаемся к FastCGI процессу
 	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", address_php, port), 5*time.Second)
 	if err != nil {
-		tools.Logs_file(1, "PHP", fmt.Sprintf("❌ Ошибка подключения к FastCGI порт %d: %v", port, err), "logs_php.log", false)
-		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		phpLog.Error("Ошибка подключения к FastCGI", "port", port, "error", err)
+		fastCGIErrors.Inc("connect")
+		serveErrorPage(w, r, http.StatusServiceUnavailable, host)
 		return
 	}
 	defer conn.Close()
 
+	phpBusy.Add(1)
+	defer phpBusy.Add(-1)
+
 	// Читаем POST данные
 	var postData []byte
 	if r.Method == "POST" {
@@ -271,10 +288,7 @@ func PHPHandler(w http.ResponseWriter, r *http.Request, host string, originalURI
 	}
 
 	// Формируем параметры FastCGI
-	serverPort := "80"
-	if r.TLS != nil {
-		serverPort = "443"
-	}
+	serverPort := strconv.Itoa(requestLocalPort(r))
 
 	// Используем переданные оригинальные значения или текущие если не переданы
 	requestURI := r.URL.RequestURI()
@@ -295,7 +309,7 @@ func PHPHandler(w http.ResponseWriter, r *http.Request, host string, originalURI
 		"CONTENT_LENGTH":    fmt.Sprintf("%d", len(postData)),
 		"SCRIPT_FILENAME":   absPath,
 		"SCRIPT_NAME":       r.URL.Path,
-		"DOCUMENT_ROOT":     "WebServer/www/" + host + "/public_www",
+		"DOCUMENT_ROOT":     documentRoot,
 		"SERVER_NAME":       host,
 		"HTTP_HOST":         host,
 		"SERVER_PORT":       serverPort,
@@ -372,12 +386,13 @@ func PHPHandler(w http.ResponseWriter, r *http.Request, host string, originalURI
 	// Читаем и стримим ответ (с поддержкой SSE и chunked transfer)
 	err = streamFastCGIResponse(conn, requestID, w)
 	if err != nil {
-		tools.Logs_file(1, "PHP", "❌ Ошибка чтения FastCGI ответа: "+err.Error(), "logs_php.log", false)
+		phpLog.Error("Ошибка чтения ответа FastCGI", "path", phpPath, "port", port, "error", err)
+		fastCGIErrors.Inc("response")
 		// Не вызываем http.Error здесь, т.к. заголовки уже могли быть отправлены
 		return
 	}
 
-	tools.Logs_file(0, "PHP", fmt.Sprintf("✅ FastCGI обработал: %s (порт %d)", phpPath, port), "logs_php.log", false)
+	phpLog.Debug("FastCGI обработал запрос", "path", phpPath, "port", port)
 }
 
 // Streaming чтение FastCGI ответа с поддержкой SSE и chunked transfer
@@ -477,7 +492,7 @@ func streamFastCGIResponse(conn net.Conn, requestID uint16, w http.ResponseWrite
 		case FCGI_END_REQUEST:
 			// Завершение запроса
 			if stderr.Len() > 0 {
-				tools.Logs_file(1, "PHP", "FastCGI stderr: "+stderr.String(), "logs_php.log", false)
+				phpLog.Error("FastCGI stderr", "output", strings.TrimSpace(stderr.String()))
 			}
 			// Если заголовки так и не были записаны (пустой ответ)
 			if !headersWritten {
@@ -530,27 +545,23 @@ func PHP_Stop() {
 	// Устанавливаем флаг остановки
 	stopping = true
 
-	for i, cmd := range phpProcesses {
-		if cmd != nil && cmd.Process != nil {
-			err := cmd.Process.Kill()
-			if err != nil {
-				tools.Logs_file(1, "PHP", fmt.Sprintf("❌ Ошибка остановки FastCGI процесса %d: %v", i, err), "logs_php.log", true)
+	// Останавливаем только свои процессы php-cgi, все сразу
+	var wg sync.WaitGroup
+	for i, process := range phpProcesses {
+		wg.Add(1)
+		go func(i int, process *tools.ManagedProcess) {
+			defer wg.Done()
+			if process.Stop(phpStopTimeout) {
+				phpLog.Warn("FastCGI процесс не завершился вовремя, остановлен принудительно", "worker", i, "timeout", phpStopTimeout)
 			} else {
-				tools.Logs_file(0, "PHP", fmt.Sprintf("✅ FastCGI процесс %d остановлен", i), "logs_php.log", false)
+				phpLog.Debug("FastCGI процесс остановлен", "worker", i)
 			}
-		}
+		}(i, process)
 	}
+	wg.Wait()
 
 	phpProcesses = nil
 	fcgiPorts = nil
 
-	// Дополнительно убиваем все процессы php-cgi.exe
-	cmd := exec.Command("taskkill", "/F", "/IM", "php-cgi.exe")
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow:    true,
-		CreationFlags: 0x08000000,
-	}
-	cmd.Run()
-
-	tools.Logs_file(0, "PHP", "🛑 Все FastCGI процессы остановлены", "logs_php.log", true)
+	phpLog.Console().Info("Все FastCGI процессы остановлены")
 }
@@ -0,0 +1,294 @@
+package webserver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Балансировка прокси между несколькими бэкендами и пассивная проверка их здоровья
+
+const (
+	defaultProxyMaxFails    = 3
+	defaultProxyFailTimeout = 30 * time.Second
+)
+
+// proxyUpstream - бэкенд прокси и его состояние
+type proxyUpstream struct {
+	address string
+	weight  int
+	active  atomic.Int64 // Запросы в работе (least_conn)
+
+	// Под upstreamPool.mu
+	current   int       // Текущий вес для плавного weighted round-robin
+	fails     int       // Ошибок подряд
+	downUntil time.Time // Исключён из балансировки до этого момента
+
+	// Активная проверка (proxy_health.go), под upstreamPool.mu
+	health    string    // healthUnknown, healthUp, healthDown
+	checking  bool      // Проверка уже идёт
+	nextCheck time.Time // Когда проверять в следующий раз
+	lastCheck time.Time
+	latency   time.Duration
+	lastError string
+}
+
+// upstreamPool - бэкенды одного прокси
+type upstreamPool struct {
+	domain      string
+	route       string // Путь маршрута ("" - бэкенды самого прокси)
+	signature   string // Настройки, из которых собран пул (при изменении пул пересобирается)
+	balance     string
+	healthCheck bool // Задан health_check: бэкенды с неудачной проверкой не получают запросы
+	maxFails    int
+	failTimeout time.Duration
+	upstreams   []*proxyUpstream
+	mu          sync.Mutex
+}
+
+var (
+	upstreamPoolsMu sync.Mutex
+	upstreamPools   = make(map[string]*upstreamPool) // ExternalDomain и путь маршрута → пул
+)
+
+// proxyUpstreams возвращает бэкенды прокси: upstreams или LocalAddress:LocalPort
+func proxyUpstreams(proxy config.Proxy_Service) []config.Proxy_Upstream {
+	if len(proxy.Upstreams) > 0 {
+		return proxy.Upstreams
+	}
+	return []config.Proxy_Upstream{{Address: net.JoinHostPort(proxy.LocalAddress, proxy.LocalPort)}}
+}
+
+// upstreamPoolFor возвращает пул прокси или его маршрута (route == nil - бэкенды прокси).
+// При изменении настроек пул пересобирается, состояние бэкендов с тем же адресом сохраняется
+func upstreamPoolFor(proxy config.Proxy_Service, route *config.Proxy_Route) *upstreamPool {
+	upstreams := routeUpstreams(proxy, route)
+	routePath := ""
+	if route != nil {
+		routePath = route.Path
+	}
+	key := proxy.ExternalDomain + "|" + routePath
+	signature := fmt.Sprintf("%v|%s|%d|%d|%v", upstreams, proxy.Balance, proxy.Max_fails, proxy.Fail_timeout, proxy.Health_check != nil)
+
+	upstreamPoolsMu.Lock()
+	defer upstreamPoolsMu.Unlock()
+
+	old := upstreamPools[key]
+	if old != nil && old.signature == signature {
+		return old
+	}
+
+	pool := &upstreamPool{
+		domain:      proxy.ExternalDomain,
+		route:       routePath,
+		signature:   signature,
+		balance:     proxy.Balance,
+		healthCheck: proxy.Health_check != nil,
+		maxFails:    proxy.Max_fails,
+		failTimeout: secondsOr(proxy.Fail_timeout, defaultProxyFailTimeout),
+	}
+	if pool.maxFails <= 0 {
+		pool.maxFails = defaultProxyMaxFails
+	}
+
+	for _, upstream := range upstreams {
+		state := &proxyUpstream{address: upstream.Address, weight: max(upstream.Weight, 1), health: healthUnknown}
+		if old != nil {
+			if previous := old.find(upstream.Address); previous != nil {
+				old.mu.Lock()
+				previous.copyHealth(state, old.healthCheck && pool.healthCheck)
+				old.mu.Unlock()
+			}
+		}
+		pool.upstreams = append(pool.upstreams, state)
+	}
+
+	upstreamPools[key] = pool
+	return pool
+}
+
+func (p *upstreamPool) find(address string) *proxyUpstream {
+	for _, upstream := range p.upstreams {
+		if upstream.address == address {
+			return upstream
+		}
+	}
+	return nil
+}
+
+// copyHealth переносит состояние бэкенда в пересобранный пул (результат активной проверки -
+// только если проверка осталась включённой)
+func (u *proxyUpstream) copyHealth(target *proxyUpstream, keepActive bool) {
+	target.fails = u.fails
+	target.downUntil = u.downUntil
+	if keepActive {
+		target.health = u.health
+		target.nextCheck = u.nextCheck
+		target.lastCheck = u.lastCheck
+		target.latency = u.latency
+		target.lastError = u.lastError
+	}
+}
+
+// available: бэкенд не исключён после ошибок и не провалил активную проверку
+func (u *proxyUpstream) available(now time.Time) bool {
+	return !now.Before(u.downUntil) && u.health != healthDown
+}
+
+// pick выбирает бэкенд, пропуская уже опробованные. Исключённые бэкенды используются,
+// только если доступных не осталось
+func (p *upstreamPool) pick(r *http.Request, tried map[*proxyUpstream]bool) *proxyUpstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var candidates, down []*proxyUpstream
+	for _, upstream := range p.upstreams {
+		if tried[upstream] {
+			continue
+		}
+		if !upstream.available(now) {
+			down = append(down, upstream)
+			continue
+		}
+		candidates = append(candidates, upstream)
+	}
+	if len(candidates) == 0 {
+		candidates = down
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch p.balance {
+	case "least_conn":
+		return pickLeastConn(candidates)
+	case "ip_hash":
+		return p.pickIPHash(clientIP(r), candidates)
+	}
+	return pickWeightedRoundRobin(candidates)
+}
+
+// pickWeightedRoundRobin - плавный weighted round-robin (как в nginx): a,a,b,a,a,b вместо a,a,a,a,b,b
+func pickWeightedRoundRobin(candidates []*proxyUpstream) *proxyUpstream {
+	total := 0
+	var best *proxyUpstream
+	for _, upstream := range candidates {
+		upstream.current += upstream.weight
+		total += upstream.weight
+		if best == nil || upstream.current > best.current {
+			best = upstream
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// pickLeastConn выбирает бэкенд с наименьшим числом запросов в работе с учётом веса,
+// между равными - по weighted round-robin, чтобы первый в списке не получал всё
+func pickLeastConn(candidates []*proxyUpstream) *proxyUpstream {
+	var least []*proxyUpstream
+	var leastLoad float64
+	for _, upstream := range candidates {
+		load := float64(upstream.active.Load()) / float64(upstream.weight)
+		switch {
+		case least == nil || load < leastLoad:
+			least, leastLoad = []*proxyUpstream{upstream}, load
+		case load == leastLoad:
+			least = append(least, upstream)
+		}
+	}
+	return pickWeightedRoundRobin(least)
+}
+
+// pickIPHash привязывает клиента к бэкенду по IP. Распределение считается по всем бэкендам,
+// чтобы исключение одного не перемешивало остальных клиентов
+func (p *upstreamPool) pickIPHash(ip string, candidates []*proxyUpstream) *proxyUpstream {
+	total := 0
+	for _, upstream := range p.upstreams {
+		total += upstream.weight
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(ip))
+	slot := int(hash.Sum32() % uint32(total))
+
+	start := 0
+	for i, upstream := range p.upstreams {
+		if slot < upstream.weight {
+			start = i
+			break
+		}
+		slot -= upstream.weight
+	}
+
+	// Выбранный бэкенд недоступен - идём по кругу до ближайшего доступного
+	for i := 0; i < len(p.upstreams); i++ {
+		upstream := p.upstreams[(start+i)%len(p.upstreams)]
+		for _, candidate := range candidates {
+			if candidate == upstream {
+				return upstream
+			}
+		}
+	}
+	return candidates[0]
+}
+
+// success сбрасывает счётчик ошибок бэкенда
+func (p *upstreamPool) success(upstream *proxyUpstream) {
+	p.mu.Lock()
+	if upstream.fails == 0 && upstream.downUntil.IsZero() {
+		p.mu.Unlock()
+		return
+	}
+	recovered := !upstream.downUntil.IsZero()
+	upstream.fails = 0
+	upstream.downUntil = time.Time{}
+	state := p.state(upstream)
+	p.mu.Unlock()
+
+	if recovered {
+		proxyLog.Info("Бэкенд снова отвечает", "domain", p.domain, "upstream", upstream.address)
+		notifyUpstreamState(state)
+	}
+}
+
+// failure учитывает ошибку бэкенда; после max_fails ошибок подряд он исключается на fail_timeout
+func (p *upstreamPool) failure(upstream *proxyUpstream) {
+	proxyUpstreamErrors.Inc(p.domain, upstream.address)
+
+	p.mu.Lock()
+	upstream.fails++
+	if upstream.fails < p.maxFails {
+		p.mu.Unlock()
+		return
+	}
+	upstream.fails = 0
+	upstream.downUntil = time.Now().Add(p.failTimeout)
+	state := p.state(upstream)
+	p.mu.Unlock()
+
+	proxyLog.Warn("Бэкенд исключён из балансировки", "domain", p.domain, "upstream", upstream.address, "fail_timeout", p.failTimeout.String())
+	notifyUpstreamState(state)
+}
+
+// untried возвращает число бэкендов, которые ещё не пробовали для запроса
+func (p *upstreamPool) untried(tried map[*proxyUpstream]bool) int {
+	return len(p.upstreams) - len(tried)
+}
+
+// canRetry: повторяем только идемпотентные запросы без тела - тело уже ушло первому бэкенду
+func canRetry(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return r.ContentLength == 0 && len(r.TransferEncoding) == 0 && !strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
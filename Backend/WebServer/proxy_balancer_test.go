@@ -0,0 +1,160 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testUpstreams(weights ...int) []*proxyUpstream {
+	var upstreams []*proxyUpstream
+	for i, weight := range weights {
+		upstreams = append(upstreams, &proxyUpstream{address: string(rune('a' + i)), weight: weight})
+	}
+	return upstreams
+}
+
+func pickSequence(n int, pick func() *proxyUpstream) string {
+	var sequence strings.Builder
+	for i := 0; i < n; i++ {
+		sequence.WriteString(pick().address)
+	}
+	return sequence.String()
+}
+
+func TestPickWeightedRoundRobin(t *testing.T) {
+	cases := []struct {
+		name    string
+		weights []int
+		want    string
+	}{
+		{"один бэкенд", []int{1}, "aaaa"},
+		{"равные веса", []int{1, 1, 1}, "abcabc"},
+		{"2 к 1", []int{2, 1}, "abaaba"},
+		{"плавное распределение как в nginx", []int{5, 1, 1}, "aabacaa"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreams := testUpstreams(tc.weights...)
+			got := pickSequence(len(tc.want), func() *proxyUpstream { return pickWeightedRoundRobin(upstreams) })
+			if got != tc.want {
+				t.Errorf("получили %s, ожидали %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPickLeastConn(t *testing.T) {
+	cases := []struct {
+		name    string
+		weights []int
+		active  []int64
+		want    string
+	}{
+		{"меньше запросов в работе", []int{1, 1, 1}, []int64{3, 1, 2}, "bbb"},
+		{"нагрузка с учётом веса", []int{4, 1}, []int64{3, 1}, "aaa"},
+		{"равная нагрузка - по кругу", []int{1, 1}, []int64{2, 2}, "abab"},
+		{"без запросов - по весу", []int{2, 1}, []int64{0, 0}, "abaaba"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreams := testUpstreams(tc.weights...)
+			for i, active := range tc.active {
+				upstreams[i].active.Store(active)
+			}
+			got := pickSequence(len(tc.want), func() *proxyUpstream { return pickLeastConn(upstreams) })
+			if got != tc.want {
+				t.Errorf("получили %s, ожидали %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPickIPHash(t *testing.T) {
+	pool := &upstreamPool{upstreams: testUpstreams(1, 1, 1)}
+	all := pool.upstreams
+
+	// Один клиент всегда попадает на один бэкенд
+	first := pool.pickIPHash("10.0.0.1", all)
+	for i := 0; i < 10; i++ {
+		if got := pool.pickIPHash("10.0.0.1", all); got != first {
+			t.Fatalf("клиент перешёл с %s на %s", first.address, got.address)
+		}
+	}
+
+	// Клиенты распределяются по всем бэкендам
+	seen := map[string]bool{}
+	for i := 0; i < 256; i++ {
+		seen[pool.pickIPHash(fmt.Sprintf("10.0.1.%d", i), all).address] = true
+	}
+	if len(seen) != len(all) {
+		t.Errorf("клиенты попали только на %d бэкенда из %d", len(seen), len(all))
+	}
+
+	// Бэкенд клиента недоступен - ближайший следующий, остальные клиенты не перемешиваются
+	var rest []*proxyUpstream
+	for _, upstream := range all {
+		if upstream != first {
+			rest = append(rest, upstream)
+		}
+	}
+	next := all[0]
+	for i, upstream := range all {
+		if upstream == first {
+			next = all[(i+1)%len(all)]
+		}
+	}
+	if got := pool.pickIPHash("10.0.0.1", rest); got != next {
+		t.Errorf("после исключения %s получили %s, ожидали %s", first.address, got.address, next.address)
+	}
+	for i := 0; i < 256; i++ {
+		ip := fmt.Sprintf("192.168.0.%d", i)
+		if own := pool.pickIPHash(ip, all); own != first {
+			if got := pool.pickIPHash(ip, rest); got != own {
+				t.Fatalf("клиент %s перешёл с %s на %s", ip, own.address, got.address)
+			}
+		}
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		header map[string]string
+		chunk  bool
+		want   bool
+	}{
+		{"GET", http.MethodGet, "", nil, false, true},
+		{"HEAD", http.MethodHead, "", nil, false, true},
+		{"OPTIONS", http.MethodOptions, "", nil, false, true},
+		{"PUT без тела", http.MethodPut, "", nil, false, true},
+		{"DELETE", http.MethodDelete, "", nil, false, true},
+		{"POST", http.MethodPost, "", nil, false, false},
+		{"PATCH", http.MethodPatch, "", nil, false, false},
+		{"PUT с телом", http.MethodPut, "data", nil, false, false},
+		{"GET с chunked телом", http.MethodGet, "", nil, true, false},
+		{"GET с Expect: 100-continue", http.MethodGet, "", map[string]string{"Expect": "100-Continue"}, false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "http://example.com/", strings.NewReader(tc.body))
+			for key, value := range tc.header {
+				r.Header.Set(key, value)
+			}
+			if tc.chunk {
+				r.ContentLength = -1
+				r.TransferEncoding = []string{"chunked"}
+			}
+			if got := canRetry(r); got != tc.want {
+				t.Errorf("canRetry = %v, ожидали %v", got, tc.want)
+			}
+		})
+	}
+}
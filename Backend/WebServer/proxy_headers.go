@@ -0,0 +1,89 @@
+package webserver
+
+import (
+	"net/http"
+	"strings"
+	config "vServer/Backend/config"
+)
+
+// Заголовки запроса к бэкенду и ответа клиенту: X-Forwarded-*, Forwarded (RFC 7239) и правила прокси
+
+// setForwardHeaders передаёт бэкенду реальный IP клиента, его протокол и исходный Host
+// Цепочки X-Forwarded-For и Forwarded от предыдущих прокси сохраняются, адрес клиента добавляется в конец
+func setForwardHeaders(header http.Header, r *http.Request) {
+	clientIP := clientIP(r)
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	forwardedFor := clientIP
+	if prior := r.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		forwardedFor = strings.Join(prior, ", ") + ", " + clientIP
+	}
+
+	header.Set("X-Real-IP", clientIP)
+	header.Set("X-Forwarded-For", forwardedFor)
+	header.Set("X-Forwarded-Proto", proto)
+	header.Set("X-Forwarded-Host", r.Host)
+
+	forwarded := forwardedElement(clientIP, r.Host, proto)
+	if prior := r.Header.Values("Forwarded"); len(prior) > 0 {
+		forwarded = strings.Join(prior, ", ") + ", " + forwarded
+	}
+	header.Set("Forwarded", forwarded)
+}
+
+// forwardedElement собирает элемент Forwarded: for=192.0.2.60;host=example.com;proto=https
+func forwardedElement(clientIP string, host string, proto string) string {
+	// IPv6 в for= пишется в квадратных скобках и кавычках
+	if strings.Contains(clientIP, ":") {
+		clientIP = "[" + clientIP + "]"
+	}
+	return "for=" + forwardedValue(clientIP) + ";host=" + forwardedValue(host) + ";proto=" + proto
+}
+
+// forwardedValue возвращает значение как token или quoted-string (RFC 7230 3.2.6)
+func forwardedValue(value string) string {
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+func isTokenChar(c rune) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
+
+// upstreamHost возвращает Host для бэкенда: адрес бэкенда (по умолчанию), Host клиента
+// (preserve_host) или заданное имя (upstream_host)
+func upstreamHost(proxy config.Proxy_Service, r *http.Request, address string) string {
+	switch {
+	case proxy.Preserve_host:
+		return r.Host
+	case proxy.Upstream_host != "":
+		return proxy.Upstream_host
+	}
+	return address
+}
+
+// applyHeaderRules применяет правила прокси: сначала remove, потом set, потом add
+func applyHeaderRules(header http.Header, rules *config.Proxy_Headers) {
+	if rules == nil {
+		return
+	}
+	for _, name := range rules.Remove {
+		header.Del(name)
+	}
+	for name, value := range rules.Set {
+		header.Set(name, value)
+	}
+	for name, value := range rules.Add {
+		header.Add(name, value)
+	}
+}
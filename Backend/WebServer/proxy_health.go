@@ -0,0 +1,274 @@
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Активная проверка бэкендов прокси (health_check) и состояние бэкендов для админки
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+)
+
+// Результат активной проверки бэкенда
+const (
+	healthUnknown = "unknown" // Ещё не проверялся
+	healthUp      = "up"
+	healthDown    = "down"
+)
+
+// UpstreamState - текущее состояние бэкенда прокси
+type UpstreamState struct {
+	Domain         string
+	Route          string // Путь маршрута ("" - бэкенды самого прокси)
+	Address        string
+	Weight         int
+	Status         string // up, down или unknown (health_check задан, но проверки ещё не было)
+	Ejected        bool   // Исключён после max_fails ошибок запросов
+	ActiveRequests int64
+	Latency        time.Duration // Время последней проверки
+	LastCheck      time.Time
+	LastError      string
+}
+
+var (
+	healthMu      sync.Mutex
+	healthStop    chan struct{}
+	healthHandler func(UpstreamState)
+)
+
+// SetUpstreamStateHandler задаёт функцию, которую вызывают при смене состояния бэкенда (up/down)
+func SetUpstreamStateHandler(handler func(UpstreamState)) {
+	healthMu.Lock()
+	healthHandler = handler
+	healthMu.Unlock()
+}
+
+func notifyUpstreamState(state UpstreamState) {
+	healthMu.Lock()
+	handler := healthHandler
+	healthMu.Unlock()
+
+	if handler != nil {
+		handler(state)
+	}
+}
+
+// ProxyUpstreamStates возвращает состояние бэкендов прокси и его маршрутов
+func ProxyUpstreamStates(proxy config.Proxy_Service) []UpstreamState {
+	var states []UpstreamState
+	for _, pool := range proxyPools(proxy) {
+		pool.mu.Lock()
+		for _, upstream := range pool.upstreams {
+			states = append(states, pool.state(upstream))
+		}
+		pool.mu.Unlock()
+	}
+	return states
+}
+
+// state собирает состояние бэкенда (под p.mu)
+func (p *upstreamPool) state(upstream *proxyUpstream) UpstreamState {
+	now := time.Now()
+
+	status := healthUp
+	switch {
+	case !upstream.available(now):
+		status = healthDown
+	case p.healthCheck && upstream.health == healthUnknown:
+		status = healthUnknown
+	}
+
+	return UpstreamState{
+		Domain:         p.domain,
+		Route:          p.route,
+		Address:        upstream.address,
+		Weight:         upstream.weight,
+		Status:         status,
+		Ejected:        now.Before(upstream.downUntil),
+		ActiveRequests: upstream.active.Load(),
+		Latency:        upstream.latency,
+		LastCheck:      upstream.lastCheck,
+		LastError:      upstream.lastError,
+	}
+}
+
+// StartHealthChecks запускает фоновые проверки бэкендов. Настройки читаются из конфига
+// на каждом шаге, поэтому перезагрузка конфига подхватывается без перезапуска
+func StartHealthChecks() {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if healthStop != nil {
+		return
+	}
+	healthStop = make(chan struct{})
+	go healthLoop(healthStop)
+}
+
+// StopHealthChecks останавливает фоновые проверки
+func StopHealthChecks() {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if healthStop != nil {
+		close(healthStop)
+		healthStop = nil
+	}
+}
+
+func healthLoop(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		runDueHealthChecks()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkedProxies возвращает включённые прокси с health_check
+func checkedProxies() []config.Proxy_Service {
+	cfg := config.Current()
+	if !cfg.Soft_Settings.Proxy_enabled {
+		return nil
+	}
+
+	var proxies []config.Proxy_Service
+	for _, proxy := range cfg.Proxy_Service {
+		if proxy.Enable && proxy.Health_check != nil {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// runDueHealthChecks запускает проверки бэкендов, у которых подошло время
+func runDueHealthChecks() {
+	now := time.Now()
+	for _, proxy := range checkedProxies() {
+		interval := secondsOr(proxy.Health_check.Interval, defaultHealthInterval)
+
+		for _, pool := range proxyPools(proxy) {
+			for _, upstream := range pool.upstreams {
+				pool.mu.Lock()
+				due := !upstream.checking && !now.Before(upstream.nextCheck)
+				if due {
+					upstream.checking = true
+					upstream.nextCheck = now.Add(interval)
+				}
+				pool.mu.Unlock()
+
+				if due {
+					go checkUpstream(proxy, pool, upstream)
+				}
+			}
+		}
+	}
+}
+
+// checkUpstream отправляет бэкенду GET на путь проверки с Host прокси
+func checkUpstream(proxy config.Proxy_Service, pool *upstreamPool, upstream *proxyUpstream) {
+	check := proxy.Health_check
+	scheme := "http"
+	if proxy.ServiceHTTPSuse {
+		scheme = "https"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secondsOr(check.Timeout, defaultHealthTimeout))
+	defer cancel()
+
+	start := time.Now()
+	err := probeUpstream(ctx, proxy, scheme, upstream.address)
+	pool.healthResult(upstream, time.Since(start), err)
+}
+
+func probeUpstream(ctx context.Context, proxy config.Proxy_Service, scheme string, address string) error {
+	check := proxy.Health_check
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+address+check.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = proxy.ExternalDomain
+	req.Header.Set("User-Agent", "vServer-health-check")
+
+	transport, err := proxyTransport(proxy, scheme, address)
+	if err != nil {
+		return err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		if reason := tlsErrorReason(err); reason != "" {
+			return fmt.Errorf("%s: %w", reason, err)
+		}
+		return err
+	}
+	// Дочитываем небольшое тело, чтобы соединение вернулось в пул
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	if !expectedHealthStatus(check.Expected_status, resp.StatusCode) {
+		return fmt.Errorf("код ответа %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// expectedHealthStatus: заданный expected_status или любой 2xx/3xx
+func expectedHealthStatus(expected int, code int) bool {
+	if expected > 0 {
+		return code == expected
+	}
+	return code >= 200 && code < 400
+}
+
+// healthResult сохраняет результат проверки; при смене up/down пишет в лог и уведомляет админку
+func (p *upstreamPool) healthResult(upstream *proxyUpstream, latency time.Duration, err error) {
+	health := healthUp
+	lastError := ""
+	if err != nil {
+		health = healthDown
+		lastError = err.Error()
+	}
+
+	p.mu.Lock()
+	previous := upstream.health
+	upstream.checking = false
+	upstream.health = health
+	upstream.lastCheck = time.Now()
+	upstream.latency = latency
+	upstream.lastError = lastError
+	if health == healthUp {
+		// Бэкенд прошёл проверку - возвращаем его и после ошибок запросов
+		upstream.fails = 0
+		upstream.downUntil = time.Time{}
+	}
+	state := p.state(upstream)
+	p.mu.Unlock()
+
+	if previous == health {
+		return
+	}
+
+	switch {
+	case health == healthDown:
+		proxyLog.Warn("Бэкенд не прошёл проверку", "domain", p.domain, "upstream", upstream.address, "error", lastError)
+	case previous == healthDown:
+		proxyLog.Info("Бэкенд снова прошёл проверку", "domain", p.domain, "upstream", upstream.address,
+			"latency", latency.Round(time.Millisecond).String())
+	default:
+		proxyLog.Debug("Бэкенд прошёл проверку", "domain", p.domain, "upstream", upstream.address)
+	}
+	notifyUpstreamState(state)
+}
@@ -0,0 +1,349 @@
+package webserver
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	config "vServer/Backend/config"
+)
+
+// Переписывание ответов бэкенда (response_rewrite): адрес бэкенда в Location, Refresh и Set-Cookie
+// заменяется на домен клиента, в теле ответа - потоковые замены строк и регулярных выражений
+
+// Совпадение регулярного выражения в теле ищется в окне этого размера
+const regexReplaceWindow = 4096
+
+// Типы тела для замен по умолчанию (кроме text/*)
+var defaultRewriteTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"image/svg+xml",
+}
+
+// responseRewriter - данные запроса, нужные для переписывания ответа
+type responseRewriter struct {
+	rules   *config.Proxy_Rewrite
+	route   *config.Proxy_Route
+	request *http.Request // Запрос клиента
+	address string        // Адрес бэкенда
+	sent    string        // Host, отправленный бэкенду
+}
+
+func newResponseRewriter(proxy config.Proxy_Service, route *config.Proxy_Route, r *http.Request, address string) *responseRewriter {
+	if proxy.Response_rewrite == nil {
+		return nil
+	}
+	return &responseRewriter{
+		rules:   proxy.Response_rewrite,
+		route:   route,
+		request: r,
+		address: address,
+		sent:    upstreamHost(proxy, r, address),
+	}
+}
+
+// rewrite исправляет заголовки ответа и подключает замены в теле
+func (rw *responseRewriter) rewrite(resp *http.Response) {
+	if rw == nil {
+		return
+	}
+
+	if rw.rules.Redirects {
+		if location := resp.Header.Get("Location"); location != "" {
+			resp.Header.Set("Location", rw.rewriteURL(location))
+		}
+		if refresh := resp.Header.Get("Refresh"); refresh != "" {
+			resp.Header.Set("Refresh", rw.rewriteRefresh(refresh))
+		}
+	}
+
+	if rw.rules.Cookies {
+		cookies := resp.Header.Values("Set-Cookie")
+		for i, cookie := range cookies {
+			cookies[i] = rw.rewriteCookie(cookie)
+		}
+	}
+
+	if len(rw.rules.Body) > 0 && rw.bodyRewritable(resp) {
+		resp.Body = newBodyReplacer(resp.Body, rw.rules.Body)
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+}
+
+// isBackendHost: хост в ответе - адрес бэкенда или Host, который ему отправили
+func (rw *responseRewriter) isBackendHost(host string) bool {
+	return strings.EqualFold(host, rw.address) || strings.EqualFold(host, rw.sent)
+}
+
+// rewriteURL переводит ссылку бэкенда в адрес для клиента
+// Абсолютная ссылка на бэкенд получает протокол и Host клиента, путь - префикс маршрута
+func (rw *responseRewriter) rewriteURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+
+	if u.Host != "" {
+		if !rw.isBackendHost(u.Host) {
+			return value
+		}
+		if u.Scheme != "" {
+			u.Scheme = "http"
+			if rw.request.TLS != nil {
+				u.Scheme = "https"
+			}
+		}
+		u.Host = rw.request.Host
+	} else if !strings.HasPrefix(u.Path, "/") {
+		return value // Относительная ссылка остаётся относительной
+	}
+
+	u.Path = reverseRoutePath(rw.route, u.Path)
+	u.RawPath = ""
+	return u.String()
+}
+
+// rewriteRefresh: "5; url=http://127.0.0.1:3333/next"
+func (rw *responseRewriter) rewriteRefresh(value string) string {
+	index := strings.Index(strings.ToLower(value), "url=")
+	if index < 0 {
+		return value
+	}
+	target := strings.Trim(strings.TrimSpace(value[index+4:]), `"'`)
+	return value[:index+4] + rw.rewriteURL(target)
+}
+
+// rewriteCookie заменяет Domain бэкенда доменом клиента и переводит Path в путь маршрута
+func (rw *responseRewriter) rewriteCookie(cookie string) string {
+	parts := strings.Split(cookie, ";")
+	for i, part := range parts[1:] {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+
+		switch strings.ToLower(name) {
+		case "domain":
+			domain := strings.TrimPrefix(value, ".")
+			if strings.EqualFold(domain, hostOnly(rw.address)) || strings.EqualFold(domain, hostOnly(rw.sent)) {
+				parts[i+1] = " " + name + "=" + requestHostname(rw.request)
+			}
+		case "path":
+			parts[i+1] = " " + name + "=" + reverseRoutePath(rw.route, value)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// bodyRewritable: замены только для несжатого тела подходящего типа
+func (rw *responseRewriter) bodyRewritable(resp *http.Response) bool {
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		proxyLog.Debug("Тело ответа сжато, замены пропущены", "upstream", rw.address, "encoding", encoding)
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	if len(rw.rules.Content_types) > 0 {
+		for _, allowed := range rw.rules.Content_types {
+			if strings.EqualFold(mediaType, allowed) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// text/event-stream не трогаем: окно замен задерживало бы события
+	if strings.HasPrefix(mediaType, "text/") {
+		return mediaType != "text/event-stream"
+	}
+	for _, allowed := range defaultRewriteTypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// reverseRoutePath переводит путь бэкенда обратно в путь клиента (обратное strip_prefix/rewrite)
+func reverseRoutePath(route *config.Proxy_Route, path string) string {
+	if route == nil || (!route.Strip_prefix && route.Rewrite == "") {
+		return path
+	}
+
+	prefix, isPrefix := strings.CutSuffix(route.Path, "*")
+	if !isPrefix {
+		// Точный маршрут: бэкенд видел весь путь как rewrite (или "/")
+		target := route.Rewrite
+		if target == "" {
+			target = "/"
+		}
+		if path == target {
+			return route.Path
+		}
+		return path
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	replacement := strings.TrimSuffix(route.Rewrite, "/")
+	if replacement == "" {
+		return prefix + path
+	}
+	if path == replacement || strings.HasPrefix(path, replacement+"/") {
+		return prefix + path[len(replacement):]
+	}
+	return path
+}
+
+func hostOnly(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// newBodyReplacer оборачивает тело цепочкой замен (каждая следующая видит результат предыдущей)
+func newBodyReplacer(body io.ReadCloser, rules []config.Proxy_Replace) io.ReadCloser {
+	var reader io.Reader = body
+	for _, rule := range rules {
+		replacer := &replaceReader{src: reader, chunk: make([]byte, 32*1024)}
+
+		if rule.Regex {
+			re := cachedRegexp(rule.Search)
+			if re == nil {
+				continue
+			}
+			replacement := []byte(rule.Replace)
+			replacer.find = func(data []byte) [][]int { return re.FindAllIndex(data, -1) }
+			replacer.replace = func(match []byte) []byte { return re.ReplaceAll(match, replacement) }
+			replacer.window = regexReplaceWindow
+			replacer.context = true
+		} else {
+			search, replacement := []byte(rule.Search), []byte(rule.Replace)
+			replacer.find = func(data []byte) [][]int {
+				var matches [][]int
+				for pos := 0; len(search) > 0 && pos < len(data); {
+					index := bytes.Index(data[pos:], search)
+					if index < 0 {
+						break
+					}
+					matches = append(matches, []int{pos + index, pos + index + len(search)})
+					pos += index + len(search)
+				}
+				return matches
+			}
+			replacer.replace = func([]byte) []byte { return replacement }
+			replacer.window = len(search) - 1
+		}
+		reader = replacer
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, body}
+}
+
+// replaceReader потоково заменяет совпадения. Последние window байт придерживаются,
+// пока не придут следующие данные: совпадение могло начаться на границе блоков
+type replaceReader struct {
+	src     io.Reader
+	find    func([]byte) [][]int // Все непересекающиеся совпадения, как у regexp.FindAllIndex
+	replace func([]byte) []byte
+	window  int
+	context bool // Якорям (^, \A, \b) нужен байт перед буфером
+
+	chunk   []byte
+	pending []byte // Ещё не обработанные данные
+	out     []byte // Готовые к отдаче данные
+	eof     bool
+
+	started    bool // pending уже не с начала потока
+	prev       byte // Последний обработанный байт перед pending
+	afterMatch bool // pending начинается сразу после замены
+}
+
+func (r *replaceReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		n, err := r.src.Read(r.chunk)
+		r.pending = append(r.pending, r.chunk[:n]...)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		r.process()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// process переносит в out всё, что уже не может стать частью нового совпадения
+func (r *replaceReader) process() {
+	// Совпадения ищутся по всему буферу сразу: так пустые совпадения (a*, $)
+	// и якоря обрабатываются так же, как в regexp.ReplaceAll.
+	// Не в начале потока перед буфером ставится предыдущий байт, иначе ^ и \A
+	// срабатывали бы на каждой границе буфера
+	data, skip := r.pending, 0
+	if r.context && r.started {
+		data, skip = append([]byte{r.prev}, r.pending...), 1
+	}
+	matches := r.find(data)
+	if skip == 1 && len(matches) > 0 && matches[0][0] == 0 && matches[0][1] > 1 {
+		// Совпадение, начатое с уже обработанного байта, сбило бы разбор - ищем только в новых данных
+		data, skip = r.pending, 0
+		matches = r.find(data)
+	}
+
+	boundary := len(data) - r.window
+	pos := skip
+	matchEnd := -1
+	if r.afterMatch {
+		matchEnd = skip
+	}
+
+	for _, loc := range matches {
+		start, end := loc[0], loc[1]
+		// Пустое совпадение сразу после замены regexp.ReplaceAll тоже пропускает
+		if start < skip || (start == end && start == matchEnd) {
+			continue
+		}
+		if !r.eof && start >= boundary {
+			break
+		}
+
+		r.out = append(r.out, data[pos:start]...)
+		r.out = append(r.out, r.replace(data[start:end])...)
+		pos, matchEnd = end, end
+	}
+
+	if r.eof {
+		r.out = append(r.out, data[pos:]...)
+		r.pending = nil
+		return
+	}
+
+	keep := max(pos, boundary)
+	r.out = append(r.out, data[pos:keep]...)
+	r.pending = append([]byte(nil), data[keep:]...)
+	if keep > skip {
+		r.started, r.prev = true, data[keep-1]
+	}
+	r.afterMatch = matchEnd == keep
+}
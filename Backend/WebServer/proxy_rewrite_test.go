@@ -0,0 +1,77 @@
+package webserver
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"vServer/Backend/config"
+)
+
+func TestBodyReplacerPlainAcrossReads(t *testing.T) {
+	rules := []config.Proxy_Replace{{Search: "http://backend", Replace: "https://site"}}
+	body := strings.Repeat("<a href=\"http://backend/x\">", 3)
+	want := strings.ReplaceAll(body, "http://backend", "https://site")
+
+	got, err := io.ReadAll(newBodyReplacer(io.NopCloser(iotest.OneByteReader(strings.NewReader(body))), rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("получили %q, ожидали %q", got, want)
+	}
+}
+
+// Тело больше regexReplaceWindow читается частями: якоря не должны срабатывать на границах буфера
+func TestBodyReplacerRegexAcrossBuffers(t *testing.T) {
+	body := strings.Repeat("abc foo123 xyz\naaa bbb\n", 11000) // ~260 КБ
+	if len(body) <= regexReplaceWindow {
+		t.Fatal("тело должно быть больше окна")
+	}
+
+	for _, search := range []string{`^`, `\A`, `$`, `\z`, `(?m)^`, `\bfoo`, `a*`, `foo\d+`} {
+		want := regexp.MustCompile(search).ReplaceAllString(body, ">")
+		rules := []config.Proxy_Replace{{Search: search, Replace: ">", Regex: true}}
+
+		got, err := io.ReadAll(newBodyReplacer(io.NopCloser(iotest.HalfReader(strings.NewReader(body))), rules))
+		if err != nil {
+			t.Fatalf("%q: %v", search, err)
+		}
+		if string(got) != want {
+			t.Errorf("%q: вставок %d, ожидали %d", search, strings.Count(string(got), ">"), strings.Count(want, ">"))
+		}
+	}
+}
+
+func TestBodyReplacerEmptyRegexMatch(t *testing.T) {
+	cases := []struct {
+		search, replace, body string
+	}{
+		{"$", "<!-- end -->", "<html></html>"},
+		{"$", "X", ""},
+		{"a*", "-", "baaac"},
+		{"a*", "-", "aaa"},
+		{"x*", "-", ""},
+		{"^", ">", "line"},
+	}
+
+	for _, tc := range cases {
+		want := regexp.MustCompile(tc.search).ReplaceAllString(tc.body, tc.replace)
+		rules := []config.Proxy_Replace{{Search: tc.search, Replace: tc.replace, Regex: true}}
+
+		for name, src := range map[string]io.Reader{
+			"whole":    strings.NewReader(tc.body),
+			"bytewise": iotest.OneByteReader(strings.NewReader(tc.body)),
+		} {
+			got, err := io.ReadAll(newBodyReplacer(io.NopCloser(src), rules))
+			if err != nil {
+				t.Fatalf("%q в %q (%s): %v", tc.search, tc.body, name, err)
+			}
+			if string(got) != want {
+				t.Errorf("%q в %q (%s): получили %q, ожидали %q", tc.search, tc.body, name, got, want)
+			}
+		}
+	}
+}
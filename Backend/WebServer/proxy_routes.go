@@ -0,0 +1,102 @@
+package webserver
+
+import (
+	"net/http"
+	"strings"
+	config "vServer/Backend/config"
+)
+
+// Маршруты прокси по пути (как location в nginx)
+
+// matchProxyRoute выбирает маршрут для пути: точное совпадение важнее префикса,
+// из префиксов - самый длинный
+func matchProxyRoute(routes []config.Proxy_Route, path string) *config.Proxy_Route {
+	var best *config.Proxy_Route
+	bestLength := -1
+
+	for i := range routes {
+		route := &routes[i]
+		prefix, isPrefix := strings.CutSuffix(route.Path, "*")
+		if !isPrefix {
+			if path == route.Path {
+				return route
+			}
+			continue
+		}
+
+		// "/api/*" совпадает с /api/... и с самим /api
+		if !strings.HasPrefix(path, prefix) && path != strings.TrimSuffix(prefix, "/") {
+			continue
+		}
+		if len(prefix) > bestLength {
+			best, bestLength = route, len(prefix)
+		}
+	}
+	return best
+}
+
+// routePath применяет strip_prefix и rewrite маршрута к пути запроса
+func routePath(route *config.Proxy_Route, path string) string {
+	if !route.Strip_prefix && route.Rewrite == "" {
+		return path
+	}
+
+	// Для точного маршрута заменяется весь путь
+	prefix, isPrefix := strings.CutSuffix(route.Path, "*")
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !isPrefix {
+		prefix = route.Path
+	}
+	rest := strings.TrimPrefix(path, prefix)
+
+	replacement := strings.TrimSuffix(route.Rewrite, "/")
+	newPath := replacement + rest
+	if !strings.HasPrefix(newPath, "/") {
+		newPath = "/" + newPath
+	}
+	return newPath
+}
+
+// withRoutePath возвращает копию запроса с путём для бэкенда маршрута
+func withRoutePath(r *http.Request, route *config.Proxy_Route) *http.Request {
+	path := routePath(route, r.URL.Path)
+	if path == r.URL.Path {
+		return r
+	}
+
+	routed := new(http.Request)
+	*routed = *r
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	routed.URL = &u
+	routed.RequestURI = u.RequestURI()
+	return routed
+}
+
+// hasDefaultUpstream: есть ли у прокси бэкенды для запросов вне маршрутов
+func hasDefaultUpstream(proxy config.Proxy_Service) bool {
+	return len(proxy.Upstreams) > 0 || (proxy.LocalAddress != "" && proxy.LocalPort != "")
+}
+
+// proxyPools возвращает пулы бэкендов прокси и его маршрутов
+func proxyPools(proxy config.Proxy_Service) []*upstreamPool {
+	var pools []*upstreamPool
+	if hasDefaultUpstream(proxy) {
+		pools = append(pools, upstreamPoolFor(proxy, nil))
+	}
+	for i := range proxy.Routes {
+		if len(proxy.Routes[i].Upstreams) > 0 {
+			pools = append(pools, upstreamPoolFor(proxy, &proxy.Routes[i]))
+		}
+	}
+	return pools
+}
+
+// routeUpstreams возвращает бэкенды маршрута или прокси (route == nil)
+func routeUpstreams(proxy config.Proxy_Service, route *config.Proxy_Route) []config.Proxy_Upstream {
+	if route != nil {
+		return route.Upstreams
+	}
+	return proxyUpstreams(proxy)
+}
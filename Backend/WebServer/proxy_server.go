@@ -1,191 +1,130 @@
 package webserver
 
 import (
-	"bytes"
-	"crypto/tls"
-	"io"
-	"log"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"vServer/Backend/config"
-	tools "vServer/Backend/tools"
 )
 
+// Скомпилированные регулярные выражения прокси: regex alias и замены в теле ответа
 var (
-	configMutex sync.RWMutex
+	proxyRegexpMu sync.Mutex
+	proxyRegexps  = make(map[string]*regexp.Regexp)
 )
 
 func StartHandlerProxy(w http.ResponseWriter, r *http.Request) (valid bool) {
-	valid = false
-
-	configMutex.RLock()
-	defer configMutex.RUnlock()
-
-	// Проверяем глобальный флаг прокси
-	if !config.ConfigData.Soft_Settings.Proxy_enabled {
+	proxyConfig, level := findProxy(r)
+	if level == matchNone {
 		return false
 	}
 
-	// Проходим по всем прокси конфигурациям
-	for _, proxyConfig := range config.ConfigData.Proxy_Service {
-		// Пропускаем отключенные прокси
-		if !proxyConfig.Enable {
-			continue
-		}
+	// Сайт с более точным совпадением домена важнее прокси (приоритеты как в Alias_Run)
+	if _, siteLevel := matchSiteHost(r); siteLevel != matchNone && siteLevel < level {
+		return false
+	}
 
-		// Проверяем совпадение домена
-		if r.Host != proxyConfig.ExternalDomain {
-			continue
-		}
+	setAccessLog(w, "proxy", proxyConfig.ExternalDomain, proxyConfig.Access_log)
 
-		valid = true
+	// Проверяем vAccess для прокси
+	accessAllowed, errorPage := CheckProxyVAccess(r.URL.Path, proxyConfig.ExternalDomain, r)
+	if !accessAllowed {
+		// Доступ запрещён - обрабатываем страницу ошибки
+		HandleProxyVAccessError(w, r, errorPage, proxyConfig)
+		return true
+	}
 
-		// Проверяем vAccess для прокси
-		accessAllowed, errorPage := CheckProxyVAccess(r.URL.Path, proxyConfig.ExternalDomain, r)
-		if !accessAllowed {
-			// Доступ запрещён - обрабатываем страницу ошибки
-			HandleProxyVAccessError(w, r, errorPage)
-			return valid
-		}
+	// Проверяем AutoHTTPS - редирект с HTTP на HTTPS
+	https_check := !(r.TLS == nil)
+	if !https_check && proxyConfig.AutoHTTPS {
+		// Перенаправляем на HTTPS
+		httpsURL := httpsRedirectURL(r)
+		http.Redirect(w, r, httpsURL, http.StatusMovedPermanently)
+		httpLog.Debug("Редирект прокси на HTTPS", "ip", r.RemoteAddr, "host", r.Host, "path", r.URL.Path)
+		return true
+	}
 
-		// Проверяем AutoHTTPS - редирект с HTTP на HTTPS
-		https_check := !(r.TLS == nil)
-		if !https_check && proxyConfig.AutoHTTPS {
-			// Перенаправляем на HTTPS
-			httpsURL := "https://" + r.Host + r.URL.RequestURI()
-			http.Redirect(w, r, httpsURL, http.StatusMovedPermanently)
-			tools.Logs_file(0, "P-HTTP", "🔀 IP клиента: "+r.RemoteAddr+" Редирект HTTP → HTTPS: "+r.Host+r.URL.Path, "logs_http.log", false)
-			return valid
-		}
+	// Проксирование на локальный адрес
+	serveProxy(w, r, proxyConfig)
+	return true
+}
 
-		// Логирование прокси-запроса
-		if https_check {
-			tools.Logs_file(0, "P-HTTPS", "🔍 IP клиента: "+r.RemoteAddr+" Обработка запроса: https://"+r.Host+r.URL.Path+" → "+proxyConfig.LocalAddress+":"+proxyConfig.LocalPort, "logs_https.log", false)
-		} else {
-			tools.Logs_file(0, "P-HTTP", "🔍 IP клиента: "+r.RemoteAddr+" Обработка запроса: http://"+r.Host+r.URL.Path+" → "+proxyConfig.LocalAddress+":"+proxyConfig.LocalPort, "logs_http.log", false)
-		}
+// findProxy ищет включённый прокси для домена и порта запроса и возвращает уровень совпадения
+// Порядок: ExternalDomain, точный alias, wildcard alias, regex alias; при равенстве - первый в конфиге
+// Возвращает копию настроек - запрос может идти долго, а конфиг за это время перезагрузиться
+func findProxy(r *http.Request) (config.Proxy_Service, int) {
+	cfg := config.Current()
 
-		// Определяем протокол для локального соединения
-		protocol := "http"
-		if proxyConfig.ServiceHTTPSuse {
-			protocol = "https"
-		}
+	// Проверяем глобальный флаг прокси
+	if !cfg.Soft_Settings.Proxy_enabled {
+		return config.Proxy_Service{}, matchNone
+	}
 
-		// Читаем тело запроса в буфер для корректной передачи POST данных
-		var bodyBuffer bytes.Buffer
-		if r.Body != nil {
-			if _, err := io.Copy(&bodyBuffer, r.Body); err != nil {
-				http.Error(w, "Ошибка чтения тела запроса", http.StatusInternalServerError)
-				return valid
-			}
-			r.Body.Close()
-		}
+	host := strings.ToLower(requestHostname(r))
+	best := matchNone
+	var found config.Proxy_Service
 
-		// Проксирование на локальный адрес
-		proxyURL := protocol + "://" + proxyConfig.LocalAddress + ":" + proxyConfig.LocalPort + r.URL.RequestURI()
-		proxyReq, err := http.NewRequest(r.Method, proxyURL, &bodyBuffer)
-		if err != nil {
-			http.Error(w, "Ошибка создания прокси-запроса", http.StatusInternalServerError)
-			return valid
+	// Проходим по всем прокси конфигурациям
+	for _, proxyConfig := range cfg.Proxy_Service {
+		// Пропускаем отключенные прокси и прокси на других портах
+		if !proxyConfig.Enable || !listenAllowed(proxyConfig.Listen, r) {
+			continue
 		}
 
-		// Копируем ВСЕ заголовки без изменений (кроме технических)
-		for name, values := range r.Header {
-			// Пропускаем только технические заголовки HTTP/1.1
-			lowerName := strings.ToLower(name)
-			if lowerName == "connection" || lowerName == "upgrade" ||
-				lowerName == "proxy-connection" || lowerName == "te" ||
-				lowerName == "trailers" || lowerName == "transfer-encoding" {
-				continue
-			}
-
-			// Копируем заголовок как есть
-			for _, value := range values {
-				proxyReq.Header.Add(name, value)
-			}
+		level := proxyMatchLevel(proxyConfig, host)
+		if level == matchNone || (best != matchNone && level >= best) {
+			continue
 		}
-
-		// Добавляем заголовки для передачи реального IP клиента
-		clientIP := r.RemoteAddr
-		if colonIndex := strings.LastIndex(clientIP, ":"); colonIndex != -1 {
-			clientIP = clientIP[:colonIndex]
+		best, found = level, proxyConfig
+		if level == matchHost {
+			break
 		}
-		proxyReq.Header.Set("X-Real-IP", clientIP)
-		proxyReq.Header.Set("X-Forwarded-For", clientIP)
-		proxyReq.Header.Set("X-Forwarded-Proto", protocol)
+	}
 
-		// Устанавливаем правильный Content-Length для POST/PUT запросов
-		if bodyBuffer.Len() > 0 {
-			proxyReq.ContentLength = int64(bodyBuffer.Len())
-		}
+	return found, best
+}
 
-		// Выполняем прокси-запрос
-		client := &http.Client{
-			// Отключаем автоматическое следование редиректам для корректной работы с авторизацией
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
+// proxyMatchLevel сравнивает хост (без порта, в нижнем регистре) с доменом и alias прокси
+func proxyMatchLevel(proxy config.Proxy_Service, host string) int {
+	if strings.EqualFold(proxy.ExternalDomain, host) {
+		return matchHost
+	}
 
-		// Для HTTPS соединений настраиваем TLS (если понадобится)
-		if proxyConfig.ServiceHTTPSuse {
-			client.Transport = &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true, // Простая настройка для внутренних соединений
-				},
+	level := matchNone
+	for _, alias := range proxy.Alias {
+		switch {
+		case strings.HasPrefix(alias, "~"):
+			if level == matchNone && matchRegexAlias(alias[1:], host) {
+				level = matchRegex
 			}
-		}
-		resp, err := client.Do(proxyReq)
-		if err != nil {
-			http.Error(w, "Ошибка прокси-запроса", http.StatusBadGateway)
-			tools.Logs_file(1, "PROXY", "Ошибка прокси-запроса: "+err.Error(), "logs_proxy.log", false)
-			return valid
-		}
-		defer resp.Body.Close()
-
-		// Прозрачно копируем ВСЕ заголовки ответа без изменений
-		for name, values := range resp.Header {
-			for _, value := range values {
-				w.Header().Add(name, value)
+		case strings.Contains(alias, "*"):
+			if (level == matchNone || level > matchWildcard) && matchWildcardAlias(strings.ToLower(alias), host) {
+				level = matchWildcard
 			}
+		case strings.EqualFold(alias, host):
+			return matchAlias
 		}
+	}
+	return level
+}
 
-		// Устанавливаем статус код
-		w.WriteHeader(resp.StatusCode)
-
-		// Копируем тело ответа с поддержкой streaming (SSE, chunked responses)
-		// Используем буферизированное копирование с принудительной отправкой данных
-		flusher, canFlush := w.(http.Flusher)
-		
-		// Буфер для чанков (32KB - оптимальный размер для баланса производительности)
-		buffer := make([]byte, 32*1024)
-		
-		for {
-			n, err := resp.Body.Read(buffer)
-			if n > 0 {
-				// Записываем прочитанные данные
-				if _, writeErr := w.Write(buffer[:n]); writeErr != nil {
-					log.Printf("Ошибка записи тела ответа: %v", writeErr)
-					break
-				}
-				
-				// Принудительно отправляем данные клиенту (критично для SSE)
-				if canFlush {
-					flusher.Flush()
-				}
-			}
-			
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("Ошибка чтения тела ответа: %v", err)
-				}
-				break
-			}
-		}
+// matchRegexAlias проверяет хост регулярным выражением (без учёта регистра)
+func matchRegexAlias(pattern string, host string) bool {
+	re := cachedRegexp("(?i)" + pattern)
+	return re != nil && re.MatchString(host)
+}
 
-		return valid
-	}
+// cachedRegexp компилирует выражение один раз. Выражения проверяются при загрузке конфига,
+// для некорректного возвращается nil
+func cachedRegexp(pattern string) *regexp.Regexp {
+	proxyRegexpMu.Lock()
+	defer proxyRegexpMu.Unlock()
 
-	return valid
+	re, cached := proxyRegexps[pattern]
+	if !cached {
+		re, _ = regexp.Compile(pattern)
+		proxyRegexps[pattern] = re
+	}
+	return re
 }
@@ -0,0 +1,236 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Значения по умолчанию для транспорта к бэкендам (0 в конфиге прокси)
+const (
+	defaultProxyDialTimeout     = 10 * time.Second
+	defaultProxyResponseTimeout = 60 * time.Second
+	defaultProxyIdleTimeout     = 90 * time.Second
+	defaultProxyMaxIdleConns    = 32
+)
+
+// Общие транспорты: один пул keep-alive соединений на бэкенд и набор настроек
+var (
+	proxyTransportsMu sync.Mutex
+	proxyTransports   = make(map[string]*http.Transport)
+)
+
+// proxyTransport возвращает общий транспорт для бэкенда прокси, создавая его при первом запросе
+// Ошибка - если не удалось загрузить файлы из upstream_tls
+func proxyTransport(proxy config.Proxy_Service, scheme string, address string) (*http.Transport, error) {
+	dialTimeout := secondsOr(proxy.Dial_timeout, defaultProxyDialTimeout)
+	responseTimeout := secondsOr(proxy.Response_timeout, defaultProxyResponseTimeout)
+	idleTimeout := secondsOr(proxy.Idle_timeout, defaultProxyIdleTimeout)
+	maxIdleConns := proxy.Max_idle_conns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultProxyMaxIdleConns
+	}
+
+	key := fmt.Sprintf("%s://%s|%s|%s|%s|%d", scheme, address, dialTimeout, responseTimeout, idleTimeout, maxIdleConns)
+	if scheme == "https" && proxy.Upstream_tls != nil {
+		key += fmt.Sprintf("|%+v", *proxy.Upstream_tls)
+	}
+
+	proxyTransportsMu.Lock()
+	defer proxyTransportsMu.Unlock()
+
+	if transport, ok := proxyTransports[key]; ok {
+		return transport, nil
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   maxIdleConns,
+		IdleConnTimeout:       idleTimeout,
+		ResponseHeaderTimeout: responseTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if scheme == "https" {
+		tlsConfig, err := upstreamTLSConfig(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	proxyTransports[key] = transport
+	return transport, nil
+}
+
+// ResetProxyTransports закрывает простаивающие соединения и сбрасывает транспорты
+// (после изменения прокси в конфиге; активные запросы дорабатывают на старом транспорте)
+func ResetProxyTransports() {
+	proxyTransportsMu.Lock()
+	defer proxyTransportsMu.Unlock()
+
+	for key, transport := range proxyTransports {
+		transport.CloseIdleConnections()
+		delete(proxyTransports, key)
+	}
+	resetUpstreamTLSConfigs()
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// serveProxy передаёт запрос бэкенду прокси и потоково отдаёт ответ клиенту
+// Тело запроса не буферизуется, hop-by-hop заголовки удаляет httputil.ReverseProxy
+func serveProxy(w http.ResponseWriter, r *http.Request, proxy config.Proxy_Service) {
+	// Определяем протокол для локального соединения
+	scheme := "http"
+	if proxy.ServiceHTTPSuse {
+		scheme = "https"
+	}
+
+	// Маршрут по пути: свои бэкенды или сайт; путь для бэкенда - после strip_prefix/rewrite
+	route := matchProxyRoute(proxy.Routes, r.URL.Path)
+	if route != nil {
+		r = withRoutePath(r, route)
+		if route.Site != "" {
+			serveSite(w, r, route.Site)
+			return
+		}
+	} else if !hasDefaultUpstream(proxy) {
+		serveProxyErrorPage(w, r, http.StatusNotFound, proxy)
+		return
+	}
+
+	pool := upstreamPoolFor(proxy, route)
+	proxyAttempt(w, r, proxy, route, pool, scheme, make(map[*proxyUpstream]bool))
+}
+
+// proxyAttempt отправляет запрос выбранному бэкенду. При ошибке соединения идемпотентный
+// запрос повторяется на следующем бэкенде, пока не кончатся бэкенды или max_tries
+func proxyAttempt(w http.ResponseWriter, r *http.Request, proxy config.Proxy_Service, route *config.Proxy_Route, pool *upstreamPool, scheme string, tried map[*proxyUpstream]bool) {
+	upstream := pool.pick(r, tried)
+	tried[upstream] = true
+	address := upstream.address
+
+	// Запрос и адрес бэкенда попадают в access-лог
+	setUpstream(w, address)
+
+	// Счётчик запросов в работе для least_conn; при повторе освобождаем бэкенд сразу
+	upstream.active.Add(1)
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			upstream.active.Add(-1)
+		}
+	}
+	defer release()
+
+	onError := func(w http.ResponseWriter, err error) {
+		// Клиент сам закрыл соединение - бэкенд не виноват
+		if errors.Is(err, context.Canceled) {
+			setAccessStatus(w, statusClientClosedRequest)
+			proxyLog.Debug("Клиент закрыл соединение", "domain", proxy.ExternalDomain, "path", r.URL.Path)
+			return
+		}
+
+		pool.failure(upstream)
+		maxTries := proxy.Max_tries
+		if maxTries <= 0 {
+			maxTries = len(pool.upstreams)
+		}
+		if canRetry(r) && len(tried) < maxTries && pool.untried(tried) > 0 && r.Context().Err() == nil {
+			proxyLog.Warn("Бэкенд не ответил, повторяем запрос на другом", "domain", proxy.ExternalDomain, "upstream", address, "error", err)
+			release()
+			proxyAttempt(w, r, proxy, route, pool, scheme, tried)
+			return
+		}
+		handleProxyError(w, r, proxy, address, err)
+	}
+
+	// Ссылки на бэкенд в ответе и замены в теле (response_rewrite)
+	rewriter := newResponseRewriter(proxy, route, r, address)
+
+	if isUpgradeRequest(r) {
+		if err := serveProxyUpgrade(w, r, proxy, rewriter, scheme, address); err != nil {
+			onError(w, err)
+			return
+		}
+		pool.success(upstream)
+		return
+	}
+
+	transport, err := proxyTransport(proxy, scheme, address)
+	if err != nil {
+		handleProxyError(w, r, proxy, address, err)
+		return
+	}
+
+	reverseProxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: scheme, Host: address})
+			pr.Out.Host = upstreamHost(proxy, pr.In, address)
+			setForwardHeaders(pr.Out.Header, pr.In)
+			applyHeaderRules(pr.Out.Header, proxy.Request_headers)
+			// Замены в теле возможны только для несжатого ответа
+			if proxy.Response_rewrite != nil && len(proxy.Response_rewrite.Body) > 0 {
+				pr.Out.Header.Del("Accept-Encoding")
+			}
+		},
+		Transport:     transport,
+		FlushInterval: -1, // Сразу отправляем данные клиенту (критично для SSE)
+		ErrorLog:      log.New(proxyLogWriter{proxy.ExternalDomain}, "", 0),
+		ModifyResponse: func(resp *http.Response) error {
+			pool.success(upstream)
+			rewriter.rewrite(resp)
+			applyHeaderRules(resp.Header, proxy.Response_headers)
+			return nil
+		},
+		// ReverseProxy передаёт сюда исходящий запрос - повторяем исходный r
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			onError(w, err)
+		},
+	}
+	reverseProxy.ServeHTTP(w, r)
+}
+
+// handleProxyError отвечает 502 (бэкенд недоступен) или 504 (бэкенд не ответил вовремя)
+func handleProxyError(w http.ResponseWriter, r *http.Request, proxy config.Proxy_Service, address string, err error) {
+	code := http.StatusBadGateway
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		code = http.StatusGatewayTimeout
+	}
+
+	if reason := tlsErrorReason(err); reason != "" {
+		proxyLog.Error("Ошибка TLS-соединения с бэкендом", "domain", proxy.ExternalDomain, "upstream", address, "status", code, "reason", reason, "error", err)
+	} else {
+		proxyLog.Error("Ошибка прокси-запроса", "domain", proxy.ExternalDomain, "upstream", address, "status", code, "error", err)
+	}
+	serveProxyErrorPage(w, r, code, proxy)
+}
+
+// proxyLogWriter направляет сообщения httputil.ReverseProxy (обрыв передачи тела) в logs_proxy.log
+type proxyLogWriter struct {
+	domain string
+}
+
+func (w proxyLogWriter) Write(data []byte) (int, error) {
+	proxyLog.Warn("Ошибка передачи ответа", "domain", w.domain, "error", strings.TrimSpace(string(data)))
+	return len(data), nil
+}
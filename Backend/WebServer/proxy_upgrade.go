@@ -0,0 +1,315 @@
+package webserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Upgrade-соединения через прокси (WebSocket и другие протоколы поверх HTTP/1.1 Upgrade)
+
+const defaultUpgradeIdleTimeout = 5 * time.Minute
+
+// Заголовки соединения (hop-by-hop), которые не передаются дальше, RFC 7230 6.1
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// isUpgradeRequest проверяет Connection: Upgrade и Upgrade: <протокол> (только HTTP/1.x, HTTP/2 не поддерживает Hijack)
+func isUpgradeRequest(r *http.Request) bool {
+	return r.ProtoMajor == 1 && r.Header.Get("Upgrade") != "" && headerHasToken(r.Header, "Connection", "upgrade")
+}
+
+func headerHasToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeHopHeaders удаляет hop-by-hop заголовки и заголовки, перечисленные в Connection
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// serveProxyUpgrade передаёт запрос Upgrade бэкенду и после ответа 101 связывает
+// клиентское соединение с бэкендом напрямую, пока одна из сторон не закроет его или не истечёт простой.
+// Ошибку соединения с бэкендом возвращает до ответа клиенту - запрос можно повторить на другом бэкенде
+func serveProxyUpgrade(w http.ResponseWriter, r *http.Request, proxy config.Proxy_Service, rewriter *responseRewriter, scheme string, address string) error {
+	protocol := r.Header.Get("Upgrade")
+
+	dialCtx, cancel := context.WithTimeout(r.Context(), secondsOr(proxy.Dial_timeout, defaultProxyDialTimeout))
+	backend, err := dialUpstream(dialCtx, proxy, scheme, address)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	// Запрос к бэкенду: как обычный прокси-запрос, но с Connection/Upgrade
+	outReq := r.Clone(r.Context())
+	outReq.URL = &url.URL{Scheme: scheme, Host: address, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	outReq.Host = upstreamHost(proxy, r, address)
+	outReq.RequestURI = ""
+	removeHopHeaders(outReq.Header)
+	outReq.Header.Set("Connection", "Upgrade")
+	outReq.Header.Set("Upgrade", protocol)
+	setForwardHeaders(outReq.Header, r)
+	applyHeaderRules(outReq.Header, proxy.Request_headers)
+
+	backend.SetDeadline(time.Now().Add(secondsOr(proxy.Response_timeout, defaultProxyResponseTimeout)))
+	if err := outReq.Write(backend); err != nil {
+		backend.Close()
+		return err
+	}
+
+	backendReader := bufio.NewReader(backend)
+	resp, err := http.ReadResponse(backendReader, outReq)
+	if err != nil {
+		backend.Close()
+		return err
+	}
+	backend.SetDeadline(time.Time{})
+
+	// Бэкенд не переключил протокол - отдаём его ответ как обычный
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer backend.Close()
+		defer resp.Body.Close()
+		removeHopHeaders(resp.Header)
+		rewriter.rewrite(resp)
+		applyHeaderRules(resp.Header, proxy.Response_headers)
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return nil
+	}
+
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), protocol) {
+		backend.Close()
+		handleProxyError(w, r, proxy, address, errors.New("бэкенд переключился на другой протокол: "+resp.Header.Get("Upgrade")))
+		return nil
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		backend.Close()
+		handleProxyError(w, r, proxy, address, errors.New("соединение клиента не поддерживает hijack"))
+		return nil
+	}
+	client, clientBuffer, err := hijacker.Hijack()
+	if err != nil {
+		backend.Close()
+		proxyLog.Error("Не удалось перехватить соединение клиента", "domain", proxy.ExternalDomain, "error", err)
+		return nil
+	}
+	// Сервер мог оставить таймауты чтения/записи на соединении
+	client.SetDeadline(time.Time{})
+
+	// Ответ 101 клиенту и данные, которые уже успели попасть в буферы
+	applyHeaderRules(resp.Header, proxy.Response_headers)
+	if err := resp.Write(clientBuffer); err == nil {
+		err = flushBuffered(clientBuffer.Writer, backendReader)
+		if err == nil {
+			err = flushBuffered(backend, clientBuffer.Reader)
+		}
+	}
+	if err != nil {
+		client.Close()
+		backend.Close()
+		proxyLog.Warn("Ошибка установки upgrade-соединения", "domain", proxy.ExternalDomain, "error", err)
+		return nil
+	}
+
+	tunnel := &proxyTunnel{client: client, backend: backend}
+	untrack := trackTunnel(r, tunnel)
+	proxyTunnels.Add(1, proxy.ExternalDomain)
+	proxyLog.Debug("Upgrade-соединение установлено", "domain", proxy.ExternalDomain, "upstream", address, "protocol", protocol, "ip", clientIP(r))
+
+	start := time.Now()
+	sent, received, reason := tunnel.run(secondsOr(proxy.Upgrade_idle_timeout, defaultUpgradeIdleTimeout))
+
+	untrack()
+	proxyTunnels.Add(-1, proxy.ExternalDomain)
+	addAccessLogBytes(w, sent)
+	proxyLog.Debug("Upgrade-соединение закрыто", "domain", proxy.ExternalDomain, "protocol", protocol,
+		"duration", time.Since(start).Round(time.Millisecond).String(), "sent", sent, "received", received, "reason", reason)
+	return nil
+}
+
+// dialUpstream открывает соединение с бэкендом (TLS для ServiceHTTPSuse, только HTTP/1.1)
+func dialUpstream(ctx context.Context, proxy config.Proxy_Service, scheme string, address string) (net.Conn, error) {
+	dialer := &net.Dialer{KeepAlive: 30 * time.Second}
+	if scheme != "https" {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+
+	tlsConfig, err := upstreamTLSConfig(proxy)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.NextProtos = []string{"http/1.1"}
+
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+	return tlsDialer.DialContext(ctx, "tcp", address)
+}
+
+// flushBuffered дописывает в dst данные, уже прочитанные в буфер reader
+func flushBuffered(dst io.Writer, reader *bufio.Reader) error {
+	if flusher, ok := dst.(*bufio.Writer); ok {
+		defer flusher.Flush()
+	}
+	if reader.Buffered() == 0 {
+		return nil
+	}
+	data, _ := reader.Peek(reader.Buffered())
+	_, err := dst.Write(data)
+	return err
+}
+
+// proxyTunnel - установленное upgrade-соединение клиент ↔ бэкенд
+type proxyTunnel struct {
+	client       net.Conn
+	backend      net.Conn
+	lastActivity atomic.Int64 // UnixNano последней передачи в любую сторону
+	closeOnce    sync.Once
+	reason       atomic.Value
+	onData       func(toClient bool, n int64) // Учёт переданных байт (Stream_Service)
+}
+
+// run копирует данные в обе стороны и возвращает (байт клиенту, байт от клиента, причина закрытия)
+func (t *proxyTunnel) run(idleTimeout time.Duration) (int64, int64, string) {
+	t.lastActivity.Store(time.Now().UnixNano())
+
+	var sent, received int64
+	done := make(chan struct{}, 2)
+	go func() {
+		received = t.pipe(t.backend, t.client)
+		t.close("client")
+		done <- struct{}{}
+	}()
+	go func() {
+		sent = t.pipe(t.client, t.backend)
+		t.close("backend")
+		done <- struct{}{}
+	}()
+
+	// Простой считается по обеим сторонам: сервер может только слать, клиент - только слушать
+	ticker := time.NewTicker(min(idleTimeout/4+time.Millisecond, 10*time.Second))
+	defer ticker.Stop()
+
+	for finished := 0; finished < 2; {
+		select {
+		case <-done:
+			finished++
+		case <-ticker.C:
+			if time.Since(time.Unix(0, t.lastActivity.Load())) > idleTimeout {
+				t.close("idle")
+			}
+		}
+	}
+
+	reason, _ := t.reason.Load().(string)
+	return sent, received, reason
+}
+
+func (t *proxyTunnel) pipe(dst net.Conn, src net.Conn) int64 {
+	buffer := make([]byte, 32*1024)
+	var total int64
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			t.lastActivity.Store(time.Now().UnixNano())
+			written, writeErr := dst.Write(buffer[:n])
+			total += int64(written)
+			if t.onData != nil {
+				t.onData(dst == t.client, int64(written))
+			}
+			if writeErr != nil {
+				return total
+			}
+		}
+		if err != nil {
+			return total
+		}
+	}
+}
+
+// close закрывает обе стороны; первая причина сохраняется для лога
+func (t *proxyTunnel) close(reason string) {
+	t.closeOnce.Do(func() {
+		t.reason.Store(reason)
+		t.client.Close()
+		t.backend.Close()
+	})
+}
+
+// Активные upgrade-соединения по серверам: Shutdown не закрывает перехваченные соединения сам
+var (
+	tunnelsMu sync.Mutex
+	tunnels   = make(map[*http.Server]map[*proxyTunnel]struct{})
+)
+
+// trackTunnel регистрирует соединение, чтобы закрыть его при остановке сервера, принявшего запрос
+func trackTunnel(r *http.Request, tunnel *proxyTunnel) func() {
+	server, _ := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if server == nil {
+		return func() {}
+	}
+
+	tunnelsMu.Lock()
+	if tunnels[server] == nil {
+		tunnels[server] = make(map[*proxyTunnel]struct{})
+		server.RegisterOnShutdown(func() { closeTunnels(server) })
+	}
+	tunnels[server][tunnel] = struct{}{}
+	tunnelsMu.Unlock()
+
+	return func() {
+		tunnelsMu.Lock()
+		delete(tunnels[server], tunnel)
+		tunnelsMu.Unlock()
+	}
+}
+
+func closeTunnels(server *http.Server) {
+	tunnelsMu.Lock()
+	active := tunnels[server]
+	delete(tunnels, server)
+	tunnelsMu.Unlock()
+
+	for tunnel := range active {
+		tunnel.close("shutdown")
+	}
+}
@@ -0,0 +1,399 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Значения по умолчанию для Stream_Service (0 в конфиге)
+const (
+	defaultStreamConnectTimeout = 10 * time.Second
+	defaultStreamTCPIdleTimeout = time.Hour
+	defaultStreamUDPIdleTimeout = time.Minute
+	streamHandshakeTimeout      = 10 * time.Second
+)
+
+// streamState - счётчики сервиса; переживают перезагрузку конфига, пока сервис в нём есть
+type streamState struct {
+	active   atomic.Int64
+	total    atomic.Int64
+	rejected atomic.Int64
+	received atomic.Int64 // Байт от клиентов
+	sent     atomic.Int64 // Байт клиентам
+
+	tunnelsMu sync.Mutex
+	tunnels   map[*proxyTunnel]struct{}
+}
+
+// streamRuntime - запущенный Stream_Service: его слушатели и настройки, с которыми они открыты
+type streamRuntime struct {
+	config    config.Stream_Service
+	addresses [][2]string
+	listeners []net.Listener
+	packets   []*udpStream
+	state     *streamState
+}
+
+var (
+	streamMutex    sync.Mutex
+	streamRunning  bool
+	streamRuntimes []*streamRuntime // В порядке конфига
+	streamStates   = make(map[string]*streamState)
+	sniStreams     atomic.Bool // Есть сервисы с sni - порты HTTPS читают ClientHello
+)
+
+// StreamState - состояние Stream_Service для админки
+type StreamState struct {
+	Name     string
+	Protocol string
+	Listen   int
+	Upstream string
+	Sni      []string
+	Enable   bool
+	Running  bool
+	Active   int64
+	Total    int64
+	Rejected int64
+	BytesIn  int64
+	BytesOut int64
+	MaxConns int // max_connections (0 = без ограничения)
+}
+
+// GetStreamStatus возвращает статус Stream_Service
+func GetStreamStatus() bool {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	return streamRunning
+}
+
+// GetStreamPorts возвращает порты работающих сервисов ("3306/tcp, 443/sni")
+func GetStreamPorts() string {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	var ports []string
+	for _, runtime := range streamRuntimes {
+		if runtime.config.Listen != 0 {
+			ports = append(ports, strconv.Itoa(runtime.config.Listen)+"/"+streamProtocol(runtime.config))
+		}
+		if len(runtime.config.Sni) > 0 {
+			ports = append(ports, portsString(config.HTTPSPorts())+"/sni")
+		}
+	}
+	if len(ports) == 0 {
+		return "-"
+	}
+	return strings.Join(ports, ", ")
+}
+
+// StartStreams запускает Stream_Service из конфига
+func StartStreams() {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	if streamRunning {
+		return
+	}
+	streamRunning = true
+	applyStreamsLocked()
+}
+
+// StopStreams закрывает порты сервисов и все их соединения
+func StopStreams() {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	if !streamRunning {
+		return
+	}
+	streamRunning = false
+	for _, runtime := range streamRuntimes {
+		runtime.stop(true)
+	}
+	streamRuntimes = nil
+	sniStreams.Store(false)
+	streamLog.Console().Info("Stream-сервисы остановлены")
+}
+
+// ApplyStreams приводит запущенные сервисы к конфигу: изменённые перезапускаются,
+// остальные продолжают работу. Открытые TCP-соединения дорабатывают со старыми настройками
+func ApplyStreams() {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	if streamRunning {
+		applyStreamsLocked()
+	}
+}
+
+func applyStreamsLocked() {
+	streams := config.Current().Stream_Service
+
+	current := make(map[string]*streamRuntime)
+	for _, runtime := range streamRuntimes {
+		current[runtime.config.Name] = runtime
+	}
+
+	var next []*streamRuntime
+	configured := make(map[string]bool)
+	hasSNI := false
+	for _, stream := range streams {
+		configured[stream.Name] = true
+		if !stream.Enable {
+			continue
+		}
+
+		addresses := streamAddresses(stream)
+		runtime := current[stream.Name]
+		delete(current, stream.Name)
+		if runtime != nil && (!reflect.DeepEqual(runtime.config, stream) || !reflect.DeepEqual(runtime.addresses, addresses)) {
+			runtime.stop(false)
+			runtime = nil
+		}
+		if runtime == nil {
+			runtime = startStream(stream, addresses)
+		}
+
+		next = append(next, runtime)
+		hasSNI = hasSNI || len(stream.Sni) > 0
+	}
+
+	// Сервисы, которые выключены или удалены из конфига
+	for name, runtime := range current {
+		runtime.stop(false)
+		streamLog.Console().Info("Stream-сервис остановлен", "name", name)
+	}
+	for name := range streamStates {
+		if !configured[name] {
+			delete(streamStates, name)
+		}
+	}
+
+	streamRuntimes = next
+	sniStreams.Store(hasSNI)
+}
+
+// startStream открывает порты сервиса. Занятый порт пишется в лог, сервис остаётся доступным по sni
+func startStream(stream config.Stream_Service, addresses [][2]string) *streamRuntime {
+	state := streamStates[stream.Name]
+	if state == nil {
+		state = &streamState{tunnels: make(map[*proxyTunnel]struct{})}
+		streamStates[stream.Name] = state
+	}
+	runtime := &streamRuntime{config: stream, addresses: addresses, state: state}
+
+	var opened []string
+	for _, addr := range addresses {
+		if streamProtocol(stream) == "udp" {
+			conn, err := net.ListenPacket(addr[0], addr[1])
+			if err != nil {
+				streamLog.Console().Error("Не удалось открыть адрес", "name", stream.Name, "addr", addr[1], "error", err)
+				continue
+			}
+			packets := newUDPStream(conn, stream, state)
+			runtime.packets = append(runtime.packets, packets)
+			go packets.run()
+			opened = append(opened, conn.LocalAddr().String())
+			continue
+		}
+
+		listener, err := net.Listen(addr[0], addr[1])
+		if err != nil {
+			streamLog.Console().Error("Не удалось открыть адрес", "name", stream.Name, "addr", addr[1], "error", err)
+			continue
+		}
+		runtime.listeners = append(runtime.listeners, listener)
+		go acceptStream(listener, stream, state)
+		opened = append(opened, listener.Addr().String())
+	}
+
+	fields := []interface{}{"name", stream.Name, "protocol", streamProtocol(stream), "upstream", stream.Upstream}
+	if len(opened) > 0 {
+		fields = append(fields, "listen", strings.Join(opened, ", "))
+	}
+	if len(stream.Sni) > 0 {
+		fields = append(fields, "sni", strings.Join(stream.Sni, ","))
+	}
+	streamLog.Console().Info("Stream-сервис запущен", fields...)
+	return runtime
+}
+
+// stop закрывает порты сервиса; closeConns - ещё и все его TCP-соединения (остановка сервера)
+// UDP-сессии закрываются всегда: ответы клиентам идут через закрытый порт
+func (runtime *streamRuntime) stop(closeConns bool) {
+	for _, listener := range runtime.listeners {
+		listener.Close()
+	}
+	for _, packets := range runtime.packets {
+		packets.conn.Close()
+	}
+	if !closeConns {
+		return
+	}
+
+	state := runtime.state
+	state.tunnelsMu.Lock()
+	active := make([]*proxyTunnel, 0, len(state.tunnels))
+	for tunnel := range state.tunnels {
+		active = append(active, tunnel)
+	}
+	state.tunnelsMu.Unlock()
+
+	for _, tunnel := range active {
+		tunnel.close("shutdown")
+	}
+}
+
+// streamAddresses возвращает адреса привязки сервиса (те же listen_address и IPv6, что у HTTP)
+func streamAddresses(stream config.Stream_Service) [][2]string {
+	if stream.Listen == 0 {
+		return nil
+	}
+
+	addresses := config.ListenAddresses(stream.Listen)
+	if streamProtocol(stream) == "udp" {
+		for i := range addresses {
+			addresses[i][0] = strings.Replace(addresses[i][0], "tcp", "udp", 1)
+		}
+	}
+	return addresses
+}
+
+func streamProtocol(stream config.Stream_Service) string {
+	if stream.Protocol == "" {
+		return "tcp"
+	}
+	return stream.Protocol
+}
+
+// acceptStream принимает TCP-соединения до закрытия порта
+func acceptStream(listener net.Listener, stream config.Stream_Service, state *streamState) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+		go serveStreamConn(conn, stream, state)
+	}
+}
+
+// serveStreamConn соединяет клиента с сервисом: лимит соединений, TLS (если tls), затем
+// прямая передача данных в обе стороны до закрытия или простоя
+func serveStreamConn(client net.Conn, stream config.Stream_Service, state *streamState) {
+	ip := client.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	if active := state.active.Add(1); stream.Max_connections > 0 && active > int64(stream.Max_connections) {
+		state.active.Add(-1)
+		state.rejected.Add(1)
+		streamConnections.Inc(stream.Name, "rejected")
+		streamLog.Warn("Превышен лимит соединений, соединение отклонено", "name", stream.Name, "ip", ip, "max_connections", stream.Max_connections)
+		client.Close()
+		return
+	}
+	defer state.active.Add(-1)
+	state.total.Add(1)
+	streamConnections.Inc(stream.Name, "accepted")
+
+	if stream.Tls {
+		tlsConn := tls.Server(client, &tls.Config{GetCertificate: selectCertificate})
+		tlsConn.SetDeadline(time.Now().Add(streamHandshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			streamLog.Debug("Ошибка TLS-рукопожатия с клиентом", "name", stream.Name, "ip", ip, "error", err)
+			client.Close()
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+		client = tlsConn
+	}
+
+	backend, err := net.DialTimeout("tcp", stream.Upstream, secondsOr(stream.Connect_timeout, defaultStreamConnectTimeout))
+	if err != nil {
+		streamLog.Warn("Сервис недоступен", "name", stream.Name, "upstream", stream.Upstream, "ip", ip, "error", err)
+		client.Close()
+		return
+	}
+
+	tunnel := &proxyTunnel{client: client, backend: backend, onData: state.count(stream.Name)}
+	state.tunnelsMu.Lock()
+	state.tunnels[tunnel] = struct{}{}
+	state.tunnelsMu.Unlock()
+	streamLog.Debug("Соединение открыто", "name", stream.Name, "ip", ip, "upstream", stream.Upstream)
+
+	start := time.Now()
+	sent, received, reason := tunnel.run(secondsOr(stream.Idle_timeout, defaultStreamTCPIdleTimeout))
+
+	state.tunnelsMu.Lock()
+	delete(state.tunnels, tunnel)
+	state.tunnelsMu.Unlock()
+	streamLog.Debug("Соединение закрыто", "name", stream.Name, "ip", ip,
+		"duration", time.Since(start).Round(time.Millisecond).String(), "sent", sent, "received", received, "reason", reason)
+}
+
+// count возвращает счётчик переданных байт для статистики и метрик
+func (state *streamState) count(name string) func(toClient bool, n int64) {
+	return func(toClient bool, n int64) {
+		if toClient {
+			state.sent.Add(n)
+			streamBytes.Add(float64(n), name, "out")
+		} else {
+			state.received.Add(n)
+			streamBytes.Add(float64(n), name, "in")
+		}
+	}
+}
+
+// StreamStates возвращает состояние всех Stream_Service из конфига
+func StreamStates() []StreamState {
+	streams := config.Current().Stream_Service
+
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	running := make(map[string]bool)
+	for _, runtime := range streamRuntimes {
+		running[runtime.config.Name] = true
+	}
+
+	states := make([]StreamState, 0, len(streams))
+	for _, stream := range streams {
+		info := StreamState{
+			Name:     stream.Name,
+			Protocol: streamProtocol(stream),
+			Listen:   stream.Listen,
+			Upstream: stream.Upstream,
+			Sni:      stream.Sni,
+			Enable:   stream.Enable,
+			Running:  running[stream.Name],
+			MaxConns: stream.Max_connections,
+		}
+		if state := streamStates[stream.Name]; state != nil {
+			info.Active = state.active.Load()
+			info.Total = state.total.Load()
+			info.Rejected = state.rejected.Load()
+			info.BytesIn = state.received.Load()
+			info.BytesOut = state.sent.Load()
+		}
+		states = append(states, info)
+	}
+	return states
+}
+
+// sniStreamsActive: есть ли работающие сервисы с sni (иначе порты HTTPS не читают ClientHello)
+func sniStreamsActive() bool {
+	return sniStreams.Load()
+}
@@ -0,0 +1,84 @@
+package webserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Сколько ждать ClientHello от клиента на порту HTTPS
+const sniPeekTimeout = 10 * time.Second
+
+var errSNIPeeked = errors.New("sni прочитан")
+
+// peekServerName читает ClientHello и возвращает SNI. Прочитанные байты не теряются:
+// возвращаемое соединение отдаёт их первыми, рукопожатие может провести HTTPS сервер или сервис
+func peekServerName(conn net.Conn) (string, net.Conn, error) {
+	var hello bytes.Buffer
+	var serverName string
+
+	conn.SetReadDeadline(time.Now().Add(sniPeekTimeout))
+	err := tls.Server(readOnlyConn{reader: io.TeeReader(conn, &hello), Conn: conn}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = info.ServerName
+			return nil, errSNIPeeked
+		},
+	}).Handshake()
+	conn.SetReadDeadline(time.Time{})
+
+	peeked := &peekedConn{Conn: conn, reader: io.MultiReader(&hello, conn)}
+	if errors.Is(err, errSNIPeeked) {
+		return strings.ToLower(serverName), peeked, nil
+	}
+	return "", peeked, err
+}
+
+// readOnlyConn отдаёт tls.Server данные клиента и не даёт ему ничего отправить в ответ
+type readOnlyConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c readOnlyConn) Read(p []byte) (int, error)  { return c.reader.Read(p) }
+func (c readOnlyConn) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
+func (c readOnlyConn) Close() error                { return nil }
+
+// peekedConn - соединение, из которого уже прочитан ClientHello
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) { return c.reader.Read(p) }
+
+// sniStream ищет работающий Stream_Service с доменом в sni: сначала точное совпадение, потом wildcard
+func sniStream(serverName string) (config.Stream_Service, *streamState, bool) {
+	if serverName == "" {
+		return config.Stream_Service{}, nil, false
+	}
+
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	var found *streamRuntime
+	for _, runtime := range streamRuntimes {
+		for _, name := range runtime.config.Sni {
+			name = strings.ToLower(name)
+			if name == serverName {
+				return runtime.config, runtime.state, true
+			}
+			if found == nil && strings.Contains(name, "*") && matchWildcardAlias(name, serverName) {
+				found = runtime
+			}
+		}
+	}
+	if found == nil {
+		return config.Stream_Service{}, nil, false
+	}
+	return found.config, found.state, true
+}
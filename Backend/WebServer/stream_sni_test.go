@@ -0,0 +1,134 @@
+package webserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	config "vServer/Backend/config"
+)
+
+// recordingConn запоминает всё, что клиент отправил
+type recordingConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written bytes.Buffer
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.written.Write(p)
+	c.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+func (c *recordingConn) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.written.Bytes())
+}
+
+func TestPeekServerName(t *testing.T) {
+	cases := []struct {
+		name       string
+		serverName string
+		want       string
+	}{
+		{"SNI приводится к нижнему регистру", "Game.Example.COM", "game.example.com"},
+		{"без SNI", "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+			recorder := &recordingConn{Conn: client}
+
+			go func() {
+				defer client.Close()
+				tls.Client(recorder, &tls.Config{ServerName: tc.serverName, InsecureSkipVerify: true}).Handshake()
+			}()
+
+			got, peeked, err := peekServerName(server)
+			if err != nil {
+				t.Fatalf("peekServerName: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("получили %q, ожидали %q", got, tc.want)
+			}
+
+			// ClientHello не теряется: соединение отдаёт его первым
+			hello := recorder.bytes()
+			replayed := make([]byte, len(hello))
+			if _, err := io.ReadFull(peeked, replayed); err != nil {
+				t.Fatalf("чтение ClientHello: %v", err)
+			}
+			if !bytes.Equal(replayed, hello) {
+				t.Error("соединение отдало не те байты, что прислал клиент")
+			}
+		})
+	}
+}
+
+func TestPeekServerNameNotTLS(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+
+	request := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	go func() {
+		client.Write(request)
+		client.Close()
+	}()
+
+	name, peeked, err := peekServerName(server)
+	if err == nil || name != "" {
+		t.Fatalf("ожидали ошибку без имени, получили %q, %v", name, err)
+	}
+	replayed := make([]byte, 5)
+	if _, err := io.ReadFull(peeked, replayed); err != nil || !bytes.Equal(replayed, request[:5]) {
+		t.Errorf("прочитанные байты потеряны: %q, %v", replayed, err)
+	}
+}
+
+func TestSNIStream(t *testing.T) {
+	saved := streamRuntimes
+	defer func() { streamRuntimes = saved }()
+
+	runtime := func(name string, sni ...string) *streamRuntime {
+		return &streamRuntime{config: config.Stream_Service{Name: name, Sni: sni}, state: &streamState{}}
+	}
+	streamRuntimes = []*streamRuntime{
+		runtime("wildcard", "*.example.com"),
+		runtime("exact", "Game.Example.com"),
+		runtime("other", "other.local", "*.other.local"),
+	}
+
+	cases := []struct {
+		serverName string
+		want       string // "" - сервис не найден
+	}{
+		{"game.example.com", "exact"},
+		{"chat.example.com", "wildcard"},
+		{"other.local", "other"},
+		{"a.other.local", "other"},
+		{"example.com", ""},
+		{"unknown.local", ""},
+		{"", ""},
+	}
+
+	for _, tc := range cases {
+		stream, state, ok := sniStream(tc.serverName)
+		if tc.want == "" {
+			if ok {
+				t.Errorf("%q: ожидали без сервиса, получили %s", tc.serverName, stream.Name)
+			}
+			continue
+		}
+		if !ok || stream.Name != tc.want || state == nil {
+			t.Errorf("%q: получили %q (%v), ожидали %q", tc.serverName, stream.Name, ok, tc.want)
+		}
+	}
+}
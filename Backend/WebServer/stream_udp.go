@@ -0,0 +1,220 @@
+package webserver
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	config "vServer/Backend/config"
+)
+
+// Сколько пакетов клиента ждут, пока его сессия подключается к сервису
+const udpDialQueue = 32
+
+// udpStream - порт UDP-сервиса. Каждый адрес клиента получает свою сессию:
+// отдельный сокет к сервису, ответы с которого уходят этому клиенту
+type udpStream struct {
+	conn   net.PacketConn
+	stream config.Stream_Service
+	state  *streamState
+	idle   time.Duration
+	count  func(toClient bool, n int64)
+
+	mu       sync.Mutex
+	sessions map[string]*udpSession
+}
+
+type udpSession struct {
+	client       net.Addr
+	upstream     net.Conn     // nil, пока идёт подключение (под udpStream.mu)
+	queue        [][]byte     // Пакеты, пришедшие во время подключения (под udpStream.mu)
+	lastActivity atomic.Int64 // UnixNano последнего пакета в любую сторону
+	started      time.Time
+}
+
+func newUDPStream(conn net.PacketConn, stream config.Stream_Service, state *streamState) *udpStream {
+	return &udpStream{
+		conn:     conn,
+		stream:   stream,
+		state:    state,
+		idle:     secondsOr(stream.Idle_timeout, defaultStreamUDPIdleTimeout),
+		count:    state.count(stream.Name),
+		sessions: make(map[string]*udpSession),
+	}
+}
+
+// run читает пакеты клиентов до закрытия порта и пересылает их в сессии
+func (u *udpStream) run() {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, addr, err := u.conn.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				u.closeSessions()
+				return
+			}
+			continue
+		}
+
+		u.forward(addr, buffer[:n])
+	}
+}
+
+// forward отправляет пакет клиента в его сессию
+func (u *udpStream) forward(addr net.Addr, packet []byte) {
+	key := addr.String()
+
+	// Второй проход - сессию только что закрыл reply по простою, пакет уходит в новую
+	for attempt := 0; attempt < 2; attempt++ {
+		u.mu.Lock()
+		session, ok := u.sessions[key]
+		if !ok {
+			if session = u.openSession(key, addr); session == nil {
+				u.mu.Unlock()
+				return
+			}
+		}
+		session.lastActivity.Store(time.Now().UnixNano())
+		if session.upstream == nil {
+			// Подключение ещё идёт - пакет отправится после него
+			if len(session.queue) < udpDialQueue {
+				session.queue = append(session.queue, bytes.Clone(packet))
+			}
+			u.mu.Unlock()
+			return
+		}
+		upstream := session.upstream
+		u.mu.Unlock()
+
+		written, err := upstream.Write(packet)
+		if err == nil {
+			u.count(false, int64(written))
+			return
+		}
+		if !errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+// openSession регистрирует сессию нового клиента (под u.mu) и подключает её к сервису в фоне:
+// медленный DNS или connect не задерживает пакеты остальных клиентов
+// nil - превышен max_connections (пакет отбрасывается)
+func (u *udpStream) openSession(key string, addr net.Addr) *udpSession {
+	if u.stream.Max_connections > 0 && u.state.active.Load() >= int64(u.stream.Max_connections) {
+		u.state.rejected.Add(1)
+		streamConnections.Inc(u.stream.Name, "rejected")
+		streamLog.Debug("Превышен лимит клиентов, пакет отброшен", "name", u.stream.Name, "client", key, "max_connections", u.stream.Max_connections)
+		return nil
+	}
+
+	session := &udpSession{client: addr, started: time.Now()}
+	u.sessions[key] = session
+	u.state.active.Add(1)
+	go u.dial(session)
+	return session
+}
+
+// dial подключает сессию к сервису, отправляет накопленные пакеты и запускает приём ответов
+func (u *udpStream) dial(session *udpSession) {
+	key := session.client.String()
+	upstream, err := net.DialTimeout("udp", u.stream.Upstream, secondsOr(u.stream.Connect_timeout, defaultStreamConnectTimeout))
+
+	u.mu.Lock()
+	if u.sessions[key] != session {
+		// Порт закрыли, пока шло подключение - сессию уже сняла closeSessions
+		u.mu.Unlock()
+		if upstream != nil {
+			upstream.Close()
+		}
+		return
+	}
+	if err != nil {
+		delete(u.sessions, key)
+		u.mu.Unlock()
+		u.state.active.Add(-1)
+		streamLog.Warn("Сервис недоступен", "name", u.stream.Name, "upstream", u.stream.Upstream, "client", key, "error", err)
+		return
+	}
+
+	// Очередь отправляется под блокировкой, чтобы новые пакеты клиента не обогнали её
+	session.upstream = upstream
+	for _, packet := range session.queue {
+		if written, err := upstream.Write(packet); err == nil {
+			u.count(false, int64(written))
+		}
+	}
+	session.queue = nil
+	u.mu.Unlock()
+
+	u.state.total.Add(1)
+	streamConnections.Inc(u.stream.Name, "accepted")
+	streamLog.Debug("UDP-сессия открыта", "name", u.stream.Name, "client", key, "upstream", u.stream.Upstream)
+
+	u.reply(session)
+}
+
+// reply пересылает ответы сервиса клиенту, пока сессия не простаивает дольше idle_timeout
+func (u *udpStream) reply(session *udpSession) {
+	reason := "idle"
+	defer func() { u.closeSession(session, reason) }()
+
+	buffer := make([]byte, 64*1024)
+	for {
+		session.upstream.SetReadDeadline(time.Now().Add(u.idle))
+		n, err := session.upstream.Read(buffer)
+		if n > 0 {
+			session.lastActivity.Store(time.Now().UnixNano())
+			if written, err := u.conn.WriteTo(buffer[:n], session.client); err == nil {
+				u.count(true, int64(written))
+			}
+		}
+		if err != nil {
+			// Ответов не было, но клиент мог слать пакеты - простой считается по обеим сторонам
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if time.Since(time.Unix(0, session.lastActivity.Load())) < u.idle {
+					continue
+				}
+				return
+			}
+			reason = "upstream"
+			return
+		}
+	}
+}
+
+func (u *udpStream) closeSession(session *udpSession, reason string) {
+	u.mu.Lock()
+	key := session.client.String()
+	if u.sessions[key] != session {
+		u.mu.Unlock()
+		return
+	}
+	delete(u.sessions, key)
+	upstream := session.upstream
+	u.mu.Unlock()
+
+	if upstream != nil {
+		upstream.Close()
+	}
+	u.state.active.Add(-1)
+	streamLog.Debug("UDP-сессия закрыта", "name", u.stream.Name, "client", key,
+		"duration", time.Since(session.started).Round(time.Millisecond).String(), "reason", reason)
+}
+
+// closeSessions закрывает все сессии после закрытия порта
+func (u *udpStream) closeSessions() {
+	u.mu.Lock()
+	sessions := make([]*udpSession, 0, len(u.sessions))
+	for _, session := range u.sessions {
+		sessions = append(sessions, session)
+	}
+	u.mu.Unlock()
+
+	for _, session := range sessions {
+		u.closeSession(session, "shutdown")
+	}
+}
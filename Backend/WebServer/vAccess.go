@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	config "vServer/Backend/config"
 	tools "vServer/Backend/tools"
 )
 
@@ -134,11 +136,8 @@ func parseVAccessFile(filePath string) (*VAccessConfig, error) {
 func findVAccessFiles(requestPath string, host string) []string {
 	var configFiles []string
 
-	// Базовый путь к сайту (НЕ public_www, а уровень выше)
-	basePath := "WebServer/www/" + host
-
-	// Получаем абсолютный базовый путь
-	absBasePath, err := tools.AbsPath(basePath)
+	// Все vAccess.conf лежат в папке сайта (НЕ public_www и не document_root, а вне отдаваемого дерева)
+	absBasePath, err := tools.AbsPath(config.SiteDir(host))
 	if err != nil {
 		return configFiles
 	}
@@ -168,6 +167,11 @@ func findVAccessFiles(requestPath string, host string) []string {
 	return configFiles
 }
 
+// isVAccessFile - файлы правил не отдаются как статика, даже если лежат в корне документов
+func isVAccessFile(filePath string) bool {
+	return strings.EqualFold(filepath.Base(filePath), "vAccess.conf")
+}
+
 // Проверка соответствия пути правилу
 func matchPath(rulePath, requestPath string) bool {
 	// Если правило заканчивается на /*, проверяем префикс
@@ -331,10 +335,10 @@ func matchExceptions(exceptions []string, requestPath string) bool {
 }
 
 // Универсальная функция проверки правил vAccess
-// Возвращает (разрешён_доступ, страница_ошибки)
-func checkRules(rules []VAccessRule, requestPath string, r *http.Request, checkFileExtensions bool, logPrefix string, logFile string) (bool, string) {
+// Возвращает (разрешён_доступ, страница_ошибки, номер_запретившего_правила или -1)
+func checkRules(rules []VAccessRule, requestPath string, r *http.Request, checkFileExtensions bool) (bool, string, int) {
 	// Проверяем каждое правило
-	for _, rule := range rules {
+	for index, rule := range rules {
 		// Проверяем соответствие путей (если указаны)
 		pathMatched := true // По умолчанию true, если путей нет
 		if len(rule.PathAccess) > 0 {
@@ -388,11 +392,10 @@ func checkRules(rules []VAccessRule, requestPath string, r *http.Request, checkF
 				if errorPage == "" {
 					errorPage = "404"
 				}
-				tools.Logs_file(1, logPrefix, "🚫 Доступ запрещён для "+getClientIP(r)+" к "+requestPath, logFile, false)
-				return false, errorPage
+				return false, errorPage, index
 			}
 			// Все условия Allow выполнены - разрешаем доступ
-			return true, ""
+			return true, "", -1
 
 		case "Disable":
 			// Disable правило: запрещаем если ЛЮБОЕ условие выполнено
@@ -413,8 +416,7 @@ func checkRules(rules []VAccessRule, requestPath string, r *http.Request, checkF
 				if errorPage == "" {
 					errorPage = "404"
 				}
-				tools.Logs_file(1, logPrefix, "🚫 Доступ запрещён для "+getClientIP(r)+" к "+requestPath, logFile, false)
-				return false, errorPage
+				return false, errorPage, index
 			}
 
 		default:
@@ -424,12 +426,32 @@ func checkRules(rules []VAccessRule, requestPath string, r *http.Request, checkF
 	}
 
 	// Все проверки пройдены - разрешаем доступ
-	return true, ""
+	return true, "", -1
+}
+
+// reportVAccessDenial пишет запрет в лог и учитывает в метрике vserver_vaccess_denied_total
+// Правило обозначается номером среди действующих правил файла (с 1) и типом: "2:Disable"
+func reportVAccessDenial(logger *tools.Logger, r *http.Request, requestPath string, site string, configFile string, rules []VAccessRule, index int) {
+	logger.Warn("Доступ запрещён правилом", "ip", getClientIP(r), "path", requestPath, "rule", rules[index].Type)
+
+	file := configFile
+	if workDir, err := filepath.Abs("."); err == nil {
+		if relative, err := filepath.Rel(workDir, configFile); err == nil {
+			file = relative
+		}
+	}
+	vaccessDenied.Inc(site, filepath.ToSlash(file), strconv.Itoa(index+1)+":"+rules[index].Type)
 }
 
 // Основная функция проверки доступа
 // Возвращает (разрешён_доступ, страница_ошибки)
 func CheckVAccess(requestPath string, host string, r *http.Request) (bool, string) {
+	return checkSiteVAccess(requestPath, host, r, true)
+}
+
+// checkSiteVAccess проверяет доступ к пути сайта
+// report = false для служебных проверок (скрытие файлов в autoindex): запрет не пишется в лог и метрики
+func checkSiteVAccess(requestPath string, host string, r *http.Request, report bool) (bool, string) {
 	// Находим все vAccess.conf файлы
 	configFiles := findVAccessFiles(requestPath, host)
 
@@ -442,13 +464,16 @@ func CheckVAccess(requestPath string, host string, r *http.Request) (bool, strin
 	for _, configFile := range configFiles {
 		config, err := parseVAccessFile(configFile)
 		if err != nil {
-			tools.Logs_file(1, "vAccess", "❌ Ошибка парсинга "+configFile+": "+err.Error(), "logs_vaccess.log", false)
+			vaccessLog.Error("Ошибка разбора vAccess.conf", "path", configFile, "error", err)
 			continue
 		}
 
 		// Используем универсальную функцию проверки правил (с проверкой расширений файлов)
-		allowed, errorPage := checkRules(config.Rules, requestPath, r, true, "vAccess", "logs_vaccess.log")
+		allowed, errorPage, index := checkRules(config.Rules, requestPath, r, true)
 		if !allowed {
+			if report {
+				reportVAccessDenial(vaccessLog, r, requestPath, host, configFile, config.Rules, index)
+			}
 			return false, errorPage
 		}
 	}
@@ -458,29 +483,21 @@ func CheckVAccess(requestPath string, host string, r *http.Request) (bool, strin
 }
 
 // Обработка страницы ошибки vAccess
+// "404" - страница 404 сайта, URL - редирект, локальный путь - своя страница со статусом 403
 func HandleVAccessError(w http.ResponseWriter, r *http.Request, errorPage string, host string) {
 	switch {
 	case errorPage == "404":
-		// Стандартная 404 страница
-		errorPagePath, _ := tools.AbsPath("WebServer/tools/error_page/index.html")
-		http.ServeFile(w, r, errorPagePath)
+		serveErrorPage(w, r, http.StatusNotFound, host)
 
 	case strings.HasPrefix(errorPage, "http://") || strings.HasPrefix(errorPage, "https://"):
 		// Внешний сайт - редирект
 		http.Redirect(w, r, errorPage, http.StatusFound)
 
 	default:
-		// Локальный путь от public_www
-		localPath := "WebServer/www/" + host + "/public_www" + errorPage
-		absLocalPath, _ := tools.AbsPath(localPath)
-		if _, err := os.Stat(absLocalPath); err == nil {
-			http.ServeFile(w, r, absLocalPath)
-		} else {
-			// Файл не найден - показываем стандартную 404
-			errorPagePath, _ := tools.AbsPath("WebServer/tools/error_page/index.html")
-			http.ServeFile(w, r, errorPagePath)
-			tools.Logs_file(1, "vAccess", "❌ Страница ошибки не найдена: "+localPath, "logs_vaccess.log", false)
-		}
+		// Локальный путь от корня документов сайта
+		// (если файла нет - будет стандартная страница и запись в logs_error.log)
+		localPath := config.DocumentRoot(host) + errorPage
+		writeErrorPage(w, r, http.StatusForbidden, host, map[string]string{"403": localPath}, "")
 	}
 }
 
@@ -510,31 +527,30 @@ func CheckProxyVAccess(requestPath string, domain string, r *http.Request) (bool
 	// Парсим конфигурационный файл
 	config, err := parseVAccessFile(absConfigPath)
 	if err != nil {
-		tools.Logs_file(1, "vAccess-Proxy", "❌ Ошибка парсинга "+configPath+": "+err.Error(), "logs_vaccess_proxy.log", false)
+		vaccessProxyLog.Error("Ошибка разбора vAccess.conf", "path", configPath, "error", err)
 		return true, "" // При ошибке парсинга разрешаем доступ
 	}
 
 	// Используем универсальную функцию проверки правил (с проверкой расширений файлов)
-	return checkRules(config.Rules, requestPath, r, true, "vAccess-Proxy", "logs_vaccess_proxy.log")
+	allowed, errorPage, index := checkRules(config.Rules, requestPath, r, true)
+	if !allowed {
+		reportVAccessDenial(vaccessProxyLog, r, requestPath, domain, absConfigPath, config.Rules, index)
+	}
+	return allowed, errorPage
 }
 
 // Обработка страницы ошибки vAccess для прокси
-func HandleProxyVAccessError(w http.ResponseWriter, r *http.Request, errorPage string) {
+// "404" - страница 404 прокси, URL - редирект, иначе - страница 403 прокси
+func HandleProxyVAccessError(w http.ResponseWriter, r *http.Request, errorPage string, proxy config.Proxy_Service) {
 	switch {
 	case errorPage == "404":
-		// Стандартная 404 страница
-		w.WriteHeader(http.StatusForbidden)
-		errorPagePath, _ := tools.AbsPath("WebServer/tools/error_page/index.html")
-		http.ServeFile(w, r, errorPagePath)
+		serveProxyErrorPage(w, r, http.StatusNotFound, proxy)
 
 	case strings.HasPrefix(errorPage, "http://") || strings.HasPrefix(errorPage, "https://"):
 		// Внешний сайт - редирект
 		http.Redirect(w, r, errorPage, http.StatusFound)
 
 	default:
-		// Для прокси возвращаем 403 Forbidden
-		w.WriteHeader(http.StatusForbidden)
-		errorPagePath, _ := tools.AbsPath("WebServer/tools/error_page/index.html")
-		http.ServeFile(w, r, errorPagePath)
+		serveProxyErrorPage(w, r, http.StatusForbidden, proxy)
 	}
 }
@@ -31,6 +31,95 @@ class ConfigAPI {
         }
     }
 
+    // Проверить конфигурацию без сохранения (список ошибок {path, message})
+    async validateConfig(configJSON) {
+        if (!this.available) return [];
+        try {
+            return await window.go.admin.App.ValidateConfig(configJSON);
+        } catch (error) {
+            return [{ path: '', message: error.message }];
+        }
+    }
+
+    // Получить историю снимков config.json и vAccess.conf (file = '' - все файлы)
+    async getConfigHistory(file = '') {
+        if (!this.available) return [];
+        try {
+            return await window.go.admin.App.GetConfigHistory(file);
+        } catch (error) {
+            return [];
+        }
+    }
+
+    // Сравнить снимок с другим снимком или с текущим файлом (otherID = '')
+    async diffConfigSnapshot(id, otherID = '') {
+        if (!this.available) return 'Error: API недоступен';
+        try {
+            return await window.go.admin.App.DiffConfigSnapshot(id, otherID);
+        } catch (error) {
+            return `Error: ${error.message}`;
+        }
+    }
+
+    // Восстановить файл из снимка
+    async restoreConfigSnapshot(id) {
+        if (!this.available) return 'Error: API недоступен';
+        try {
+            return await window.go.admin.App.RestoreConfigSnapshot(id);
+        } catch (error) {
+            return `Error: ${error.message}`;
+        }
+    }
+
+    // Получить список файлов логов
+    async getLogFiles() {
+        if (!this.available) return [];
+        try {
+            return await window.go.admin.App.GetLogFiles();
+        } catch (error) {
+            return [];
+        }
+    }
+
+    // Поиск по логу: filter = {level, service, host, query, include_rotated}, страница 1 - новые записи
+    // При ошибке (неверный фильтр, файл не читается) в ответе заполнено поле error
+    async searchLogs(file, filter = {}, page = 1, pageSize = 100) {
+        const empty = (error) => ({ entries: [], total: 0, page, page_size: pageSize, error });
+        if (!this.available) return empty('API недоступен');
+        try {
+            return await window.go.admin.App.SearchLogs(file, JSON.stringify(filter), page, pageSize);
+        } catch (error) {
+            return empty(String(error));
+        }
+    }
+
+    // Включить live-просмотр лога; onEntries(file, entries) вызывается на каждое событие logs:tail
+    async startLogTail(file, filter = {}, onEntries = null) {
+        if (!this.available) return 'Error: API недоступен';
+        if (onEntries && window.runtime?.EventsOn && !this.tailSubscribed) {
+            this.tailSubscribed = true;
+            window.runtime.EventsOn('logs:tail', (event) => {
+                if (this.onLogEntries) this.onLogEntries(event.file, event.entries);
+            });
+        }
+        if (onEntries) this.onLogEntries = onEntries;
+        try {
+            return await window.go.admin.App.StartLogTail(file, JSON.stringify(filter));
+        } catch (error) {
+            return `Error: ${error.message}`;
+        }
+    }
+
+    // Выключить live-просмотр лога (file = '' - всех логов)
+    async stopLogTail(file = '') {
+        if (!this.available) return 'Error: API недоступен';
+        try {
+            return await window.go.admin.App.StopLogTail(file);
+        } catch (error) {
+            return `Error: ${error.message}`;
+        }
+    }
+
     // Включить Proxy Service
     async enableProxyService() {
         if (!this.available) return;
@@ -67,12 +156,13 @@ class ConfigAPI {
         }
     }
 
-    // Перезапустить все сервисы
+    // Перезапустить все сервисы (строка "Error: ..." - конфиг не загрузился, сервисы не перезапускались)
     async restartAllServices() {
-        if (!this.available) return;
+        if (!this.available) return 'Error: API недоступен';
         try {
-            await window.go.admin.App.RestartAllServices();
+            return await window.go.admin.App.RestartAllServices();
         } catch (error) {
+            return `Error: ${error.message}`;
         }
     }
 
@@ -111,6 +201,24 @@ class ConfigAPI {
         } catch (error) {
         }
     }
+
+    // Запустить Stream Service (TCP/UDP)
+    async startStreamService() {
+        if (!this.available) return;
+        try {
+            await window.go.admin.App.StartStreamService();
+        } catch (error) {
+        }
+    }
+
+    // Остановить Stream Service (TCP/UDP)
+    async stopStreamService() {
+        if (!this.available) return;
+        try {
+            await window.go.admin.App.StopStreamService();
+        } catch (error) {
+        }
+    }
 }
 
 // Экспортируем единственный экземпляр
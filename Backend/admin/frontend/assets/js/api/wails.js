@@ -49,6 +49,22 @@ class WailsAPI {
         }
     }
 
+    // Получить Stream_Service со статистикой: [{name, protocol, listen, upstream, running, active, total, rejected, bytes_in, bytes_out, ...}]
+    async getStreamList() {
+        if (!this.checkAvailability()) return [];
+        try {
+            return await window.go.admin.App.GetStreamList();
+        } catch (error) {
+            return [];
+        }
+    }
+
+    // Подписаться на смену состояния бэкендов прокси: callback({domain, address, status, latency_ms, last_error, ...})
+    onProxyHealth(callback) {
+        if (!window.runtime?.EventsOn) return;
+        window.runtime.EventsOn('proxy:health', callback);
+    }
+
     // Получить правила vAccess
     async getVAccessRules(host, isProxy) {
         if (!this.checkAvailability()) return { rules: [] };
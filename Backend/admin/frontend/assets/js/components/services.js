@@ -35,6 +35,11 @@ export class ServicesManager {
                 notification.error('vServer уже запущен!<br><br>Закройте другой экземпляр перед запуском нового.', 5000);
                 this.setServerStatus(false, 'Уже запущен в другом процессе');
             });
+
+            window.runtime.EventsOn('server:config_error', () => {
+                notification.error('config.json содержит ошибки, сервисы не запущены.<br><br>Подробности в logs_config.log', 8000);
+                this.setServerStatus(false, 'Ошибка в config.json');
+            });
         }
     }
 
@@ -118,7 +123,7 @@ export class ServicesManager {
 
     // Отрисовать статусы сервисов
     renderServices(data) {
-        const services = [data.http, data.https, data.mysql, data.php, data.proxy];
+        const services = [data.http, data.https, data.mysql, data.php, data.proxy, data.stream];
         const cards = $$('.service-card');
 
         services.forEach((service, index) => {
@@ -144,6 +149,13 @@ export class ServicesManager {
                 if (infoValues[0] && service.info) {
                     infoValues[0].textContent = service.info;
                 }
+            } else if (service.name === 'Stream') {
+                if (infoValues[0]) {
+                    infoValues[0].textContent = service.port;
+                }
+                if (infoValues[1] && service.info) {
+                    infoValues[1].textContent = service.info;
+                }
             } else {
                 if (infoValues[0]) {
                     infoValues[0].textContent = service.port;
@@ -166,7 +178,8 @@ export class ServicesManager {
                 https: { name: 'HTTPS', status: true, port: '443' },
                 mysql: { name: 'MySQL', status: true, port: '3306' },
                 php: { name: 'PHP', status: true, port: '8000-8003' },
-                proxy: { name: 'Proxy', status: true, port: '', info: '1 из 3' }
+                proxy: { name: 'Proxy', status: true, port: '', info: '1 из 3' },
+                stream: { name: 'Stream', status: true, port: '3306/tcp, 443/sni', info: '2 из 2, соединений: 1' }
             };
             this.renderServices(mockData);
         }
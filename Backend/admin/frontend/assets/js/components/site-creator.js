@@ -64,6 +64,7 @@ export class SiteCreator {
         $('newSiteHost').value = '';
         $('newSiteAliasInput').value = '';
         $('newSiteRootFile').value = 'index.html';
+        $('newSiteDocumentRoot').value = '';
         $('newSiteStatus').value = 'active';
         $('newSiteRouting').checked = true;
         $('certMode').value = 'none';
@@ -307,6 +308,7 @@ export class SiteCreator {
                 status: $('newSiteStatus').value,
                 root_file: $('newSiteRootFile').value,
                 root_file_routing: $('newSiteRouting').checked,
+                document_root: $('newSiteDocumentRoot').value.trim(),
                 AutoCreateSSL: certMode === 'auto'
             };
 
@@ -294,7 +294,11 @@ class App {
             }
 
             saveBtn.querySelector('span').textContent = 'Перезапуск сервисов...';
-            await configAPI.restartAllServices();
+            const restartResult = await configAPI.restartAllServices();
+            if (restartResult.startsWith('Error')) {
+                notification.error(restartResult);
+                return;
+            }
 
             notification.success('Настройки сохранены и сервисы перезапущены!', 1500);
         } catch (error) {
@@ -336,6 +340,9 @@ class App {
             if (editRootFile) editRootFile.value = site.root_file;
             if (editRouting) editRouting.checked = site.root_file_routing;
 
+            const editDocumentRoot = $('editDocumentRoot');
+            if (editDocumentRoot) editDocumentRoot.value = site.document_root || '';
+
             const editAutoCreateSSL = $('editAutoCreateSSL');
             if (editAutoCreateSSL) editAutoCreateSSL.checked = site.auto_create_ssl || false;
 
@@ -467,15 +474,20 @@ class App {
         const statusBtn = document.querySelector('.status-btn.active');
 
         const config = await configAPI.getConfig();
+        const documentRoot = $('editDocumentRoot')?.value.trim() || '';
+        // Поля, которых нет в форме (listen, error_pages и т.п.), сохраняем как есть
         config.Site_www[index] = {
+            ...config.Site_www[index],
             name: $('editName').value,
             host: $('editHost').value,
             alias: aliases,
             status: statusBtn ? statusBtn.dataset.value : 'active',
             root_file: $('editRootFile').value,
             root_file_routing: $('editRouting').checked,
-            AutoCreateSSL: $('editAutoCreateSSL')?.checked || false
+            AutoCreateSSL: $('editAutoCreateSSL')?.checked || false,
+            document_root: documentRoot
         };
+        if (!documentRoot) delete config.Site_www[index].document_root;
 
         const result = await configAPI.saveConfig(JSON.stringify(config, null, 4));
         if (result.startsWith('Error')) {
@@ -496,7 +508,9 @@ class App {
         const isEnabled = statusBtn && statusBtn.dataset.value === 'enable';
 
         const config = await configAPI.getConfig();
+        // Поля, которых нет в форме (listen, error_pages и т.п.), сохраняем как есть
         config.Proxy_Service[index] = {
+            ...config.Proxy_Service[index],
             Enable: isEnabled,
             ExternalDomain: $('editDomain').value,
             LocalAddress: $('editLocalAddr').value,
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
 	"time"
 
@@ -12,11 +11,14 @@ import (
 
 	webserver "vServer/Backend/WebServer"
 	"vServer/Backend/WebServer/acme"
+	"vServer/Backend/admin/go/logs"
 	"vServer/Backend/admin/go/proxy"
 	"vServer/Backend/admin/go/services"
 	"vServer/Backend/admin/go/sites"
 	"vServer/Backend/admin/go/vaccess"
+	"vServer/Backend/daemon"
 	config "vServer/Backend/config"
+	"vServer/Backend/metrics"
 	tools "vServer/Backend/tools"
 )
 
@@ -48,54 +50,14 @@ func (a *App) Startup(ctx context.Context) {
 
 	isSingleInstance = true
 
-	// Инициализируем время запуска
-	tools.ServerUptime("start")
+	// Смена состояния бэкендов прокси (проверки и ошибки запросов) - сразу в интерфейс
+	webserver.SetUpstreamStateHandler(func(state webserver.UpstreamState) {
+		runtime.EventsEmit(appContext, "proxy:health", proxy.NewUpstreamInfo(state))
+	})
 
-	// Загружаем конфигурацию
-	config.LoadConfig()
-	time.Sleep(50 * time.Millisecond)
-
-	// Запускаем handler
-	webserver.StartHandler()
-	time.Sleep(50 * time.Millisecond)
-
-	// Загружаем сертификаты
-	webserver.Cert_start()
-	time.Sleep(50 * time.Millisecond)
-
-	// Инициализируем ACME менеджер (true = production, false = staging)
-	if err := acme.Init(true); err != nil {
-		tools.Logs_file(1, "ACME", "❌ Ошибка инициализации ACME: "+err.Error(), "logs_acme.log", true)
-	} else {
-		// Запускаем фоновую проверку сертификатов каждые 24 часа
-		acme.StartBackgroundRenewal(24 * time.Hour)
-	}
-	time.Sleep(50 * time.Millisecond)
-
-	// Запускаем серверы
-	go webserver.StartHTTPS()
-	time.Sleep(50 * time.Millisecond)
-
-	go webserver.StartHTTP()
-	time.Sleep(50 * time.Millisecond)
-
-	// Запускаем PHP
-	webserver.PHP_Start()
-	time.Sleep(50 * time.Millisecond)
-
-	// Запускаем MySQL асинхронно
-	go webserver.StartMySQLServer(false)
-
-	// Автоматическое получение SSL сертификатов для доменов с AutoCreateSSL=true
-	if config.ConfigData.Soft_Settings.ACME_enabled {
-		go func() {
-			time.Sleep(2 * time.Second) // Ждём пока HTTP сервер полностью запустится
-			results := acme.ObtainAllCertificates()
-			if len(results) > 0 {
-				// Перезагружаем сертификаты после получения
-				webserver.ReloadCertificates()
-			}
-		}()
+	// Запускаем весь стек сервисов (общий с headless-режимом)
+	if err := daemon.Start(); err != nil {
+		runtime.EventsEmit(ctx, "server:config_error", err.Error())
 	}
 
 	// Запускаем мониторинг статусов
@@ -114,14 +76,15 @@ func (a *App) CheckServicesReady() bool {
 func (a *App) Shutdown(ctx context.Context) {
 	// Останавливаем все сервисы при закрытии приложения
 	if isSingleInstance {
-		webserver.StopHTTPServer()
-		webserver.StopHTTPSServer()
-		webserver.PHP_Stop()
-		webserver.StopMySQLServer()
+		daemon.Stop()
 
 		// Освобождаем мьютекс
 		tools.ReleaseMutex()
 	}
+
+	// Останавливаем live-просмотр логов и дописываем буферизованные логи на диск
+	logs.StopTail("")
+	tools.FlushLogs()
 }
 
 func (a *App) monitorServices() {
@@ -142,37 +105,57 @@ func (a *App) GetProxyList() []proxy.ProxyInfo {
 	return proxy.GetProxyList()
 }
 
+func (a *App) GetStreamList() []services.StreamInfo {
+	return services.GetStreamList()
+}
+
 func (a *App) StartServer() string {
 	webserver.Cert_start()
 
 	go webserver.StartHTTPS()
 	go webserver.StartHTTP()
+	webserver.StartStreams()
 
 	webserver.PHP_Start()
 	go webserver.StartMySQLServer(false)
+	webserver.StartHealthChecks()
+	metrics.Start(config.Current().Soft_Settings.Metrics_listen)
 
 	return "Server started"
 }
 
 func (a *App) StopServer() string {
-	webserver.StopHTTPServer()
-	webserver.StopHTTPSServer()
-	webserver.PHP_Stop()
-	webserver.StopMySQLServer()
+	daemon.Stop()
 
 	return "Server stopped"
 }
 
 func (a *App) ReloadConfig() string {
-	config.LoadConfig()
+	if _, err := daemon.Reload(); err != nil {
+		return "Error: " + err.Error()
+	}
 	return "Config reloaded"
 }
 
 func (a *App) GetConfig() interface{} {
-	return config.ConfigData
+	return *config.Current()
+}
+
+// ValidateConfig проверяет конфигурацию без сохранения и возвращает список ошибок с путями полей
+func (a *App) ValidateConfig(configJSON string) []config.ValidationError {
+	errs := config.Validate([]byte(configJSON))
+	if errs == nil {
+		return []config.ValidationError{}
+	}
+	return errs
 }
 
 func (a *App) SaveConfig(configJSON string) string {
+	// Проверяем схему - сломанная конфигурация не должна попасть на диск
+	if errs := config.Validate([]byte(configJSON)); len(errs) > 0 {
+		return "Error: " + errs.Error()
+	}
+
 	// Форматируем JSON перед сохранением
 	var tempConfig interface{}
 	err := json.Unmarshal([]byte(configJSON), &tempConfig)
@@ -185,29 +168,116 @@ func (a *App) SaveConfig(configJSON string) string {
 		return "Error: " + err.Error()
 	}
 
-	// Сохранение конфига в файл
-	err = os.WriteFile(config.ConfigPath, formattedJSON, 0644)
+	// Сохранение конфига в файл (атомарно, со снимком в истории)
+	err = config.SaveFile(config.ConfigPath, formattedJSON)
 	if err != nil {
 		return "Error: " + err.Error()
 	}
 
-	// Перезагружаем конфигурацию
-	config.LoadConfig()
+	// Применяем изменения конфигурации
+	if _, err := daemon.Reload(); err != nil {
+		return "Error: " + err.Error()
+	}
 	return "Config saved"
 }
 
+// GetLogFiles возвращает файлы из WebServer/tools/logs
+func (a *App) GetLogFiles() []logs.LogFile {
+	files, err := logs.GetLogFiles()
+	if err != nil {
+		return []logs.LogFile{}
+	}
+	return files
+}
+
+// SearchLogs ищет по файлу лога с фильтром (JSON LogFilter) и постраничным выводом, новые записи первыми
+func (a *App) SearchLogs(file string, filterJSON string, page int, pageSize int) logs.LogPage {
+	filter, err := logs.ParseFilter(filterJSON)
+	if err != nil {
+		return logs.LogPage{Entries: []logs.LogEntry{}, Error: err.Error()}
+	}
+
+	result, err := logs.Search(file, filter, page, pageSize)
+	if err != nil {
+		return logs.LogPage{Entries: []logs.LogEntry{}, Error: err.Error()}
+	}
+	return result
+}
+
+// StartLogTail включает live-просмотр файла: новые записи приходят событием "logs:tail"
+func (a *App) StartLogTail(file string, filterJSON string) string {
+	filter, err := logs.ParseFilter(filterJSON)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+
+	err = logs.StartTail(file, filter, func(entries []logs.LogEntry) {
+		runtime.EventsEmit(appContext, "logs:tail", map[string]interface{}{
+			"file":    file,
+			"entries": entries,
+		})
+	})
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return "Log tail started"
+}
+
+// StopLogTail выключает live-просмотр файла (file = "" - всех файлов)
+func (a *App) StopLogTail(file string) string {
+	logs.StopTail(file)
+	return "Log tail stopped"
+}
+
+// GetLogTails возвращает файлы с активным live-просмотром
+func (a *App) GetLogTails() []string {
+	return logs.ActiveTails()
+}
+
+// GetConfigHistory возвращает снимки config.json и vAccess.conf (file = "" - все файлы)
+func (a *App) GetConfigHistory(file string) []config.HistoryEntry {
+	entries, err := config.History(file)
+	if err != nil {
+		return []config.HistoryEntry{}
+	}
+	return entries
+}
+
+// DiffConfigSnapshot сравнивает снимок с другим снимком или с текущим файлом (otherID = "")
+func (a *App) DiffConfigSnapshot(id string, otherID string) string {
+	diff, err := config.HistoryDiff(id, otherID)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return diff
+}
+
+// RestoreConfigSnapshot возвращает файл к состоянию снимка и применяет изменения
+func (a *App) RestoreConfigSnapshot(id string) string {
+	restored, err := config.RestoreSnapshot(id)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+
+	if config.IsConfigPath(restored) {
+		if _, err := daemon.Reload(); err != nil {
+			return "Error: " + err.Error()
+		}
+	}
+	return "Snapshot restored"
+}
+
 func (a *App) RestartAllServices() string {
-	// Останавливаем все сервисы
-	webserver.StopHTTPServer()
-	webserver.StopHTTPSServer()
+	// Сначала перезагружаем конфиг: с ошибочным config.json сервисы продолжают работать на старом
+	if err := config.LoadConfig(); err != nil {
+		return "Error: " + err.Error()
+	}
+
+	// Останавливаем PHP и MySQL (HTTP/HTTPS перезапускаются без закрытия портов)
 	webserver.PHP_Stop()
 	webserver.StopMySQLServer()
 	time.Sleep(500 * time.Millisecond)
 
-	// Перезагружаем конфиг
-	config.LoadConfig()
-	time.Sleep(200 * time.Millisecond)
-
 	// Обновляем кэш статусов сайтов
 	webserver.UpdateSiteStatusCache()
 
@@ -215,12 +285,10 @@ func (a *App) RestartAllServices() string {
 	webserver.Cert_start()
 	time.Sleep(50 * time.Millisecond)
 
-	// Запускаем серверы заново
-	go webserver.StartHTTPS()
-	time.Sleep(50 * time.Millisecond)
-
-	go webserver.StartHTTP()
-	time.Sleep(50 * time.Millisecond)
+	// Передаём сокеты новым серверам - активные загрузки и websocket не обрываются
+	webserver.RestartHTTPSServer()
+	webserver.RestartHTTPServer()
+	webserver.ApplyStreams()
 
 	webserver.PHP_Start()
 	time.Sleep(200 * time.Millisecond)
@@ -265,6 +333,16 @@ func (a *App) StopMySQLService() string {
 	return "MySQL stopped"
 }
 
+func (a *App) StartStreamService() string {
+	webserver.StartStreams()
+	return "Stream started"
+}
+
+func (a *App) StopStreamService() string {
+	webserver.StopStreams()
+	return "Stream stopped"
+}
+
 func (a *App) StartPHPService() string {
 	webserver.PHP_Start()
 	return "PHP started"
@@ -276,41 +354,49 @@ func (a *App) StopPHPService() string {
 }
 
 func (a *App) EnableProxyService() string {
-	config.ConfigData.Soft_Settings.Proxy_enabled = true
-
 	// Сохраняем в файл
-	configJSON, _ := json.Marshal(config.ConfigData)
-	os.WriteFile(config.ConfigPath, configJSON, 0644)
+	err := config.Update(func(cfg *config.Config) {
+		cfg.Soft_Settings.Proxy_enabled = true
+	})
+	if err != nil {
+		return "Error: " + err.Error()
+	}
 
 	return "Proxy enabled"
 }
 
 func (a *App) DisableProxyService() string {
-	config.ConfigData.Soft_Settings.Proxy_enabled = false
-
 	// Сохраняем в файл
-	configJSON, _ := json.Marshal(config.ConfigData)
-	os.WriteFile(config.ConfigPath, configJSON, 0644)
+	err := config.Update(func(cfg *config.Config) {
+		cfg.Soft_Settings.Proxy_enabled = false
+	})
+	if err != nil {
+		return "Error: " + err.Error()
+	}
 
 	return "Proxy disabled"
 }
 
 func (a *App) EnableACMEService() string {
-	config.ConfigData.Soft_Settings.ACME_enabled = true
-
 	// Сохраняем в файл
-	configJSON, _ := json.MarshalIndent(config.ConfigData, "", "    ")
-	os.WriteFile(config.ConfigPath, configJSON, 0644)
+	err := config.Update(func(cfg *config.Config) {
+		cfg.Soft_Settings.ACME_enabled = true
+	})
+	if err != nil {
+		return "Error: " + err.Error()
+	}
 
 	return "ACME enabled"
 }
 
 func (a *App) DisableACMEService() string {
-	config.ConfigData.Soft_Settings.ACME_enabled = false
-
 	// Сохраняем в файл
-	configJSON, _ := json.MarshalIndent(config.ConfigData, "", "    ")
-	os.WriteFile(config.ConfigPath, configJSON, 0644)
+	err := config.Update(func(cfg *config.Config) {
+		cfg.Soft_Settings.ACME_enabled = false
+	})
+	if err != nil {
+		return "Error: " + err.Error()
+	}
 
 	return "ACME disabled"
 }
@@ -374,7 +460,9 @@ func (a *App) CreateNewSite(siteJSON string) string {
 		return "Error: " + err.Error()
 	}
 
-	config.LoadConfig()
+	if _, err := daemon.Reload(); err != nil {
+		return "Error: " + err.Error()
+	}
 	return "Site created successfully"
 }
 
@@ -404,7 +492,9 @@ func (a *App) DeleteSite(host string) string {
 		return "Error: " + err.Error()
 	}
 
-	config.LoadConfig()
+	if _, err := daemon.Reload(); err != nil {
+		return "Error: " + err.Error()
+	}
 	return "Site deleted successfully"
 }
 
@@ -0,0 +1,487 @@
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	tools "vServer/Backend/tools"
+)
+
+const (
+	defaultPageSize  = 100
+	maxPageSize      = 1000
+	tailPollInterval = 500 * time.Millisecond
+	tailBatchSize    = 500 // Записей в одном событии
+	maxLineSize      = 1024 * 1024
+)
+
+// Строка логгера tools: 2006-01-02 15:04:05 [-INFOS-] [HTTP] Сообщение key=value
+var textLineRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) \[([-A-Z]+)\] \[([^\]]+)\] (.*)$`)
+
+// Поле key=value или key="значение с пробелами"
+var textFieldRe = regexp.MustCompile(`(?:^| )(host|domain)=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// Combined access-лог: ip - - [время] "GET /uri HTTP/1.1" 200 ...
+var combinedLineRe = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d{3}) `)
+
+var textLevels = map[string]string{
+	"-DEBUG-": "debug",
+	"-INFOS-": "info",
+	"WARNING": "warn",
+	"-ERROR-": "error",
+}
+
+// GetLogFiles возвращает файлы логов с количеством старых сегментов
+func GetLogFiles() ([]LogFile, error) {
+	entries, err := os.ReadDir(tools.LogsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []LogFile{}, nil
+		}
+		return nil, err
+	}
+
+	files := []LogFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") || isSegment(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, LogFile{
+			Name:     entry.Name(),
+			Size:     info.Size(),
+			ModTime:  info.ModTime().Format("2006-01-02 15:04:05"),
+			Segments: len(tools.LogSegments(entry.Name())),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// Сегмент ротации: logs_http-20261018-150405.log
+var segmentNameRe = regexp.MustCompile(`-\d{8}-\d{6}(\.\d+)?\.log$`)
+
+// isSegment отличает несжатые сегменты ротации от основных файлов
+func isSegment(name string) bool {
+	return segmentNameRe.MatchString(name)
+}
+
+// logPath проверяет имя файла: только файлы .log из папки логов, без путей
+func logPath(file string) (string, error) {
+	if file == "" || file != filepath.Base(file) || strings.Contains(file, "..") || !strings.HasSuffix(file, ".log") {
+		return "", fmt.Errorf("недопустимое имя файла лога '%s'", file)
+	}
+	return filepath.Join(tools.LogsDir, file), nil
+}
+
+// ParseFilter разбирает фильтр из JSON (пустая строка - без фильтра)
+func ParseFilter(filterJSON string) (LogFilter, error) {
+	var filter LogFilter
+	if strings.TrimSpace(filterJSON) == "" {
+		return filter, nil
+	}
+	if err := json.Unmarshal([]byte(filterJSON), &filter); err != nil {
+		return filter, fmt.Errorf("некорректный фильтр: %v", err)
+	}
+	if _, err := tools.ParseLogLevel(filter.Level); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// Search ищет записи в файле лога; результаты отсортированы от новых к старым
+func Search(file string, filter LogFilter, page int, pageSize int) (LogPage, error) {
+	path, err := logPath(file)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	if page > math.MaxInt32/pageSize {
+		page = math.MaxInt32 / pageSize
+	}
+
+	// Старые сегменты (от старых к новым), затем текущий файл
+	var sources []string
+	if filter.IncludeRotated {
+		segments := tools.LogSegments(file)
+		sort.Slice(segments, func(i, j int) bool { return segments[i].ModTime().Before(segments[j].ModTime()) })
+		for _, segment := range segments {
+			sources = append(sources, filepath.Join(tools.LogsDir, segment.Name()))
+		}
+	}
+	sources = append(sources, path)
+
+	// Храним только последние page*pageSize совпадений: старше нужной страницы записи не нужны
+	matcher := newMatcher(filter)
+	ring := newEntryRing(page * pageSize)
+	total := 0
+	for _, source := range sources {
+		err := readLines(source, func(line string) {
+			if entry, ok := matcher.match(file, line); ok {
+				ring.push(entry)
+				total++
+			}
+		})
+		if err != nil && !(source == path && os.IsNotExist(err)) {
+			return LogPage{}, err
+		}
+	}
+
+	result := LogPage{Entries: []LogEntry{}, Total: total, Page: page, PageSize: pageSize}
+
+	// Страница 1 - самые свежие записи; в кольце они в конце, нужная страница - в его начале
+	newest := ring.entries()
+	end := len(newest) - (page-1)*pageSize
+	for i := end - 1; i >= 0 && i >= end-pageSize; i-- {
+		result.Entries = append(result.Entries, newest[i])
+	}
+	return result, nil
+}
+
+// entryRing - кольцевой буфер последних size записей
+type entryRing struct {
+	buf   []LogEntry
+	size  int
+	start int // Самая старая запись, когда буфер заполнен
+}
+
+func newEntryRing(size int) *entryRing {
+	return &entryRing{size: size}
+}
+
+func (r *entryRing) push(entry LogEntry) {
+	if len(r.buf) < r.size {
+		r.buf = append(r.buf, entry)
+		return
+	}
+	r.buf[r.start] = entry
+	r.start = (r.start + 1) % r.size
+}
+
+// entries возвращает записи от старых к новым
+func (r *entryRing) entries() []LogEntry {
+	ordered := make([]LogEntry, 0, len(r.buf))
+	ordered = append(ordered, r.buf[r.start:]...)
+	return append(ordered, r.buf[:r.start]...)
+}
+
+// readLines читает файл построчно, .gz распаковывается на лету
+func readLines(path string, handle func(line string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			handle(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// Подготовленный фильтр
+type matcher struct {
+	minLevel tools.LogLevel
+	service  string
+	host     string
+	query    string
+}
+
+func newMatcher(filter LogFilter) matcher {
+	level, _ := tools.ParseLogLevel(filter.Level)
+	if strings.TrimSpace(filter.Level) == "" {
+		level = tools.LevelDebug
+	}
+	return matcher{
+		minLevel: level,
+		service:  strings.ToLower(strings.TrimSpace(filter.Service)),
+		host:     strings.ToLower(strings.TrimSpace(filter.Host)),
+		query:    strings.ToLower(filter.Query),
+	}
+}
+
+func (m matcher) match(file string, line string) (LogEntry, bool) {
+	if m.query != "" && !strings.Contains(strings.ToLower(line), m.query) {
+		return LogEntry{}, false
+	}
+
+	entry := ParseLine(line)
+	entry.File = file
+
+	if level, _ := tools.ParseLogLevel(entry.Level); level < m.minLevel {
+		return LogEntry{}, false
+	}
+	if m.service != "" && entry.Service != m.service {
+		return LogEntry{}, false
+	}
+	if m.host != "" && !strings.Contains(strings.ToLower(entry.Host), m.host) {
+		return LogEntry{}, false
+	}
+	return entry, true
+}
+
+// ParseLine разбирает строку любого из форматов логов: text/json логгера и access-лога (combined/json)
+// Нераспознанная строка возвращается как info без подсистемы
+func ParseLine(line string) LogEntry {
+	entry := LogEntry{Level: "info", Message: line, Raw: line}
+
+	if strings.HasPrefix(line, "{") {
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(line), &fields) == nil {
+			parseJSONLine(&entry, fields)
+			return entry
+		}
+	}
+
+	if match := textLineRe.FindStringSubmatch(line); match != nil {
+		entry.Time = match[1]
+		if level, ok := textLevels[match[2]]; ok {
+			entry.Level = level
+		}
+		entry.Service = strings.ToLower(match[3])
+		entry.Message = match[4]
+		entry.Host = textHost(match[4])
+		return entry
+	}
+
+	if match := combinedLineRe.FindStringSubmatch(line); match != nil {
+		if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", match[2]); err == nil {
+			entry.Time = t.Local().Format("2006-01-02 15:04:05")
+		}
+		entry.Service = "access"
+		entry.Level = statusLevel(match[4])
+		entry.Message = match[3] + " " + match[4]
+	}
+	return entry
+}
+
+func parseJSONLine(entry *LogEntry, fields map[string]interface{}) {
+	entry.Time = jsonTime(fields["time"])
+	entry.Host = jsonString(fields["host"])
+	if entry.Host == "" {
+		entry.Host = jsonString(fields["domain"])
+	}
+
+	// Строка access_json.log
+	if status, ok := fields["status"].(float64); ok && fields["uri"] != nil {
+		entry.Service = "access"
+		entry.Level = statusLevel(fmt.Sprint(int(status)))
+		entry.Message = fmt.Sprintf("%s %s %s %d", jsonString(fields["method"]), jsonString(fields["uri"]), jsonString(fields["proto"]), int(status))
+		return
+	}
+
+	entry.Service = jsonString(fields["subsystem"])
+	entry.Message = jsonString(fields["msg"])
+	if level, err := tools.ParseLogLevel(jsonString(fields["level"])); err == nil {
+		entry.Level = level.String()
+	}
+}
+
+// statusLevel: 5xx - error, 4xx - warn, остальное - info
+func statusLevel(status string) string {
+	switch {
+	case strings.HasPrefix(status, "5"):
+		return "error"
+	case strings.HasPrefix(status, "4"):
+		return "warn"
+	}
+	return "info"
+}
+
+func textHost(message string) string {
+	match := textFieldRe.FindStringSubmatch(message)
+	if match == nil {
+		return ""
+	}
+	value := match[2]
+	if strings.HasPrefix(value, `"`) {
+		var unquoted string
+		if json.Unmarshal([]byte(value), &unquoted) == nil {
+			return unquoted
+		}
+	}
+	return value
+}
+
+func jsonString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if text, ok := value.(string); ok {
+		return text
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonTime приводит RFC3339 к формату текстовых логов
+func jsonTime(value interface{}) string {
+	text := jsonString(value)
+	if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	return text
+}
+
+// Активные live-подписки: файл → остановка слежения
+var (
+	tailsMu sync.Mutex
+	tails   = make(map[string]chan struct{})
+)
+
+// StartTail следит за файлом и передаёт новые записи, прошедшие фильтр, в emit
+// Повторный вызов для того же файла заменяет фильтр
+func StartTail(file string, filter LogFilter, emit func(entries []LogEntry)) error {
+	path, err := logPath(file)
+	if err != nil {
+		return err
+	}
+
+	// Начинаем с конца файла - историю показывает Search
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	stop := make(chan struct{})
+	tailsMu.Lock()
+	if previous, ok := tails[file]; ok {
+		close(previous)
+	}
+	tails[file] = stop
+	tailsMu.Unlock()
+
+	go tailLoop(file, path, offset, newMatcher(filter), emit, stop)
+	return nil
+}
+
+// StopTail останавливает слежение за файлом (file = "" - за всеми)
+func StopTail(file string) {
+	tailsMu.Lock()
+	defer tailsMu.Unlock()
+
+	for name, stop := range tails {
+		if file == "" || name == file {
+			close(stop)
+			delete(tails, name)
+		}
+	}
+}
+
+// ActiveTails возвращает файлы, за которыми идёт слежение
+func ActiveTails() []string {
+	tailsMu.Lock()
+	defer tailsMu.Unlock()
+
+	files := []string{}
+	for name := range tails {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func tailLoop(file string, path string, offset int64, m matcher, emit func([]LogEntry), stop chan struct{}) {
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+
+	var partial string // Недописанная строка до следующего опроса
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		// Файл ротирован или очищен - читаем новый с начала
+		if info.Size() < offset {
+			offset = 0
+			partial = ""
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		data, err := readFrom(path, offset)
+		if err != nil {
+			continue
+		}
+		offset += int64(len(data))
+
+		text := partial + string(data)
+		lines := strings.Split(text, "\n")
+		partial = lines[len(lines)-1]
+
+		var batch []LogEntry
+		for _, line := range lines[:len(lines)-1] {
+			line = strings.TrimRight(line, "\r")
+			if line == "" {
+				continue
+			}
+			if entry, ok := m.match(file, line); ok {
+				batch = append(batch, entry)
+			}
+			if len(batch) >= tailBatchSize {
+				emit(batch)
+				batch = nil
+			}
+		}
+		if len(batch) > 0 {
+			emit(batch)
+		}
+	}
+}
+
+// readFrom читает файл с позиции offset (не больше maxLineSize*4 за раз)
+func readFrom(path string, offset int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(file, maxLineSize*4))
+}
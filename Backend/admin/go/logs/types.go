@@ -0,0 +1,38 @@
+package logs
+
+// Файл лога в WebServer/tools/logs
+type LogFile struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	ModTime  string `json:"mtime"`
+	Segments int    `json:"segments"` // Старые сегменты после ротации
+}
+
+// Разобранная строка лога
+type LogEntry struct {
+	File    string `json:"file"`
+	Time    string `json:"time"`
+	Level   string `json:"level"`   // debug, info, warn, error
+	Service string `json:"service"` // Подсистема (http, php, acme...) или access
+	Host    string `json:"host"`
+	Message string `json:"message"`
+	Raw     string `json:"raw"`
+}
+
+// Фильтр записей: все условия объединяются через И, пустые не применяются
+type LogFilter struct {
+	Level          string `json:"level"`           // Минимальный уровень
+	Service        string `json:"service"`         // Подсистема
+	Host           string `json:"host"`            // Домен (часть имени)
+	Query          string `json:"query"`           // Подстрока в строке лога
+	IncludeRotated bool   `json:"include_rotated"` // Искать и в старых сегментах (.log.gz)
+}
+
+// Страница результатов поиска (новые записи первыми)
+type LogPage struct {
+	Entries  []LogEntry `json:"entries"`
+	Total    int        `json:"total"`
+	Page     int        `json:"page"`
+	PageSize int        `json:"page_size"`
+	Error    string     `json:"error,omitempty"` // Ошибка поиска для UI (пустая при успехе)
+}
@@ -1,13 +1,14 @@
 package proxy
 
 import (
+	webserver "vServer/Backend/WebServer"
 	config "vServer/Backend/config"
 )
 
 func GetProxyList() []ProxyInfo {
 	proxies := make([]ProxyInfo, 0)
 
-	for _, proxyConfig := range config.ConfigData.Proxy_Service {
+	for _, proxyConfig := range config.Current().Proxy_Service {
 		status := "disabled"
 		if proxyConfig.Enable {
 			status = "active"
@@ -16,12 +17,19 @@ func GetProxyList() []ProxyInfo {
 		proxyInfo := ProxyInfo{
 			Enable:          proxyConfig.Enable,
 			ExternalDomain:  proxyConfig.ExternalDomain,
+			Alias:           proxyConfig.Alias,
 			LocalAddress:    proxyConfig.LocalAddress,
 			LocalPort:       proxyConfig.LocalPort,
 			ServiceHTTPSuse: proxyConfig.ServiceHTTPSuse,
 			AutoHTTPS:       proxyConfig.AutoHTTPS,
 			AutoCreateSSL:   proxyConfig.AutoCreateSSL,
+			Listen:          proxyConfig.Listen,
 			Status:          status,
+			Balance:         proxyConfig.Balance,
+			HealthCheck:     proxyConfig.Health_check != nil,
+		}
+		for _, state := range webserver.ProxyUpstreamStates(proxyConfig) {
+			proxyInfo.Upstreams = append(proxyInfo.Upstreams, NewUpstreamInfo(state))
 		}
 		proxies = append(proxies, proxyInfo)
 	}
@@ -29,3 +37,23 @@ func GetProxyList() []ProxyInfo {
 	return proxies
 }
 
+// NewUpstreamInfo переводит состояние бэкенда из webserver в формат админки
+func NewUpstreamInfo(state webserver.UpstreamState) UpstreamInfo {
+	lastCheck := ""
+	if !state.LastCheck.IsZero() {
+		lastCheck = state.LastCheck.Format("2006-01-02 15:04:05")
+	}
+
+	return UpstreamInfo{
+		Domain:         state.Domain,
+		Route:          state.Route,
+		Address:        state.Address,
+		Weight:         state.Weight,
+		Status:         state.Status,
+		Ejected:        state.Ejected,
+		ActiveRequests: state.ActiveRequests,
+		LatencyMs:      state.Latency.Milliseconds(),
+		LastCheck:      lastCheck,
+		LastError:      state.LastError,
+	}
+}
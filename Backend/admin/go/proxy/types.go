@@ -1,13 +1,31 @@
 package proxy
 
 type ProxyInfo struct {
-	Enable          bool   `json:"enable"`
-	ExternalDomain  string `json:"external_domain"`
-	LocalAddress    string `json:"local_address"`
-	LocalPort       string `json:"local_port"`
-	ServiceHTTPSuse bool   `json:"service_https_use"`
-	AutoHTTPS       bool   `json:"auto_https"`
-	AutoCreateSSL   bool   `json:"auto_create_ssl"`
-	Status          string `json:"status"`
+	Enable          bool           `json:"enable"`
+	ExternalDomain  string         `json:"external_domain"`
+	Alias           []string       `json:"alias"`
+	LocalAddress    string         `json:"local_address"`
+	LocalPort       string         `json:"local_port"`
+	ServiceHTTPSuse bool           `json:"service_https_use"`
+	AutoHTTPS       bool           `json:"auto_https"`
+	AutoCreateSSL   bool           `json:"auto_create_ssl"`
+	Listen          []int          `json:"listen"`
+	Status          string         `json:"status"`
+	Balance         string         `json:"balance"`
+	HealthCheck     bool           `json:"health_check"` // Задана активная проверка бэкендов
+	Upstreams       []UpstreamInfo `json:"upstreams"`
 }
 
+// Бэкенд прокси (из upstreams или LocalAddress:LocalPort) и его текущее состояние
+type UpstreamInfo struct {
+	Domain         string `json:"domain"`
+	Route          string `json:"route"` // Путь маршрута ("" - бэкенды самого прокси)
+	Address        string `json:"address"`
+	Weight         int    `json:"weight"`
+	Status         string `json:"status"`  // up, down, unknown
+	Ejected        bool   `json:"ejected"` // Исключён после ошибок запросов
+	ActiveRequests int64  `json:"active_requests"`
+	LatencyMs      int64  `json:"latency_ms"` // Время последней проверки
+	LastCheck      string `json:"last_check"`
+	LastError      string `json:"last_error"`
+}
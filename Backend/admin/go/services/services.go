@@ -8,11 +8,12 @@ import (
 
 func GetAllServicesStatus() AllServicesStatus {
 	return AllServicesStatus{
-		HTTP:  getHTTPStatus(),
-		HTTPS: getHTTPSStatus(),
-		MySQL: getMySQLStatus(),
-		PHP:   getPHPStatus(),
-		Proxy: getProxyStatus(),
+		HTTP:   getHTTPStatus(),
+		HTTPS:  getHTTPSStatus(),
+		MySQL:  getMySQLStatus(),
+		PHP:    getPHPStatus(),
+		Proxy:  getProxyStatus(),
+		Stream: getStreamStatus(),
 	}
 }
 
@@ -21,7 +22,7 @@ func getHTTPStatus() ServiceStatus {
 	return ServiceStatus{
 		Name:   "HTTP",
 		Status: webserver.GetHTTPStatus(),
-		Port:   "80",
+		Port:   webserver.GetHTTPPorts(),
 		Info:   "",
 	}
 }
@@ -31,13 +32,13 @@ func getHTTPSStatus() ServiceStatus {
 	return ServiceStatus{
 		Name:   "HTTPS",
 		Status: webserver.GetHTTPSStatus(),
-		Port:   "443",
+		Port:   webserver.GetHTTPSPorts(),
 		Info:   "",
 	}
 }
 
 func getMySQLStatus() ServiceStatus {
-	port := fmt.Sprintf("%d", config.ConfigData.Soft_Settings.Mysql_port)
+	port := fmt.Sprintf("%d", config.Current().Soft_Settings.Mysql_port)
 
 	// Используем внутренний статус вместо TCP проверки
 	// чтобы не вызывать connect_errors в MySQL
@@ -50,10 +51,10 @@ func getMySQLStatus() ServiceStatus {
 }
 
 func getPHPStatus() ServiceStatus {
-	basePort := config.ConfigData.Soft_Settings.Php_port
+	basePort := config.Current().Soft_Settings.Php_port
 
-	// Диапазон портов для 4 воркеров
-	portRange := fmt.Sprintf("%d-%d", basePort, basePort+3)
+	// Диапазон портов для всех воркеров пула
+	portRange := fmt.Sprintf("%d-%d", basePort, basePort+config.PHPWorkers()-1)
 
 	// Используем внутренний статус вместо TCP проверки
 	return ServiceStatus{
@@ -65,10 +66,11 @@ func getPHPStatus() ServiceStatus {
 }
 
 func getProxyStatus() ServiceStatus {
+	cfg := config.Current()
 	activeCount := 0
-	totalCount := len(config.ConfigData.Proxy_Service)
+	totalCount := len(cfg.Proxy_Service)
 
-	for _, proxy := range config.ConfigData.Proxy_Service {
+	for _, proxy := range cfg.Proxy_Service {
 		if proxy.Enable {
 			activeCount++
 		}
@@ -77,7 +79,7 @@ func getProxyStatus() ServiceStatus {
 	info := fmt.Sprintf("%d из %d", activeCount, totalCount)
 
 	// Проверяем глобальный флаг и статус HTTP/HTTPS
-	proxyEnabled := config.ConfigData.Soft_Settings.Proxy_enabled
+	proxyEnabled := cfg.Soft_Settings.Proxy_enabled
 	httpRunning := webserver.GetHTTPStatus()
 	httpsRunning := webserver.GetHTTPSStatus()
 
@@ -90,3 +92,47 @@ func getProxyStatus() ServiceStatus {
 		Info:   info,
 	}
 }
+
+func getStreamStatus() ServiceStatus {
+	activeCount := 0
+	totalCount := len(config.Current().Stream_Service)
+	connections := int64(0)
+
+	for _, stream := range webserver.StreamStates() {
+		if stream.Running {
+			activeCount++
+			connections += stream.Active
+		}
+	}
+
+	return ServiceStatus{
+		Name:   "Stream",
+		Status: webserver.GetStreamStatus(),
+		Port:   webserver.GetStreamPorts(),
+		Info:   fmt.Sprintf("%d из %d, соединений: %d", activeCount, totalCount, connections),
+	}
+}
+
+// GetStreamList возвращает Stream_Service из конфига со статистикой соединений
+func GetStreamList() []StreamInfo {
+	states := webserver.StreamStates()
+	list := make([]StreamInfo, 0, len(states))
+	for _, state := range states {
+		list = append(list, StreamInfo{
+			Name:           state.Name,
+			Protocol:       state.Protocol,
+			Listen:         state.Listen,
+			Upstream:       state.Upstream,
+			Sni:            state.Sni,
+			Enable:         state.Enable,
+			Running:        state.Running,
+			Active:         state.Active,
+			Total:          state.Total,
+			Rejected:       state.Rejected,
+			BytesIn:        state.BytesIn,
+			BytesOut:       state.BytesOut,
+			MaxConnections: state.MaxConns,
+		})
+	}
+	return list
+}
@@ -8,9 +8,27 @@ type ServiceStatus struct {
 }
 
 type AllServicesStatus struct {
-	HTTP  ServiceStatus `json:"http"`
-	HTTPS ServiceStatus `json:"https"`
-	MySQL ServiceStatus `json:"mysql"`
-	PHP   ServiceStatus `json:"php"`
-	Proxy ServiceStatus `json:"proxy"`
+	HTTP   ServiceStatus `json:"http"`
+	HTTPS  ServiceStatus `json:"https"`
+	MySQL  ServiceStatus `json:"mysql"`
+	PHP    ServiceStatus `json:"php"`
+	Proxy  ServiceStatus `json:"proxy"`
+	Stream ServiceStatus `json:"stream"`
+}
+
+// StreamInfo - Stream_Service и его статистика
+type StreamInfo struct {
+	Name           string   `json:"name"`
+	Protocol       string   `json:"protocol"`
+	Listen         int      `json:"listen"`
+	Upstream       string   `json:"upstream"`
+	Sni            []string `json:"sni,omitempty"`
+	Enable         bool     `json:"enable"`
+	Running        bool     `json:"running"`
+	Active         int64    `json:"active"`
+	Total          int64    `json:"total"`
+	Rejected       int64    `json:"rejected"`
+	BytesIn        int64    `json:"bytes_in"`
+	BytesOut       int64    `json:"bytes_out"`
+	MaxConnections int      `json:"max_connections"`
 }
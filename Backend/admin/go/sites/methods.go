@@ -2,11 +2,11 @@ package sites
 
 import (
 	_ "embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	config "vServer/Backend/config"
 	tools "vServer/Backend/tools"
@@ -15,6 +15,8 @@ import (
 //go:embed templates/index.tmpl
 var indexTemplate string
 
+var sitesLog = tools.NewLogger("admin", "logs_config.log")
+
 // CreateNewSite создаёт новый сайт со всей необходимой структурой
 func CreateNewSite(siteData SiteInfo) error {
 	// 1. Валидация данных
@@ -23,12 +25,12 @@ func CreateNewSite(siteData SiteInfo) error {
 	}
 
 	// 2. Создание структуры папок
-	if err := CreateSiteFolder(siteData.Host); err != nil {
+	if err := CreateSiteFolder(siteData.Host, siteData.DocumentRoot); err != nil {
 		return fmt.Errorf("ошибка создания папок: %w", err)
 	}
 
 	// 3. Создание стартового файла
-	if err := CreateStarterFile(siteData.Host, siteData.RootFile); err != nil {
+	if err := CreateStarterFile(siteData.Host, siteData.DocumentRoot, siteData.RootFile); err != nil {
 		return fmt.Errorf("ошибка создания стартового файла: %w", err)
 	}
 
@@ -42,7 +44,7 @@ func CreateNewSite(siteData SiteInfo) error {
 		return fmt.Errorf("ошибка добавления в конфиг: %w", err)
 	}
 
-	tools.Logs_file(0, "SITES", fmt.Sprintf("✅ Новый сайт создан: %s (%s)", siteData.Name, siteData.Host), "logs_config.log", true)
+	sitesLog.Console().Info("Новый сайт создан", "name", siteData.Name, "host", siteData.Host)
 	return nil
 }
 
@@ -62,7 +64,7 @@ func ValidateSiteData(siteData SiteInfo) error {
 	}
 
 	// Проверка уникальности host
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
 		if strings.EqualFold(site.Host, siteData.Host) {
 			return fmt.Errorf("сайт с host '%s' уже существует", siteData.Host)
 		}
@@ -77,27 +79,30 @@ func ValidateSiteData(siteData SiteInfo) error {
 }
 
 // CreateSiteFolder создаёт структуру папок для нового сайта
-func CreateSiteFolder(host string) error {
-	// Создаём путь: WebServer/www/{host}/public_www/
-	folderPath := filepath.Join("WebServer", "www", host, "public_www")
-
-	absPath, err := tools.AbsPath(folderPath)
-	if err != nil {
-		return err
-	}
+// Папка сайта WebServer/www/{host}/ нужна всегда (vAccess.conf), корень документов - public_www или document_root
+func CreateSiteFolder(host, documentRoot string) error {
+	folderPath := config.ResolveDocumentRoot(host, documentRoot)
+
+	for _, dir := range []string{config.SiteDir(host), folderPath} {
+		// Имя папки сайта содержит точки - tools.AbsPath принял бы его за файл
+		absPath, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("ошибка получения абсолютного пути: %w", err)
+		}
 
-	// Создаём все необходимые папки
-	if err := os.MkdirAll(absPath, 0755); err != nil {
-		return fmt.Errorf("не удалось создать папку: %w", err)
+		// Создаём все необходимые папки
+		if err := os.MkdirAll(absPath, 0755); err != nil {
+			return fmt.Errorf("не удалось создать папку: %w", err)
+		}
 	}
 
-	tools.Logs_file(0, "SITES", fmt.Sprintf("📁 Создана папка: %s", folderPath), "logs_config.log", false)
+	sitesLog.Info("Создана папка сайта", "host", host, "document_root", folderPath)
 	return nil
 }
 
 // CreateStarterFile создаёт стартовый файл (index.html или index.php)
-func CreateStarterFile(host, rootFile string) error {
-	filePath := filepath.Join("WebServer", "www", host, "public_www", rootFile)
+func CreateStarterFile(host, documentRoot, rootFile string) error {
+	filePath := filepath.Join(config.ResolveDocumentRoot(host, documentRoot), rootFile)
 
 	// Получаем абсолютный путь БЕЗ проверки существования
 	absPath, err := filepath.Abs(filePath)
@@ -105,6 +110,12 @@ func CreateStarterFile(host, rootFile string) error {
 		return fmt.Errorf("ошибка получения абсолютного пути: %w", err)
 	}
 
+	// document_root может указывать на готовый проект - его файлы не перезаписываем
+	if _, err := os.Stat(absPath); err == nil {
+		sitesLog.Info("Стартовый файл уже существует", "host", host, "file", rootFile)
+		return nil
+	}
+
 	// Генерируем контент из шаблона
 	content := generateTemplate(host, rootFile)
 
@@ -113,13 +124,13 @@ func CreateStarterFile(host, rootFile string) error {
 		return fmt.Errorf("не удалось создать файл: %w", err)
 	}
 
-	tools.Logs_file(0, "SITES", fmt.Sprintf("📄 Создан стартовый файл: %s", rootFile), "logs_config.log", false)
+	sitesLog.Info("Создан стартовый файл", "host", host, "file", rootFile)
 	return nil
 }
 
 // CreateVAccessFile создаёт пустой конфиг vAccess
 func CreateVAccessFile(host string) error {
-	filePath := filepath.Join("WebServer", "www", host, "vAccess.conf")
+	filePath := filepath.Join(config.SiteDir(host), "vAccess.conf")
 
 	// Получаем абсолютный путь БЕЗ проверки существования
 	absPath, err := filepath.Abs(filePath)
@@ -138,11 +149,11 @@ func CreateVAccessFile(host string) error {
 
 `
 
-	if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+	if err := config.SaveFile(absPath, []byte(content)); err != nil {
 		return fmt.Errorf("не удалось создать vAccess.conf: %w", err)
 	}
 
-	tools.Logs_file(0, "SITES", "🔒 Создан vAccess.conf", "logs_config.log", false)
+	sitesLog.Info("Создан vAccess.conf", "host", host)
 	return nil
 }
 
@@ -156,39 +167,19 @@ func AddSiteToConfig(siteData SiteInfo) error {
 		Status:            siteData.Status,
 		Root_file:         siteData.RootFile,
 		Root_file_routing: siteData.RootFileRouting,
+		Listen:            siteData.Listen,
+		Document_root:     strings.TrimSpace(siteData.DocumentRoot),
 	}
 
-	// Добавляем в массив
-	config.ConfigData.Site_www = append(config.ConfigData.Site_www, newSite)
-
-	// Сохраняем конфиг в файл
-	if err := SaveConfig(); err != nil {
-		return err
-	}
-
-	tools.Logs_file(0, "SITES", "💾 Конфигурация обновлена", "logs_config.log", false)
-	return nil
-}
-
-// SaveConfig сохраняет текущую конфигурацию в файл
-func SaveConfig() error {
-	// Форматируем JSON с отступами
-	jsonData, err := json.MarshalIndent(config.ConfigData, "", "    ")
-	if err != nil {
-		return fmt.Errorf("ошибка форматирования JSON: %w", err)
-	}
-
-	// Получаем абсолютный путь к файлу конфига
-	absPath, err := tools.AbsPath(config.ConfigPath)
+	// Добавляем в массив и сохраняем конфиг в файл
+	err := config.Update(func(cfg *config.Config) {
+		cfg.Site_www = append(cfg.Site_www, newSite)
+	})
 	if err != nil {
 		return err
 	}
 
-	// Записываем в файл
-	if err := os.WriteFile(absPath, jsonData, 0644); err != nil {
-		return fmt.Errorf("ошибка записи файла: %w", err)
-	}
-
+	sitesLog.Info("Сайт добавлен в конфигурацию", "host", siteData.Host)
 	return nil
 }
 
@@ -227,7 +218,7 @@ func UploadSiteCertificate(host, certType string, certData []byte) error {
 		return fmt.Errorf("не удалось сохранить сертификат: %w", err)
 	}
 
-	tools.Logs_file(0, "SITES", fmt.Sprintf("🔒 Загружен сертификат: %s для %s", fileName, host), "logs_config.log", true)
+	sitesLog.Console().Info("Загружен сертификат", "host", host, "file", fileName)
 	return nil
 }
 
@@ -251,7 +242,7 @@ func DeleteSiteCertificates(host string) error {
 		return fmt.Errorf("не удалось удалить папку сертификатов: %w", err)
 	}
 
-	tools.Logs_file(0, "SITES", fmt.Sprintf("🗑️ Удалены сертификаты для: %s", host), "logs_config.log", true)
+	sitesLog.Console().Info("Удалены сертификаты", "host", host)
 	return nil
 }
 
@@ -259,7 +250,7 @@ func DeleteSiteCertificates(host string) error {
 func DeleteSite(host string) error {
 	// 1. Проверяем, существует ли сайт в конфиге
 	siteIndex := -1
-	for i, site := range config.ConfigData.Site_www {
+	for i, site := range config.Current().Site_www {
 		if site.Host == host {
 			siteIndex = i
 			break
@@ -281,27 +272,26 @@ func DeleteSite(host string) error {
 		if err := os.RemoveAll(absSiteDir); err != nil {
 			return fmt.Errorf("не удалось удалить папку сайта: %w", err)
 		}
-		tools.Logs_file(0, "SITES", fmt.Sprintf("🗑️ Удалена папка сайта: %s", siteDir), "logs_config.log", false)
+		sitesLog.Info("Удалена папка сайта", "host", host, "path", siteDir)
 	}
 
 	// 3. Удаляем сертификаты
 	if err := DeleteSiteCertificates(host); err != nil {
 		// Логируем ошибку, но продолжаем удаление
-		tools.Logs_file(1, "SITES", fmt.Sprintf("Ошибка удаления сертификатов: %v", err), "logs_config.log", false)
+		sitesLog.Error("Ошибка удаления сертификатов", "host", host, "error", err)
 	}
 
-	// 4. Удаляем из конфига
-	config.ConfigData.Site_www = append(
-		config.ConfigData.Site_www[:siteIndex],
-		config.ConfigData.Site_www[siteIndex+1:]...,
-	)
-
-	// 5. Сохраняем конфиг
-	if err := SaveConfig(); err != nil {
+	// 4. Удаляем из конфига и сохраняем его
+	err = config.Update(func(cfg *config.Config) {
+		cfg.Site_www = slices.DeleteFunc(cfg.Site_www, func(site config.Site_www) bool {
+			return site.Host == host
+		})
+	})
+	if err != nil {
 		return fmt.Errorf("ошибка сохранения конфигурации: %w", err)
 	}
 
-	tools.Logs_file(0, "SITES", fmt.Sprintf("✅ Сайт '%s' полностью удалён", host), "logs_config.log", true)
+	sitesLog.Console().Info("Сайт полностью удалён", "host", host)
 	return nil
 }
 
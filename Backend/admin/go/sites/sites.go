@@ -7,7 +7,7 @@ import (
 func GetSitesList() []SiteInfo {
 	sites := make([]SiteInfo, 0)
 
-	for _, site := range config.ConfigData.Site_www {
+	for _, site := range config.Current().Site_www {
 		siteInfo := SiteInfo{
 			Name:            site.Name,
 			Host:            site.Host,
@@ -16,6 +16,8 @@ func GetSitesList() []SiteInfo {
 			RootFile:        site.Root_file,
 			RootFileRouting: site.Root_file_routing,
 			AutoCreateSSL:   site.AutoCreateSSL,
+			Listen:          site.Listen,
+			DocumentRoot:    site.Document_root,
 		}
 		sites = append(sites, siteInfo)
 	}
@@ -8,5 +8,7 @@ type SiteInfo struct {
 	RootFile          string   `json:"root_file"`
 	RootFileRouting   bool     `json:"root_file_routing"`
 	AutoCreateSSL     bool     `json:"auto_create_ssl"`
+	Listen            []int    `json:"listen"`
+	DocumentRoot      string   `json:"document_root"`
 }
 
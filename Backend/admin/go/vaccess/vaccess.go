@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	cfg "vServer/Backend/config"
 )
 
 func GetVAccessPath(host string, isProxy bool) string {
@@ -150,7 +151,7 @@ func SaveVAccessConfig(host string, isProxy bool, config *VAccessConfig) error {
 		content.WriteString("\n")
 	}
 
-	return os.WriteFile(absPath, []byte(content.String()), 0644)
+	return cfg.SaveFile(absPath, []byte(content.String()))
 }
 
 func splitAndTrim(s string) []string {
@@ -2,137 +2,384 @@ package config
 
 import (
 	"encoding/json"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	tools "vServer/Backend/tools"
 )
 
 var ConfigPath = "WebServer/config.json"
 
-var ConfigData struct {
-	Site_www      []Site_www      `json:"Site_www"`
-	Soft_Settings Soft_Settings   `json:"Soft_Settings"`
-	Proxy_Service []Proxy_Service `json:"Proxy_Service"`
+var configLog = tools.NewLogger("config", "logs_config.log")
+
+// current - действующая конфигурация. Опубликованная структура не меняется: загрузка
+// и Update собирают новую и подменяют указатель, поэтому запросы читают её без блокировок
+var current atomic.Pointer[Config]
+
+// updateMutex упорядочивает подмену конфигурации (LoadConfig, Update)
+var updateMutex sync.Mutex
+
+func init() {
+	current.Store(&Config{})
+}
+
+// Current возвращает действующую конфигурацию только для чтения
+// Обработчику запроса лучше взять её один раз: повторный вызов может вернуть уже новую
+func Current() *Config {
+	return current.Load()
+}
+
+type Config struct {
+	Config_version int              `json:"config_version"` // Версия схемы (см. migrations.go)
+	Site_www       []Site_www       `json:"Site_www"`
+	Soft_Settings  Soft_Settings    `json:"Soft_Settings"`
+	Proxy_Service  []Proxy_Service  `json:"Proxy_Service"`
+	Stream_Service []Stream_Service `json:"Stream_Service,omitempty"`
 }
 
 type Site_www struct {
-	Name              string   `json:"name"`
-	Host              string   `json:"host"`
-	Alias             []string `json:"alias"`
-	Status            string   `json:"status"`
-	Root_file         string   `json:"root_file"`
-	Root_file_routing bool     `json:"root_file_routing"`
-	AutoCreateSSL     bool     `json:"AutoCreateSSL"`
+	Name              string            `json:"name"`
+	Host              string            `json:"host"`
+	Alias             []string          `json:"alias"`
+	Status            string            `json:"status"`
+	Root_file         string            `json:"root_file"`
+	Root_file_routing bool              `json:"root_file_routing"`
+	AutoCreateSSL     bool              `json:"AutoCreateSSL"`
+	Listen            []int             `json:"listen,omitempty"`        // Порты, на которых отвечает сайт (пусто = все)
+	Document_root     string            `json:"document_root,omitempty"` // Корень документов (пусто = public_www, относительный путь - от папки сайта)
+	Error_pages       map[string]string `json:"error_pages,omitempty"`   // Код ответа (404, 5xx, default) → файл или URL
+	Autoindex         []string          `json:"autoindex,omitempty"`     // Пути с листингом директорий ("/*", "/downloads/*")
+	Access_log        string            `json:"access_log,omitempty"`    // Формат access-лога: combined (по умолчанию), json, off
 }
 
 type Soft_Settings struct {
-	Php_port      int    `json:"php_port"`
-	Php_host      string `json:"php_host"`
-	Mysql_port    int    `json:"mysql_port"`
-	Mysql_host    string `json:"mysql_host"`
-	Proxy_enabled bool   `json:"proxy_enabled"`
-	ACME_enabled  bool   `json:"ACME_enabled"`
+	Php_port          int               `json:"php_port"`
+	Php_host          string            `json:"php_host"`
+	Mysql_port        int               `json:"mysql_port"`
+	Mysql_host        string            `json:"mysql_host"`
+	Proxy_enabled     bool              `json:"proxy_enabled"`
+	ACME_enabled      bool              `json:"ACME_enabled"`
+	Listen_address    string            `json:"listen_address"`    // IPv4 адрес для HTTP/HTTPS ("" = все интерфейсы)
+	Listen_ipv6       bool              `json:"listen_ipv6"`       // Дополнительно слушать IPv6
+	Listen_address_v6 string            `json:"listen_address_v6"` // IPv6 адрес ("" = все интерфейсы)
+	Http_ports        []int             `json:"http_ports"`
+	Https_ports       []int             `json:"https_ports"`
+	Shutdown_timeout  int               `json:"shutdown_timeout"` // Время ожидания активных запросов при остановке, сек
+	Php_workers       int               `json:"php_workers"`      // Количество FastCGI процессов PHP
+	History_limit     int               `json:"history_limit"`    // Сколько снимков конфигов хранить на файл
+	Log_rotation      Log_Rotation      `json:"log_rotation"`
+	Log_level         string            `json:"log_level"`            // Уровень по умолчанию: debug, info, warn, error
+	Log_levels        map[string]string `json:"log_levels,omitempty"` // Уровни подсистем: {"acme": "debug", "php": "warn"}
+	Log_format        string            `json:"log_format"`           // Формат файлов логов: text или json
+	Metrics_listen    string            `json:"metrics_listen"`       // Адрес Prometheus /metrics ("127.0.0.1:9180", "" = выключен)
+}
+
+// Ротация и хранение файлов в WebServer/tools/logs
+type Log_Rotation struct {
+	Max_size_mb  int    `json:"max_size_mb"`  // Размер сегмента, МБ (0 = без ограничения)
+	Interval     string `json:"interval"`     // Ротация по времени: daily, hourly или "" (выключена)
+	Max_files    int    `json:"max_files"`    // Сколько старых сегментов хранить на лог (0 = все)
+	Max_age_days int    `json:"max_age_days"` // Сколько дней хранить старые сегменты (0 = без ограничения)
+	Compress     bool   `json:"compress"`     // Сжимать старые сегменты в .gz
 }
 
 type Proxy_Service struct {
-	Enable          bool   `json:"Enable"`
-	ExternalDomain  string `json:"ExternalDomain"`
-	LocalAddress    string `json:"LocalAddress"`
-	LocalPort       string `json:"LocalPort"`
-	ServiceHTTPSuse bool   `json:"ServiceHTTPSuse"`
-	AutoHTTPS       bool   `json:"AutoHTTPS"`
-	AutoCreateSSL   bool   `json:"AutoCreateSSL"`
+	Enable               bool              `json:"Enable"`
+	ExternalDomain       string            `json:"ExternalDomain"`
+	LocalAddress         string            `json:"LocalAddress"`
+	LocalPort            string            `json:"LocalPort"`
+	ServiceHTTPSuse      bool              `json:"ServiceHTTPSuse"`
+	AutoHTTPS            bool              `json:"AutoHTTPS"`
+	AutoCreateSSL        bool              `json:"AutoCreateSSL"`
+	Alias                []string          `json:"alias,omitempty"`                // Другие домены: точные, wildcard (*.preview.example.ru), regex (~^pr-\d+\.example\.ru$)
+	Listen               []int             `json:"listen,omitempty"`               // Порты, на которых отвечает прокси (пусто = все)
+	Error_pages          map[string]string `json:"error_pages,omitempty"`          // Код ответа (502, 5xx, default) → файл или URL
+	Access_log           string            `json:"access_log,omitempty"`           // Формат access-лога: combined (по умолчанию), json, off
+	Dial_timeout         int               `json:"dial_timeout,omitempty"`         // Подключение к бэкенду, сек (0 = 10)
+	Response_timeout     int               `json:"response_timeout,omitempty"`     // Ожидание заголовков ответа бэкенда, сек (0 = 60)
+	Idle_timeout         int               `json:"idle_timeout,omitempty"`         // Простой keep-alive соединения в пуле, сек (0 = 90)
+	Max_idle_conns       int               `json:"max_idle_conns,omitempty"`       // Keep-alive соединений к бэкенду (0 = 32)
+	Upgrade_idle_timeout int               `json:"upgrade_idle_timeout,omitempty"` // Простой WebSocket/Upgrade соединения, сек (0 = 300)
+	Upstreams            []Proxy_Upstream  `json:"upstreams,omitempty"`            // Несколько бэкендов (пусто = LocalAddress:LocalPort)
+	Balance              string            `json:"balance,omitempty"`              // Балансировка: round_robin (по умолчанию), least_conn, ip_hash
+	Max_fails            int               `json:"max_fails,omitempty"`            // Ошибок подряд до исключения бэкенда (0 = 3)
+	Fail_timeout         int               `json:"fail_timeout,omitempty"`         // На сколько исключать бэкенд, сек (0 = 30)
+	Max_tries            int               `json:"max_tries,omitempty"`            // Попыток идемпотентного запроса на разных бэкендах (0 = все, 1 = без повторов)
+	Health_check         *Proxy_Health     `json:"health_check,omitempty"`         // Активная проверка бэкендов (nil = выключена)
+	Routes               []Proxy_Route     `json:"routes,omitempty"`               // Маршруты по пути; остальные запросы - на бэкенды прокси
+	Preserve_host        bool              `json:"preserve_host,omitempty"`        // Передавать бэкенду Host клиента (по умолчанию - адрес бэкенда)
+	Upstream_host        string            `json:"upstream_host,omitempty"`        // Или передавать бэкенду этот Host
+	Request_headers      *Proxy_Headers    `json:"request_headers,omitempty"`      // Заголовки запроса к бэкенду
+	Response_headers     *Proxy_Headers    `json:"response_headers,omitempty"`     // Заголовки ответа бэкенда клиенту
+	Response_rewrite     *Proxy_Rewrite    `json:"response_rewrite,omitempty"`     // Замена адреса бэкенда на внешний домен в ответах
+	Upstream_tls         *Proxy_TLS        `json:"upstream_tls,omitempty"`         // TLS к бэкенду при ServiceHTTPSuse (nil = без проверки сертификата)
 }
 
-func LoadConfig() {
+// TLS-соединение с бэкендом. Пути к файлам - абсолютные или от папки vServer
+type Proxy_TLS struct {
+	Verify      bool   `json:"verify,omitempty"`      // Проверять сертификат бэкенда
+	Ca_file     string `json:"ca_file,omitempty"`     // PEM с доверенными CA (пусто = системные)
+	Server_name string `json:"server_name,omitempty"` // SNI и имя для проверки сертификата (пусто = адрес бэкенда)
+	Min_version string `json:"min_version,omitempty"` // Минимальная версия: 1.0, 1.1, 1.2 (по умолчанию), 1.3
+	Cert_file   string `json:"cert_file,omitempty"`   // Клиентский сертификат для mTLS
+	Key_file    string `json:"key_file,omitempty"`    // Ключ клиентского сертификата
+}
 
-	data, err := os.ReadFile(ConfigPath)
+// Переписывание ответов бэкенда, который считает, что работает на своём адресе (http://127.0.0.1:3333)
+type Proxy_Rewrite struct {
+	Redirects     bool            `json:"redirects,omitempty"`     // Location и Refresh: адрес бэкенда → домен и протокол клиента
+	Cookies       bool            `json:"cookies,omitempty"`       // Set-Cookie: Domain бэкенда → домен клиента, Path - с учётом маршрута
+	Body          []Proxy_Replace `json:"body,omitempty"`          // Замены в теле ответа (потоково, по порядку)
+	Content_types []string        `json:"content_types,omitempty"` // Типы тела для замен (пусто = text/*, JSON, JavaScript, XML, SVG)
+}
 
-	if err != nil {
-		tools.Logs_file(0, "JSON", "Ошибка загрузки конфигурационного файла", "logs_config.log", true)
-	} else {
-		tools.Logs_file(0, "JSON", "config.json успешно загружен", "logs_config.log", true)
+// Замена в теле ответа
+type Proxy_Replace struct {
+	Search  string `json:"search"`
+	Replace string `json:"replace"`
+	Regex   bool   `json:"regex,omitempty"` // search - регулярное выражение, в replace доступны $1 и ${name}
+}
+
+// Правила заголовков прокси: применяются в порядке remove, set, add
+type Proxy_Headers struct {
+	Add    map[string]string `json:"add,omitempty"`    // Добавить значение к существующим
+	Set    map[string]string `json:"set,omitempty"`    // Заменить значение
+	Remove []string          `json:"remove,omitempty"` // Удалить заголовок
+}
+
+// Маршрут внутри домена прокси: запросы по path уходят на свои бэкенды или на сайт из Site_www
+type Proxy_Route struct {
+	Path         string           `json:"path"`                   // "/ws" - точный путь, "/api/*" - путь и всё под ним
+	Upstreams    []Proxy_Upstream `json:"upstreams,omitempty"`    // Бэкенды маршрута (балансировка как у прокси)
+	Site         string           `json:"site,omitempty"`         // Или host сайта из Site_www
+	Strip_prefix bool             `json:"strip_prefix,omitempty"` // Убрать префикс маршрута: /api/users → /users
+	Rewrite      string           `json:"rewrite,omitempty"`      // Заменить префикс маршрута: /old/* c rewrite /new → /new/...
+}
+
+// Активная проверка бэкендов прокси: GET path с Host прокси каждые interval секунд
+type Proxy_Health struct {
+	Path            string `json:"path"`                      // Путь проверки ("/health")
+	Interval        int    `json:"interval,omitempty"`        // Интервал, сек (0 = 10)
+	Timeout         int    `json:"timeout,omitempty"`         // Таймаут проверки, сек (0 = 5)
+	Expected_status int    `json:"expected_status,omitempty"` // Ожидаемый код ответа (0 = любой 2xx/3xx)
+}
+
+// L4-прокси: порт vServer → TCP/UDP сервис (MySQL, SSH в контейнер, игровые серверы)
+type Stream_Service struct {
+	Enable          bool     `json:"Enable"`
+	Name            string   `json:"name"`                      // Имя для логов, статистики и админки (уникальное)
+	Protocol        string   `json:"protocol,omitempty"`        // tcp (по умолчанию) или udp
+	Listen          int      `json:"listen,omitempty"`          // Свой порт (0 = только по SNI на портах HTTPS)
+	Upstream        string   `json:"upstream"`                  // Адрес сервиса host:port
+	Tls             bool     `json:"tls,omitempty"`             // Расшифровывать TLS сертификатами vServer, сервису - открытый TCP
+	Sni             []string `json:"sni,omitempty"`             // Домены TLS на портах HTTPS, соединения с которыми уходят в сервис
+	Max_connections int      `json:"max_connections,omitempty"` // Одновременных соединений (UDP - клиентов), 0 = без ограничения
+	Connect_timeout int      `json:"connect_timeout,omitempty"` // Подключение к сервису, сек (0 = 10)
+	Idle_timeout    int      `json:"idle_timeout,omitempty"`    // Простой соединения, сек (0 = 3600 для TCP, 60 для UDP)
+}
+
+// Бэкенд прокси для балансировки
+type Proxy_Upstream struct {
+	Address string `json:"address"`          // host:port
+	Weight  int    `json:"weight,omitempty"` // Вес (0 = 1)
+}
+
+// HTTPPorts возвращает порты HTTP сервера (по умолчанию 80)
+func HTTPPorts() []int {
+	ports := Current().Soft_Settings.Http_ports
+	if len(ports) == 0 {
+		return []int{80}
 	}
+	return ports
+}
 
-	err = json.Unmarshal(data, &ConfigData)
-	if err != nil {
-		tools.Logs_file(0, "JSON", "Ошибка парсинга конфигурационного файла", "logs_config.log", true)
-	} else {
-		tools.Logs_file(0, "JSON", "config.json успешно прочитан", "logs_config.log", true)
+// HTTPSPorts возвращает порты HTTPS сервера (по умолчанию 443)
+func HTTPSPorts() []int {
+	ports := Current().Soft_Settings.Https_ports
+	if len(ports) == 0 {
+		return []int{443}
 	}
+	return ports
+}
 
-	// Миграция: добавляем новые поля если их нет
-	migrateConfig(data)
+// Размер пула FastCGI процессов, если php_workers не задан
+const defaultPHPWorkers = 4
 
-	println()
+// PHPWorkers возвращает размер пула FastCGI процессов (по умолчанию 4)
+func PHPWorkers() int {
+	return phpWorkers(Current().Soft_Settings)
+}
 
+func phpWorkers(settings Soft_Settings) int {
+	if settings.Php_workers <= 0 {
+		return defaultPHPWorkers
+	}
+	return settings.Php_workers
 }
 
-// migrateConfig проверяет и добавляет новые поля в конфиг
-func migrateConfig(originalData []byte) {
-	needsSave := false
+// ShutdownTimeout возвращает время ожидания активных запросов при остановке (по умолчанию 30 сек)
+func ShutdownTimeout() time.Duration {
+	timeout := Current().Soft_Settings.Shutdown_timeout
+	if timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(timeout) * time.Second
+}
 
-	// Парсим оригинальный JSON как map для проверки наличия полей
-	var rawConfig map[string]json.RawMessage
-	if err := json.Unmarshal(originalData, &rawConfig); err != nil {
-		return
+// LogRotation возвращает настройки ротации логов для tools
+func LogRotation() tools.LogRotation {
+	rotation := Current().Soft_Settings.Log_rotation
+	return tools.LogRotation{
+		MaxSizeMB:  rotation.Max_size_mb,
+		Interval:   rotation.Interval,
+		MaxFiles:   rotation.Max_files,
+		MaxAgeDays: rotation.Max_age_days,
+		Compress:   rotation.Compress,
 	}
+}
 
-	// Проверяем Site_www
-	if rawSites, ok := rawConfig["Site_www"]; ok {
-		var sites []map[string]interface{}
-		if err := json.Unmarshal(rawSites, &sites); err == nil {
-			for _, site := range sites {
-				if _, exists := site["AutoCreateSSL"]; !exists {
-					needsSave = true
-					break
-				}
-			}
-		}
+// applyLogSettings передаёт настройки логирования в tools (значения уже проверены Validate)
+func applyLogSettings() {
+	settings := Current().Soft_Settings
+
+	defaultLevel, _ := tools.ParseLogLevel(settings.Log_level)
+	levels := make(map[string]tools.LogLevel, len(settings.Log_levels))
+	for subsystem, name := range settings.Log_levels {
+		levels[subsystem], _ = tools.ParseLogLevel(name)
 	}
 
-	// Проверяем Proxy_Service
-	if rawProxies, ok := rawConfig["Proxy_Service"]; ok {
-		var proxies []map[string]interface{}
-		if err := json.Unmarshal(rawProxies, &proxies); err == nil {
-			for _, proxy := range proxies {
-				if _, exists := proxy["AutoCreateSSL"]; !exists {
-					needsSave = true
-					break
-				}
-			}
-		}
+	format := settings.Log_format
+	if format == "" {
+		format = "text"
 	}
 
-	// Проверяем Soft_Settings на наличие ACME_enabled
-	if rawSettings, ok := rawConfig["Soft_Settings"]; ok {
-		var settings map[string]interface{}
-		if err := json.Unmarshal(rawSettings, &settings); err == nil {
-			if _, exists := settings["ACME_enabled"]; !exists {
-				needsSave = true
-			}
+	tools.SetLogRotation(LogRotation())
+	tools.SetLogLevels(defaultLevel, levels)
+	tools.SetLogFormat(format)
+}
+
+// SiteDir возвращает папку сайта WebServer/www/<host> (здесь лежит корневой vAccess.conf)
+func SiteDir(host string) string {
+	return filepath.Join("WebServer", "www", host)
+}
+
+// ResolveDocumentRoot переводит document_root в путь к корню документов
+// Абсолютный путь используется как есть, относительный считается от папки сайта
+func ResolveDocumentRoot(host string, documentRoot string) string {
+	if strings.TrimSpace(documentRoot) == "" {
+		return filepath.Join(SiteDir(host), "public_www")
+	}
+	if filepath.IsAbs(documentRoot) {
+		return filepath.Clean(documentRoot)
+	}
+	return filepath.Join(SiteDir(host), documentRoot)
+}
+
+// DocumentRoot возвращает корень документов сайта из конфигурации
+func DocumentRoot(host string) string {
+	for _, site := range Current().Site_www {
+		if site.Host == host {
+			return ResolveDocumentRoot(host, site.Document_root)
 		}
 	}
+	return ResolveDocumentRoot(host, "")
+}
+
+// ListenAddresses возвращает пары (сеть, адрес) для привязки слушателя на указанном порту
+func ListenAddresses(port int) [][2]string {
+	settings := Current().Soft_Settings
+	portStr := strconv.Itoa(port)
 
-	// Если нужно обновить - сохраняем конфиг с новыми полями
-	if needsSave {
-		tools.Logs_file(0, "JSON", "🔄 Миграция конфига: добавляем новые поля", "logs_config.log", true)
-		saveConfig()
+	addresses := [][2]string{{"tcp4", net.JoinHostPort(settings.Listen_address, portStr)}}
+	if settings.Listen_ipv6 {
+		addresses = append(addresses, [2]string{"tcp6", net.JoinHostPort(settings.Listen_address_v6, portStr)})
 	}
+	return addresses
 }
 
-// saveConfig сохраняет текущий конфиг в файл
-func saveConfig() {
-	formattedJSON, err := json.MarshalIndent(ConfigData, "", "    ")
+// LoadConfig читает и проверяет config.json
+// При ошибках валидации текущая конфигурация остаётся без изменений
+func LoadConfig() error {
+
+	data, err := os.ReadFile(ConfigPath)
+
 	if err != nil {
-		tools.Logs_file(1, "JSON", "Ошибка форматирования конфига: "+err.Error(), "logs_config.log", true)
-		return
+		configLog.Console().Error("Ошибка загрузки конфигурационного файла", "path", ConfigPath, "error", err)
+		return err
+	}
+	configLog.Console().Info("config.json успешно загружен")
+
+	// Обновляем старый файл до текущей версии схемы (с резервной копией)
+	if data, err = migrateConfig(data); err != nil {
+		return err
+	}
+
+	// Проверяем схему до применения
+	if errs := Validate(data); len(errs) > 0 {
+		logValidationErrors(errs)
+		return errs
 	}
 
-	err = os.WriteFile(ConfigPath, formattedJSON, 0644)
+	// Разбираем в новую структуру, чтобы удалённые из файла поля не оставались от прошлой загрузки
+	var newConfig Config
+	if err := json.Unmarshal(data, &newConfig); err != nil {
+		configLog.Console().Error("Ошибка парсинга конфигурационного файла", "path", ConfigPath, "error", err)
+		return err
+	}
+	updateMutex.Lock()
+	current.Store(&newConfig)
+	updateMutex.Unlock()
+	applyLogSettings()
+	configLog.Console().Info("config.json успешно прочитан", "version", newConfig.Config_version)
+
+	println()
+
+	return nil
+}
+
+// logValidationErrors выводит каждую ошибку валидации отдельной строкой
+func logValidationErrors(errs ValidationErrors) {
+	configLog.Console().Error("config.json не прошёл проверку, конфигурация не применена", "errors", len(errs))
+	for _, err := range errs {
+		configLog.Console().Error("Ошибка в config.json", "field", err.Path, "error", err.Message)
+	}
+}
+
+// Snapshot возвращает независимую копию текущей конфигурации
+func Snapshot() Config {
+	var snapshot Config
+	data, err := json.Marshal(Current())
 	if err != nil {
-		tools.Logs_file(1, "JSON", "Ошибка сохранения конфига: "+err.Error(), "logs_config.log", true)
-		return
+		return snapshot
+	}
+	json.Unmarshal(data, &snapshot)
+	return snapshot
+}
+
+// migrateConfig прогоняет недостающие миграции и возвращает обновлённые данные
+func migrateConfig(data []byte) ([]byte, error) {
+	plan, err := PlanMigrations(data)
+	if err != nil {
+		// Синтаксические ошибки подробно покажет Validate
+		return data, nil
 	}
 
-	tools.Logs_file(0, "JSON", "✅ Конфиг обновлён с новыми полями", "logs_config.log", true)
+	if plan.FromVersion > CurrentConfigVersion() {
+		configLog.Console().Warn("config.json новее поддерживаемой версии", "version", plan.FromVersion, "supported", CurrentConfigVersion())
+		return data, nil
+	}
+	if !plan.NeedsUpgrade() {
+		return data, nil
+	}
+
+	if err := applyMigrationPlan(data, plan); err != nil {
+		configLog.Console().Error("Ошибка миграции конфига", "error", err)
+		return data, err
+	}
+	return plan.Data, nil
 }
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"reflect"
+)
+
+// ConfigDiff описывает, что изменилось между двумя версиями конфигурации
+type ConfigDiff struct {
+	SitesAdded     []string // Хосты новых сайтов
+	SitesRemoved   []string // Хосты удалённых сайтов
+	SitesChanged   []string // Хосты сайтов с изменёнными полями (alias, status, root_file...)
+	ProxiesAdded   []string // Домены новых прокси
+	ProxiesRemoved []string // Домены удалённых прокси
+	ProxiesChanged []string // Домены прокси с изменёнными полями (Enable, LocalPort...)
+	ProxyToggled   bool     // Изменён глобальный флаг proxy_enabled
+	StreamsAdded   []string // Имена новых Stream_Service
+	StreamsRemoved []string // Имена удалённых Stream_Service
+	StreamsChanged []string // Имена Stream_Service с изменёнными полями
+	ListenChanged  bool     // Изменились адреса/порты HTTP или HTTPS
+	PHPChanged     bool     // Изменились хост, порт или размер пула PHP
+	MySQLChanged   bool     // Изменились хост или порт MySQL
+	ACMEEnabled    bool     // ACME был выключен и стал включён
+	MetricsChanged bool     // Изменился адрес слушателя метрик
+	SSLRequested   bool     // Появились домены с AutoCreateSSL
+}
+
+// Empty возвращает true, если конфигурации не отличаются
+func (d ConfigDiff) Empty() bool {
+	return len(d.SitesAdded) == 0 && len(d.SitesRemoved) == 0 && len(d.SitesChanged) == 0 &&
+		len(d.ProxiesAdded) == 0 && len(d.ProxiesRemoved) == 0 && len(d.ProxiesChanged) == 0 &&
+		len(d.StreamsAdded) == 0 && len(d.StreamsRemoved) == 0 && len(d.StreamsChanged) == 0 &&
+		!d.ProxyToggled && !d.ListenChanged && !d.PHPChanged && !d.MySQLChanged &&
+		!d.ACMEEnabled && !d.SSLRequested && !d.MetricsChanged
+}
+
+// SitesTouched возвращает true, если изменился список сайтов или их настройки
+func (d ConfigDiff) SitesTouched() bool {
+	return len(d.SitesAdded) > 0 || len(d.SitesRemoved) > 0 || len(d.SitesChanged) > 0
+}
+
+// ProxiesTouched возвращает true, если изменились прокси или их глобальный флаг
+func (d ConfigDiff) ProxiesTouched() bool {
+	return len(d.ProxiesAdded) > 0 || len(d.ProxiesRemoved) > 0 || len(d.ProxiesChanged) > 0 || d.ProxyToggled
+}
+
+// StreamsTouched возвращает true, если изменились Stream_Service
+func (d ConfigDiff) StreamsTouched() bool {
+	return len(d.StreamsAdded) > 0 || len(d.StreamsRemoved) > 0 || len(d.StreamsChanged) > 0
+}
+
+// Diff сравнивает старую и новую конфигурацию
+func Diff(oldConfig, newConfig Config) ConfigDiff {
+	var diff ConfigDiff
+
+	// Сайты сравниваем по host
+	oldSites := make(map[string]Site_www)
+	for _, site := range oldConfig.Site_www {
+		oldSites[site.Host] = site
+	}
+	newSites := make(map[string]Site_www)
+	for _, site := range newConfig.Site_www {
+		newSites[site.Host] = site
+
+		oldSite, exists := oldSites[site.Host]
+		if !exists {
+			diff.SitesAdded = append(diff.SitesAdded, site.Host)
+			if site.AutoCreateSSL {
+				diff.SSLRequested = true
+			}
+			continue
+		}
+		if !reflect.DeepEqual(oldSite, site) {
+			diff.SitesChanged = append(diff.SitesChanged, site.Host)
+			if site.AutoCreateSSL && (!oldSite.AutoCreateSSL || !reflect.DeepEqual(oldSite.Alias, site.Alias)) {
+				diff.SSLRequested = true
+			}
+		}
+	}
+	for _, site := range oldConfig.Site_www {
+		if _, exists := newSites[site.Host]; !exists {
+			diff.SitesRemoved = append(diff.SitesRemoved, site.Host)
+		}
+	}
+
+	// Прокси сравниваем по ExternalDomain
+	oldProxies := make(map[string]Proxy_Service)
+	for _, proxy := range oldConfig.Proxy_Service {
+		oldProxies[proxy.ExternalDomain] = proxy
+	}
+	newProxies := make(map[string]Proxy_Service)
+	for _, proxy := range newConfig.Proxy_Service {
+		newProxies[proxy.ExternalDomain] = proxy
+
+		oldProxy, exists := oldProxies[proxy.ExternalDomain]
+		if !exists {
+			diff.ProxiesAdded = append(diff.ProxiesAdded, proxy.ExternalDomain)
+			if proxy.AutoCreateSSL {
+				diff.SSLRequested = true
+			}
+			continue
+		}
+		if !reflect.DeepEqual(oldProxy, proxy) {
+			diff.ProxiesChanged = append(diff.ProxiesChanged, proxy.ExternalDomain)
+			if proxy.AutoCreateSSL && !oldProxy.AutoCreateSSL {
+				diff.SSLRequested = true
+			}
+		}
+	}
+	for _, proxy := range oldConfig.Proxy_Service {
+		if _, exists := newProxies[proxy.ExternalDomain]; !exists {
+			diff.ProxiesRemoved = append(diff.ProxiesRemoved, proxy.ExternalDomain)
+		}
+	}
+
+	// Stream_Service сравниваем по name
+	oldStreams := make(map[string]Stream_Service)
+	for _, stream := range oldConfig.Stream_Service {
+		oldStreams[stream.Name] = stream
+	}
+	newStreams := make(map[string]Stream_Service)
+	for _, stream := range newConfig.Stream_Service {
+		newStreams[stream.Name] = stream
+
+		oldStream, exists := oldStreams[stream.Name]
+		if !exists {
+			diff.StreamsAdded = append(diff.StreamsAdded, stream.Name)
+		} else if !reflect.DeepEqual(oldStream, stream) {
+			diff.StreamsChanged = append(diff.StreamsChanged, stream.Name)
+		}
+	}
+	for _, stream := range oldConfig.Stream_Service {
+		if _, exists := newStreams[stream.Name]; !exists {
+			diff.StreamsRemoved = append(diff.StreamsRemoved, stream.Name)
+		}
+	}
+
+	// Глобальные настройки
+	oldSettings := oldConfig.Soft_Settings
+	newSettings := newConfig.Soft_Settings
+
+	diff.ProxyToggled = oldSettings.Proxy_enabled != newSettings.Proxy_enabled
+
+	diff.ListenChanged = oldSettings.Listen_address != newSettings.Listen_address ||
+		oldSettings.Listen_ipv6 != newSettings.Listen_ipv6 ||
+		oldSettings.Listen_address_v6 != newSettings.Listen_address_v6 ||
+		!reflect.DeepEqual(oldSettings.Http_ports, newSettings.Http_ports) ||
+		!reflect.DeepEqual(oldSettings.Https_ports, newSettings.Https_ports)
+
+	diff.PHPChanged = oldSettings.Php_host != newSettings.Php_host ||
+		oldSettings.Php_port != newSettings.Php_port ||
+		oldSettings.Php_workers != newSettings.Php_workers
+
+	diff.MySQLChanged = oldSettings.Mysql_host != newSettings.Mysql_host ||
+		oldSettings.Mysql_port != newSettings.Mysql_port
+
+	diff.ACMEEnabled = !oldSettings.ACME_enabled && newSettings.ACME_enabled
+
+	diff.MetricsChanged = oldSettings.Metrics_listen != newSettings.Metrics_listen
+
+	return diff
+}
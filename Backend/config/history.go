@@ -0,0 +1,437 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	tools "vServer/Backend/tools"
+)
+
+// HistoryDir папка со снимками config.json и vAccess.conf
+var HistoryDir = "WebServer/config_history"
+
+// historyRoot - файлы внутри этой папки хранятся в истории под относительным путём
+var historyRoot = "WebServer"
+
+const historyTimeFormat = "20060102-150405.000"
+const historySourceFile = "source.path" // Путь исходного файла для восстановления
+const historySnapExt = ".snap"
+
+var historyMutex sync.Mutex
+
+// historyNow - время снимка (в тестах фиксируется)
+var historyNow = time.Now
+
+// HistoryEntry - один снимок файла
+type HistoryEntry struct {
+	ID   string    `json:"id"`   // Идентификатор снимка: <файл>@<время>
+	File string    `json:"file"` // Путь исходного файла
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// HistoryLimit возвращает, сколько снимков хранится на каждый файл (по умолчанию 50)
+func HistoryLimit() int {
+	limit := Current().Soft_Settings.History_limit
+	if limit <= 0 {
+		return 50
+	}
+	return limit
+}
+
+// Update меняет копию конфигурации, проверяет и сохраняет её в config.json
+// Новая конфигурация начинает действовать только после успешной записи
+func Update(change func(cfg *Config)) error {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	cfg := Snapshot()
+	change(&cfg)
+
+	data, err := json.MarshalIndent(cfg, "", "    ")
+	if err != nil {
+		return fmt.Errorf("ошибка форматирования JSON: %w", err)
+	}
+
+	if errs := Validate(data); len(errs) > 0 {
+		return fmt.Errorf("конфигурация не прошла проверку: %w", errs)
+	}
+
+	if err := SaveFile(ConfigPath, data); err != nil {
+		return err
+	}
+	current.Store(&cfg)
+	return nil
+}
+
+// SaveFile атомарно записывает конфигурационный файл и сохраняет снимок в истории
+// При первой записи в историю попадает и прежнее содержимое, чтобы его можно было вернуть
+func SaveFile(path string, data []byte) error {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	dir, key := historyDirFor(path)
+
+	if snapshots, _ := listSnapshotFiles(dir); len(snapshots) == 0 {
+		if old, err := os.ReadFile(path); err == nil {
+			writeSnapshot(dir, path, old)
+		}
+	}
+
+	if err := tools.WriteFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+
+	// Файл уже записан - ошибка истории не должна отменять сохранение
+	if err := writeSnapshot(dir, path, data); err != nil {
+		configLog.Warn("Не удалось сохранить снимок", "file", key, "error", err)
+		return nil
+	}
+	pruneSnapshots(dir, HistoryLimit())
+	return nil
+}
+
+// History возвращает снимки (новые первыми). file - путь или ключ файла, "" - все файлы
+func History(file string) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+
+	err := filepath.WalkDir(HistoryDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != historySourceFile {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		key := historyKeyOf(dir)
+		source, err := readSource(dir)
+		if err != nil {
+			return nil
+		}
+		if file != "" && filepath.ToSlash(file) != key && filepath.ToSlash(file) != source {
+			return nil
+		}
+
+		snapshots, _ := listSnapshotFiles(dir)
+		for _, name := range snapshots {
+			stamp := strings.TrimSuffix(name, historySnapExt)
+			snapTime, err := time.ParseInLocation(historyTimeFormat, stamp, time.Local)
+			if err != nil {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			entries = append(entries, HistoryEntry{
+				ID:   key + "@" + stamp,
+				File: source,
+				Time: snapTime,
+				Size: info.Size(),
+			})
+		}
+		return nil
+	})
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].Time.After(entries[j].Time)
+	})
+	return entries, err
+}
+
+// HistoryDiff сравнивает снимок id со снимком otherID ("" - с текущим файлом)
+func HistoryDiff(id string, otherID string) (string, error) {
+	oldData, source, err := readSnapshot(id)
+	if err != nil {
+		return "", err
+	}
+
+	newName := source + " (текущий)"
+	var newData []byte
+	if otherID == "" {
+		newData, err = os.ReadFile(source)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	} else {
+		newName = otherID
+		newData, _, err = readSnapshot(otherID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return textDiff(id, newName, oldData, newData), nil
+}
+
+// RestoreSnapshot возвращает файл к состоянию снимка и возвращает путь восстановленного файла
+// Восстановление само попадает в историю, поэтому его можно отменить
+func RestoreSnapshot(id string) (string, error) {
+	data, source, err := readSnapshot(id)
+	if err != nil {
+		return "", err
+	}
+
+	// Старый config.json проверяем в том виде, в каком его загрузит LoadConfig
+	if IsConfigPath(source) {
+		plan, err := PlanMigrations(data)
+		if err != nil {
+			return "", fmt.Errorf("снимок повреждён: %w", err)
+		}
+		if errs := Validate(plan.Data); len(errs) > 0 {
+			return "", fmt.Errorf("снимок не прошёл проверку: %w", errs)
+		}
+	}
+
+	if err := SaveFile(source, data); err != nil {
+		return "", err
+	}
+
+	configLog.Console().Info("Восстановлен снимок", "id", id)
+	return source, nil
+}
+
+// historyDirFor возвращает папку истории и ключ для файла
+func historyDirFor(path string) (string, string) {
+	key := filepath.ToSlash(filepath.Clean(path))
+
+	absPath, errPath := filepath.Abs(path)
+	absRoot, errRoot := filepath.Abs(historyRoot)
+	if errPath == nil && errRoot == nil {
+		if rel, err := filepath.Rel(absRoot, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+			key = filepath.ToSlash(rel)
+		} else {
+			// Файл вне WebServer/ - сохраняем под полным путём
+			key = "external/" + strings.TrimLeft(strings.ReplaceAll(filepath.ToSlash(absPath), ":", ""), "/")
+		}
+	}
+
+	return filepath.Join(HistoryDir, filepath.FromSlash(key)), key
+}
+
+// historyKeyOf восстанавливает ключ файла по папке истории
+func historyKeyOf(dir string) string {
+	rel, err := filepath.Rel(HistoryDir, dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// readSnapshot читает снимок по идентификатору
+func readSnapshot(id string) ([]byte, string, error) {
+	at := strings.LastIndex(id, "@")
+	if at <= 0 {
+		return nil, "", fmt.Errorf("некорректный идентификатор снимка: %s", id)
+	}
+	key, stamp := id[:at], id[at+1:]
+
+	// Идентификатор приходит из GUI/CLI - не даём выйти за пределы папки истории
+	dir := filepath.Join(HistoryDir, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(HistoryDir, dir); err != nil || strings.HasPrefix(rel, "..") || strings.ContainsAny(stamp, `/\`) {
+		return nil, "", fmt.Errorf("некорректный идентификатор снимка: %s", id)
+	}
+
+	source, err := readSource(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("снимок не найден: %s", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, stamp+historySnapExt))
+	if err != nil {
+		return nil, "", fmt.Errorf("снимок не найден: %s", id)
+	}
+	return data, source, nil
+}
+
+func readSource(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, historySourceFile))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeSnapshot сохраняет содержимое, если оно отличается от последнего снимка
+func writeSnapshot(dir string, source string, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, historySourceFile), []byte(filepath.ToSlash(source)), 0644); err != nil {
+		return err
+	}
+
+	snapshots, _ := listSnapshotFiles(dir)
+	if len(snapshots) > 0 {
+		last, err := os.ReadFile(filepath.Join(dir, snapshots[len(snapshots)-1]))
+		if err == nil && bytes.Equal(last, data) {
+			return nil
+		}
+	}
+
+	// Несколько записей за одну миллисекунду (в том числе из CLI и сервера одновременно)
+	// получают соседние метки времени: имя занимается атомарно через O_EXCL
+	stamp := historyNow()
+	for {
+		name := stamp.Format(historyTimeFormat) + historySnapExt
+		file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			stamp = stamp.Add(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+}
+
+// listSnapshotFiles возвращает имена снимков от старых к новым
+func listSnapshotFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), historySnapExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// pruneSnapshots удаляет самые старые снимки сверх лимита
+func pruneSnapshots(dir string, limit int) {
+	snapshots, err := listSnapshotFiles(dir)
+	if err != nil || len(snapshots) <= limit {
+		return
+	}
+	for _, name := range snapshots[:len(snapshots)-limit] {
+		os.Remove(filepath.Join(dir, name))
+	}
+}
+
+// IsConfigPath возвращает true, если путь указывает на config.json
+func IsConfigPath(path string) bool {
+	absPath, errPath := filepath.Abs(path)
+	absConfig, errConfig := filepath.Abs(ConfigPath)
+	return errPath == nil && errConfig == nil && absPath == absConfig
+}
+
+// textDiff строит построчное сравнение в формате unified diff (3 строки контекста)
+func textDiff(oldName, newName string, oldData, newData []byte) string {
+	a := splitLines(oldData)
+	b := splitLines(newData)
+
+	// Таблица LCS: конфиги небольшие, квадратичной сложности достаточно
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte // ' ', '-', '+'
+		text string
+		line int // Номер строки в старом файле
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i + 1})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i + 1})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	changed := false
+	for k := 0; k < len(lines); {
+		if lines[k].op == ' ' {
+			k++
+			continue
+		}
+		changed = true
+
+		// Границы блока изменений с контекстом; близкие блоки объединяются
+		start := max(k-context, 0)
+		end := k
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].op == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = next
+		}
+
+		fmt.Fprintf(&out, "@@ строка %d @@\n", lines[start].line)
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		k = end
+	}
+
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
+
+func splitLines(data []byte) []string {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useTempHistory переносит историю и config.json во временную папку
+func useTempHistory(t *testing.T, limit int) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	savedHistory, savedRoot, savedConfigPath, savedConfig := HistoryDir, historyRoot, ConfigPath, Current()
+	HistoryDir = filepath.Join(dir, "config_history")
+	historyRoot = dir
+	ConfigPath = filepath.Join(dir, "config.json")
+	cfg := &Config{}
+	cfg.Soft_Settings.History_limit = limit
+	current.Store(cfg)
+
+	t.Cleanup(func() {
+		HistoryDir, historyRoot, ConfigPath = savedHistory, savedRoot, savedConfigPath
+		current.Store(savedConfig)
+	})
+	return dir
+}
+
+// historyContents возвращает содержимое снимков файла, новые первыми
+func historyContents(t *testing.T, file string) []string {
+	t.Helper()
+	entries, err := History(file)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	var contents []string
+	for _, entry := range entries {
+		data, _, err := readSnapshot(entry.ID)
+		if err != nil {
+			t.Fatalf("readSnapshot(%s): %v", entry.ID, err)
+		}
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+func TestSaveFileKeepsPreviousContent(t *testing.T) {
+	dir := useTempHistory(t, 0)
+	path := filepath.Join(dir, "www", "a.local", "vAccess.conf")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("v1"), 0644)
+
+	if err := SaveFile(path, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveFile(path, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "v2" {
+		t.Errorf("файл: %q", data)
+	}
+	// Прежнее содержимое сохранено, повторная запись того же не создаёт снимок
+	if got := fmt.Sprint(historyContents(t, path)); got != "[v2 v1]" {
+		t.Errorf("снимки: %s", got)
+	}
+}
+
+func TestWriteSnapshotSameMillisecond(t *testing.T) {
+	dir := useTempHistory(t, 0)
+	snapDir := filepath.Join(HistoryDir, "config.json")
+	source := filepath.Join(dir, "config.json")
+
+	// Одновременные записи (как из CLI и сервера) не затирают друг друга
+	const writers = 20
+	frozen := time.Now()
+	historyNow = func() time.Time { return frozen }
+	defer func() { historyNow = time.Now }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := writeSnapshot(snapDir, source, []byte(fmt.Sprintf("snapshot %d", i))); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, content := range historyContents(t, "config.json") {
+		seen[content] = true
+	}
+	if len(seen) != writers {
+		t.Errorf("сохранено %d разных снимков из %d", len(seen), writers)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	dir := useTempHistory(t, 0)
+	first := filepath.Join(dir, "www", "a.local", "vAccess.conf")
+	second := filepath.Join(dir, "www", "b.local", "vAccess.conf")
+	os.MkdirAll(filepath.Dir(first), 0755)
+	os.MkdirAll(filepath.Dir(second), 0755)
+	SaveFile(first, []byte("a"))
+	SaveFile(second, []byte("b"))
+
+	cases := []struct {
+		file string
+		want string
+	}{
+		{"", "2"},
+		{"www/a.local/vAccess.conf", "[a]"},
+		{second, "[b]"},
+		{"www/c.local/vAccess.conf", "[]"},
+	}
+	for _, tc := range cases {
+		if tc.file == "" {
+			entries, _ := History("")
+			if fmt.Sprint(len(entries)) != tc.want {
+				t.Errorf("все файлы: %d снимков", len(entries))
+			}
+			continue
+		}
+		if got := fmt.Sprint(historyContents(t, tc.file)); got != tc.want {
+			t.Errorf("%s: %s, ожидали %s", tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	dir := useTempHistory(t, 0)
+	path := filepath.Join(dir, "www", "a.local", "vAccess.conf")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	SaveFile(path, []byte("v1"))
+	SaveFile(path, []byte("v2"))
+
+	entries, _ := History(path)
+	restored, err := RestoreSnapshot(entries[len(entries)-1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != filepath.ToSlash(path) {
+		t.Errorf("восстановлен %s", restored)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v1" {
+		t.Errorf("файл: %q", data)
+	}
+	// Восстановление само попадает в историю
+	if got := fmt.Sprint(historyContents(t, path)); got != "[v1 v2 v1]" {
+		t.Errorf("снимки: %s", got)
+	}
+
+	for _, id := range []string{"", "nope", "../../etc@1", "www/a.local/vAccess.conf@../../x", "www/a.local/vAccess.conf@20000101-000000.000"} {
+		if _, err := RestoreSnapshot(id); err == nil {
+			t.Errorf("%q: ожидали ошибку", id)
+		}
+	}
+}
+
+func TestRestoreSnapshotValidatesConfig(t *testing.T) {
+	useTempHistory(t, 0)
+	SaveFile(ConfigPath, []byte(`{"Site_www": "не массив"}`))
+	SaveFile(ConfigPath, []byte(`{}`))
+
+	entries, _ := History(ConfigPath)
+	if _, err := RestoreSnapshot(entries[len(entries)-1].ID); err == nil {
+		t.Error("ожидали ошибку проверки снимка config.json")
+	}
+	if data, _ := os.ReadFile(ConfigPath); string(data) != "{}" {
+		t.Errorf("config.json изменён: %q", data)
+	}
+}
+
+func TestSaveFilePrunesSnapshots(t *testing.T) {
+	dir := useTempHistory(t, 3)
+	path := filepath.Join(dir, "www", "a.local", "vAccess.conf")
+	os.MkdirAll(filepath.Dir(path), 0755)
+
+	for i := 1; i <= 5; i++ {
+		if err := SaveFile(path, []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fmt.Sprint(historyContents(t, path)); got != "[v5 v4 v3]" {
+		t.Errorf("снимки: %s", got)
+	}
+}
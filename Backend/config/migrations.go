@@ -0,0 +1,253 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackupDir папка для резервных копий config.json перед миграцией
+var BackupDir = "WebServer/config_backup"
+
+// migration - один шаг обновления схемы config.json
+// Apply работает с «сырым» JSON и возвращает список внесённых изменений
+type migration struct {
+	Version     int
+	Description string
+	Apply       func(raw map[string]interface{}) []string
+}
+
+// migrations - упорядоченный список шагов. Новый шаг добавляется в конец
+// со следующим номером версии, старые шаги никогда не меняются
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "AutoCreateSSL для сайтов и прокси, ACME_enabled",
+		Apply: func(raw map[string]interface{}) []string {
+			var changes []string
+			for i, site := range objectList(raw, "Site_www") {
+				changes = setDefault(site, fmt.Sprintf("Site_www[%d]", i), "AutoCreateSSL", false, changes)
+			}
+			for i, proxy := range objectList(raw, "Proxy_Service") {
+				changes = setDefault(proxy, fmt.Sprintf("Proxy_Service[%d]", i), "AutoCreateSSL", false, changes)
+			}
+			settings := objectField(raw, "Soft_Settings")
+			changes = setDefault(settings, "Soft_Settings", "ACME_enabled", false, changes)
+			return changes
+		},
+	},
+	{
+		Version:     2,
+		Description: "Адреса и порты HTTP/HTTPS",
+		Apply: func(raw map[string]interface{}) []string {
+			var changes []string
+			settings := objectField(raw, "Soft_Settings")
+			changes = setDefault(settings, "Soft_Settings", "http_ports", []interface{}{80}, changes)
+			changes = setDefault(settings, "Soft_Settings", "https_ports", []interface{}{443}, changes)
+			changes = setDefault(settings, "Soft_Settings", "listen_address", "", changes)
+			changes = setDefault(settings, "Soft_Settings", "listen_ipv6", false, changes)
+			changes = setDefault(settings, "Soft_Settings", "listen_address_v6", "", changes)
+			return changes
+		},
+	},
+	{
+		Version:     3,
+		Description: "Время корректной остановки и размер пула PHP",
+		Apply: func(raw map[string]interface{}) []string {
+			var changes []string
+			settings := objectField(raw, "Soft_Settings")
+			changes = setDefault(settings, "Soft_Settings", "shutdown_timeout", 30, changes)
+			changes = setDefault(settings, "Soft_Settings", "php_workers", 4, changes)
+			return changes
+		},
+	},
+	{
+		Version:     4,
+		Description: "История снимков конфигурации",
+		Apply: func(raw map[string]interface{}) []string {
+			settings := objectField(raw, "Soft_Settings")
+			return setDefault(settings, "Soft_Settings", "history_limit", 50, nil)
+		},
+	},
+	{
+		Version:     5,
+		Description: "Ротация и хранение логов",
+		Apply: func(raw map[string]interface{}) []string {
+			settings := objectField(raw, "Soft_Settings")
+			return setDefault(settings, "Soft_Settings", "log_rotation", map[string]interface{}{
+				"max_size_mb":  10,
+				"interval":     "daily",
+				"max_files":    10,
+				"max_age_days": 30,
+				"compress":     true,
+			}, nil)
+		},
+	},
+	{
+		Version:     6,
+		Description: "Уровни и формат логов",
+		Apply: func(raw map[string]interface{}) []string {
+			var changes []string
+			settings := objectField(raw, "Soft_Settings")
+			changes = setDefault(settings, "Soft_Settings", "log_level", "info", changes)
+			changes = setDefault(settings, "Soft_Settings", "log_format", "text", changes)
+			return changes
+		},
+	},
+	{
+		Version:     7,
+		Description: "Адрес Prometheus метрик",
+		Apply: func(raw map[string]interface{}) []string {
+			settings := objectField(raw, "Soft_Settings")
+			return setDefault(settings, "Soft_Settings", "metrics_listen", "", nil)
+		},
+	},
+}
+
+// CurrentConfigVersion - версия схемы, которую понимает эта сборка
+func CurrentConfigVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationPlan - результат прогона миграций над config.json
+type MigrationPlan struct {
+	FromVersion int      `json:"from_version"`
+	ToVersion   int      `json:"to_version"`
+	Steps       []string `json:"steps"`   // Применённые шаги
+	Changes     []string `json:"changes"` // Конкретные изменения полей
+	Data        []byte   `json:"-"`       // Обновлённый JSON
+}
+
+// NeedsUpgrade возвращает true, если файл старше текущей схемы
+func (p MigrationPlan) NeedsUpgrade() bool {
+	return p.FromVersion < p.ToVersion
+}
+
+// PlanMigrations применяет недостающие шаги к JSON в памяти, не трогая файл
+func PlanMigrations(data []byte) (MigrationPlan, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return MigrationPlan{}, err
+	}
+
+	plan := MigrationPlan{
+		FromVersion: rawVersion(raw),
+		ToVersion:   CurrentConfigVersion(),
+		Data:        data,
+	}
+	if !plan.NeedsUpgrade() {
+		plan.ToVersion = plan.FromVersion
+		return plan, nil
+	}
+
+	for _, step := range migrations {
+		if step.Version <= plan.FromVersion {
+			continue
+		}
+		plan.Steps = append(plan.Steps, fmt.Sprintf("v%d: %s", step.Version, step.Description))
+		plan.Changes = append(plan.Changes, step.Apply(raw)...)
+	}
+
+	raw["config_version"] = plan.ToVersion
+	plan.Changes = append(plan.Changes, fmt.Sprintf("config_version = %d", plan.ToVersion))
+
+	migrated, err := json.MarshalIndent(raw, "", "    ")
+	if err != nil {
+		return plan, err
+	}
+	plan.Data = migrated
+	return plan, nil
+}
+
+// MigrateFile обновляет config.json до текущей версии
+// В режиме dryRun только возвращает план, файл не меняется
+func MigrateFile(dryRun bool) (MigrationPlan, error) {
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	plan, err := PlanMigrations(data)
+	if err != nil || dryRun || !plan.NeedsUpgrade() {
+		return plan, err
+	}
+
+	return plan, applyMigrationPlan(data, plan)
+}
+
+// applyMigrationPlan делает резервную копию и записывает обновлённый конфиг
+func applyMigrationPlan(original []byte, plan MigrationPlan) error {
+	// Сломанный результат миграции не должен попасть на диск
+	if errs := Validate(plan.Data); len(errs) > 0 {
+		return fmt.Errorf("результат миграции не прошёл проверку: %w", errs)
+	}
+
+	backupPath, err := backupConfig(original, plan.FromVersion)
+	if err != nil {
+		return fmt.Errorf("не удалось создать резервную копию: %w", err)
+	}
+	configLog.Console().Info("Создана резервная копия конфига", "path", backupPath)
+
+	if err := SaveFile(ConfigPath, plan.Data); err != nil {
+		return fmt.Errorf("ошибка сохранения конфига: %w", err)
+	}
+
+	configLog.Console().Info("Конфиг обновлён", "from", plan.FromVersion, "to", plan.ToVersion)
+	for _, step := range plan.Steps {
+		configLog.Info("Применён шаг миграции", "step", step)
+	}
+	return nil
+}
+
+// backupConfig сохраняет исходный файл в BackupDir с версией и временем
+func backupConfig(data []byte, version int) (string, error) {
+	if err := os.MkdirAll(BackupDir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("config_v%d_%s.json", version, time.Now().Format("20060102-150405"))
+	backupPath := filepath.Join(BackupDir, name)
+	return backupPath, os.WriteFile(backupPath, data, 0644)
+}
+
+// rawVersion читает config_version (отсутствие поля = версия 0)
+func rawVersion(raw map[string]interface{}) int {
+	if version, ok := raw["config_version"].(float64); ok {
+		return int(version)
+	}
+	return 0
+}
+
+// objectField возвращает вложенный объект, создавая его при отсутствии
+func objectField(raw map[string]interface{}, key string) map[string]interface{} {
+	if object, ok := raw[key].(map[string]interface{}); ok {
+		return object
+	}
+	object := make(map[string]interface{})
+	raw[key] = object
+	return object
+}
+
+// objectList возвращает элементы-объекты массива
+func objectList(raw map[string]interface{}, key string) []map[string]interface{} {
+	items, _ := raw[key].([]interface{})
+	objects := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if object, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// setDefault добавляет ключ со значением по умолчанию, если его нет
+func setDefault(object map[string]interface{}, path string, key string, value interface{}, changes []string) []string {
+	if _, exists := object[key]; exists {
+		return changes
+	}
+	object[key] = value
+
+	encoded, _ := json.Marshal(value)
+	return append(changes, fmt.Sprintf("%s.%s = %s", path, key, encoded))
+}
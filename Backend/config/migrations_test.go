@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "migrations", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// equalJSON сравнивает JSON без учёта форматирования и порядка ключей
+func equalJSON(t *testing.T, got, want []byte) bool {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("результат не JSON: %v", err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("ожидаемый файл не JSON: %v", err)
+	}
+	return reflect.DeepEqual(gotValue, wantValue)
+}
+
+func TestPlanMigrationsFixtures(t *testing.T) {
+	cases := []struct {
+		before, after string
+		from          int
+	}{
+		{"v0.json", "v0_migrated.json", 0},
+		{"v4_custom.json", "v4_custom_migrated.json", 4}, // Заданные пользователем значения не меняются
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.before, func(t *testing.T) {
+			plan, err := PlanMigrations(readFixture(t, tc.before))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.FromVersion != tc.from || plan.ToVersion != CurrentConfigVersion() || !plan.NeedsUpgrade() {
+				t.Errorf("версии: %d → %d", plan.FromVersion, plan.ToVersion)
+			}
+			if len(plan.Steps) != CurrentConfigVersion()-tc.from {
+				t.Errorf("шаги: %v", plan.Steps)
+			}
+			if !equalJSON(t, plan.Data, readFixture(t, tc.after)) {
+				t.Errorf("результат миграции отличается от %s:\n%s", tc.after, plan.Data)
+			}
+			if errs := Validate(plan.Data); errs != nil {
+				t.Errorf("результат не прошёл проверку: %v", errs)
+			}
+		})
+	}
+}
+
+func TestPlanMigrationsIdempotent(t *testing.T) {
+	for _, name := range []string{"v0.json", "v4_custom.json"} {
+		t.Run(name, func(t *testing.T) {
+			first, err := PlanMigrations(readFixture(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Обновлённый файл больше не мигрирует и не меняется
+			second, err := PlanMigrations(first.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second.NeedsUpgrade() || len(second.Changes) != 0 || !bytes.Equal(second.Data, first.Data) {
+				t.Errorf("повторный прогон изменил конфиг: %v", second.Changes)
+			}
+
+			// Каждый шаг, применённый второй раз, ничего не меняет
+			var raw map[string]interface{}
+			json.Unmarshal(first.Data, &raw)
+			for _, step := range migrations {
+				if changes := step.Apply(raw); len(changes) != 0 {
+					t.Errorf("шаг v%d повторно изменил: %v", step.Version, changes)
+				}
+			}
+			if again, _ := json.Marshal(raw); !equalJSON(t, again, first.Data) {
+				t.Error("повторное применение шагов изменило JSON")
+			}
+		})
+	}
+}
+
+func TestPlanMigrationsCurrentAndNewer(t *testing.T) {
+	for _, data := range []string{
+		`{"config_version": 7, "Soft_Settings": {}}`,
+		`{"config_version": 99, "Soft_Settings": {}}`,
+	} {
+		plan, err := PlanMigrations([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.NeedsUpgrade() || len(plan.Steps) != 0 || string(plan.Data) != data {
+			t.Errorf("%s: файл не должен меняться, шаги %v", data, plan.Steps)
+		}
+	}
+
+	if _, err := PlanMigrations([]byte(`{"config_version": `)); err == nil {
+		t.Error("ожидали ошибку разбора")
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	dir := useTempHistory(t, 0)
+	savedBackup := BackupDir
+	BackupDir = filepath.Join(dir, "config_backup")
+	defer func() { BackupDir = savedBackup }()
+
+	original := readFixture(t, "v0.json")
+	os.WriteFile(ConfigPath, original, 0644)
+
+	// dry-run только показывает план
+	plan, err := MigrateFile(true)
+	if err != nil || !plan.NeedsUpgrade() {
+		t.Fatalf("dry-run: %v, %+v", err, plan)
+	}
+	if data, _ := os.ReadFile(ConfigPath); !bytes.Equal(data, original) {
+		t.Error("dry-run изменил config.json")
+	}
+	if _, err := os.Stat(BackupDir); !os.IsNotExist(err) {
+		t.Error("dry-run создал резервную копию")
+	}
+
+	if _, err := MigrateFile(false); err != nil {
+		t.Fatal(err)
+	}
+	migrated, _ := os.ReadFile(ConfigPath)
+	if !equalJSON(t, migrated, readFixture(t, "v0_migrated.json")) {
+		t.Errorf("config.json после миграции:\n%s", migrated)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(BackupDir, "config_v0_*.json"))
+	if len(backups) != 1 {
+		t.Fatalf("резервные копии: %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); !bytes.Equal(data, original) {
+		t.Error("резервная копия не совпадает с исходным файлом")
+	}
+
+	// Второй запуск ничего не делает
+	if plan, err := MigrateFile(false); err != nil || plan.NeedsUpgrade() {
+		t.Errorf("повторная миграция: %v, %+v", err, plan)
+	}
+	if again, _ := os.ReadFile(ConfigPath); !bytes.Equal(again, migrated) {
+		t.Error("повторная миграция изменила config.json")
+	}
+}
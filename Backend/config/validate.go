@@ -0,0 +1,805 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	tools "vServer/Backend/tools"
+)
+
+// indexPattern переводит индексы encoding/json (Site_www.0.host) в вид Site_www[0].host
+var indexPattern = regexp.MustCompile(`\.(\d+)`)
+
+// ValidationError - ошибка в конкретном поле конфигурации
+type ValidationError struct {
+	Path    string `json:"path"`    // Путь к полю, например Site_www[1].alias[0]
+	Message string `json:"message"` // Описание проблемы
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors - список ошибок валидации
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "; ")
+}
+
+// validator накапливает ошибки
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate проверяет JSON конфигурации до загрузки или сохранения
+// Возвращает nil, если конфигурация корректна
+func Validate(data []byte) ValidationErrors {
+	v := &validator{}
+
+	// 1. Синтаксис JSON
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := offsetToLineCol(data, syntaxErr.Offset)
+			v.add("", "синтаксическая ошибка JSON в строке %d, позиция %d: %s", line, col, syntaxErr.Error())
+		} else {
+			v.add("", "конфигурация должна быть JSON-объектом: %s", err.Error())
+		}
+		return v.errs
+	}
+
+	// 2. Неизвестные ключи
+	v.checkUnknownKeys(raw, reflect.TypeOf(Config{}), "")
+
+	// 3. Типы значений
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			v.add(indexPattern.ReplaceAllString(typeErr.Field, "[$1]"), "ожидается %s, получено %s", typeErr.Type.String(), typeErr.Value)
+		} else {
+			v.add("", "%s", err.Error())
+		}
+		return v.errs
+	}
+
+	// 4. Семантика
+	v.checkConfig(cfg)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// ValidateConfig проверяет структуру конфигурации (для путей сохранения из Go кода)
+func ValidateConfig(cfg Config) ValidationErrors {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ValidationErrors{{Message: err.Error()}}
+	}
+	return Validate(data)
+}
+
+// checkUnknownKeys сверяет ключи JSON с json-тегами структуры
+func (v *validator) checkUnknownKeys(raw interface{}, t reflect.Type, path string) {
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return // Несоответствие типа покажет json.Unmarshal
+		}
+
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = field.Type
+		}
+
+		// Сортируем ключи, чтобы порядок ошибок был стабильным
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			value := object[key]
+			fieldType, known := fields[key]
+			if !known {
+				v.add(joinPath(path, key), "неизвестный ключ")
+				continue
+			}
+			v.checkUnknownKeys(value, fieldType, joinPath(path, key))
+		}
+
+	case reflect.Ptr:
+		v.checkUnknownKeys(raw, t.Elem(), path)
+
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			v.checkUnknownKeys(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+// checkConfig проверяет значения и связи между сайтами и прокси
+func (v *validator) checkConfig(cfg Config) {
+	settings := cfg.Soft_Settings
+
+	// Порты сервисов
+	v.checkPort("Soft_Settings.php_port", settings.Php_port)
+	v.checkPort("Soft_Settings.mysql_port", settings.Mysql_port)
+	if settings.Php_workers < 0 {
+		v.add("Soft_Settings.php_workers", "не может быть отрицательным")
+	}
+	if settings.Php_port > 0 && settings.Php_port+phpWorkers(settings)-1 > 65535 {
+		v.add("Soft_Settings.php_workers", "порты пула PHP выходят за 65535")
+	}
+	if settings.Shutdown_timeout < 0 {
+		v.add("Soft_Settings.shutdown_timeout", "не может быть отрицательным")
+	}
+	if settings.History_limit < 0 {
+		v.add("Soft_Settings.history_limit", "не может быть отрицательным")
+	}
+	v.checkLogRotation(settings.Log_rotation)
+	v.checkLogLevel("Soft_Settings.log_level", settings.Log_level)
+	for subsystem, level := range settings.Log_levels {
+		v.checkLogLevel("Soft_Settings.log_levels."+subsystem, level)
+	}
+	switch settings.Log_format {
+	case "", "text", "json":
+	default:
+		v.add("Soft_Settings.log_format", "должен быть 'text' или 'json', получено '%s'", settings.Log_format)
+	}
+
+	// Порты HTTP/HTTPS: корректные, без повторов и без пересечений между собой
+	listenPorts := make(map[int]string)
+	portLists := []struct {
+		name  string
+		ports []int
+	}{{"http_ports", settings.Http_ports}, {"https_ports", settings.Https_ports}}
+	for _, list := range portLists {
+		for i, port := range list.ports {
+			path := "Soft_Settings." + list.name + "[" + strconv.Itoa(i) + "]"
+			if !v.checkPort(path, port) {
+				continue
+			}
+			if other, exists := listenPorts[port]; exists {
+				v.add(path, "порт %d уже используется в %s", port, other)
+				continue
+			}
+			listenPorts[port] = path
+		}
+	}
+	if len(settings.Http_ports) == 0 {
+		listenPorts[80] = "http_ports"
+	}
+	if len(settings.Https_ports) == 0 {
+		listenPorts[443] = "https_ports"
+	}
+	v.checkMetricsListen(settings, listenPorts)
+
+	// Сайты: уникальные host и alias
+	hosts := make(map[string]string)   // host -> путь
+	aliases := make(map[string]string) // точный alias -> путь
+	for i, site := range cfg.Site_www {
+		path := "Site_www[" + strconv.Itoa(i) + "]"
+		host := strings.ToLower(strings.TrimSpace(site.Host))
+
+		if host == "" {
+			v.add(path+".host", "обязательное поле")
+		} else if other, exists := hosts[host]; exists {
+			v.add(path+".host", "хост '%s' уже указан в %s", site.Host, other)
+		} else {
+			hosts[host] = path + ".host"
+		}
+
+		if site.Status != "active" && site.Status != "inactive" {
+			v.add(path+".status", "должен быть 'active' или 'inactive', получено '%s'", site.Status)
+		}
+
+		v.checkListen(path+".listen", site.Listen, listenPorts)
+		v.checkErrorPages(path+".error_pages", site.Error_pages)
+		v.checkAccessLog(path+".access_log", site.Access_log)
+
+		for j, rule := range site.Autoindex {
+			if !strings.HasPrefix(rule, "/") {
+				v.add(path+".autoindex["+strconv.Itoa(j)+"]", "путь должен начинаться с '/'")
+			}
+		}
+	}
+
+	for i, site := range cfg.Site_www {
+		path := "Site_www[" + strconv.Itoa(i) + "]"
+		for j, alias := range site.Alias {
+			aliasPath := path + ".alias[" + strconv.Itoa(j) + "]"
+			alias = strings.ToLower(strings.TrimSpace(alias))
+
+			if alias == "" {
+				v.add(aliasPath, "пустой alias")
+				continue
+			}
+			if strings.Contains(alias, "*") {
+				continue // Wildcard пересекаются намеренно, приоритет у точных совпадений
+			}
+			if hostPath, exists := hosts[alias]; exists && hostPath != path+".host" {
+				v.add(aliasPath, "alias '%s' совпадает с хостом сайта %s", alias, strings.TrimSuffix(hostPath, ".host"))
+				continue
+			}
+			if other, exists := aliases[alias]; exists {
+				v.add(aliasPath, "alias '%s' уже используется в %s", alias, other)
+				continue
+			}
+			aliases[alias] = aliasPath
+		}
+	}
+
+	// Прокси: уникальные домены, корректные адреса и отсутствие перекрытия сайтов
+	domains := make(map[string]string)
+	for i, proxy := range cfg.Proxy_Service {
+		path := "Proxy_Service[" + strconv.Itoa(i) + "]"
+		domain := strings.ToLower(strings.TrimSpace(proxy.ExternalDomain))
+
+		if domain == "" {
+			v.add(path+".ExternalDomain", "обязательное поле")
+		} else if other, exists := domains[domain]; exists {
+			v.add(path+".ExternalDomain", "домен '%s' уже указан в %s", proxy.ExternalDomain, other)
+		} else {
+			domains[domain] = path + ".ExternalDomain"
+		}
+		if strings.Contains(domain, "*") || strings.HasPrefix(domain, "~") {
+			v.add(path+".ExternalDomain", "шаблоны доменов задаются в alias")
+		}
+		v.checkProxyAliases(path, proxy, domains, hosts, aliases)
+
+		// LocalAddress/LocalPort обязательны, только если не заданы upstreams или маршруты
+		if len(proxy.Upstreams) == 0 && (len(proxy.Routes) == 0 || proxy.LocalAddress != "" || proxy.LocalPort != "") {
+			if strings.TrimSpace(proxy.LocalAddress) == "" {
+				v.add(path+".LocalAddress", "обязательное поле")
+			}
+			if port, err := strconv.Atoi(proxy.LocalPort); err != nil {
+				v.add(path+".LocalPort", "'%s' не является номером порта", proxy.LocalPort)
+			} else {
+				v.checkPort(path+".LocalPort", port)
+			}
+		}
+		v.checkBalance(path+".balance", proxy.Balance)
+		v.checkUpstreams(path+".upstreams", proxy.Upstreams)
+		v.checkRoutes(path+".routes", proxy.Routes, hosts)
+		if proxy.Preserve_host && proxy.Upstream_host != "" {
+			v.add(path+".upstream_host", "нельзя задавать вместе с preserve_host")
+		}
+		v.checkHeaderRules(path+".request_headers", proxy.Request_headers)
+		v.checkHeaderRules(path+".response_headers", proxy.Response_headers)
+		v.checkResponseRewrite(path+".response_rewrite", proxy.Response_rewrite)
+		v.checkUpstreamTLS(path+".upstream_tls", proxy)
+		v.checkHealthCheck(path+".health_check", proxy.Health_check)
+
+		v.checkListen(path+".listen", proxy.Listen, listenPorts)
+		v.checkErrorPages(path+".error_pages", proxy.Error_pages)
+		v.checkAccessLog(path+".access_log", proxy.Access_log)
+		for name, value := range map[string]int{
+			"dial_timeout":         proxy.Dial_timeout,
+			"response_timeout":     proxy.Response_timeout,
+			"idle_timeout":         proxy.Idle_timeout,
+			"max_idle_conns":       proxy.Max_idle_conns,
+			"upgrade_idle_timeout": proxy.Upgrade_idle_timeout,
+			"max_fails":            proxy.Max_fails,
+			"fail_timeout":         proxy.Fail_timeout,
+			"max_tries":            proxy.Max_tries,
+		} {
+			if value < 0 {
+				v.add(path+"."+name, "не может быть отрицательным")
+			}
+		}
+
+		// Включённый прокси обрабатывается раньше сайтов и перекрывает их
+		if proxy.Enable && domain != "" {
+			if hostPath, exists := hosts[domain]; exists {
+				v.add(path+".ExternalDomain", "прокси перекрывает сайт %s", strings.TrimSuffix(hostPath, ".host"))
+			} else if aliasPath, exists := aliases[domain]; exists {
+				v.add(path+".ExternalDomain", "прокси перекрывает alias %s", aliasPath)
+			}
+		}
+	}
+
+	v.checkStreams(cfg.Stream_Service, settings, listenPorts, hosts, aliases, domains)
+}
+
+// checkStreams проверяет Stream_Service: уникальные имена, адрес сервиса, свободные порты
+// и SNI-домены, которые не должны забирать HTTPS у сайтов и прокси
+func (v *validator) checkStreams(streams []Stream_Service, settings Soft_Settings, listenPorts map[int]string, hosts, aliases, domains map[string]string) {
+	names := make(map[string]string)
+	ports := map[string]map[int]string{"tcp": {}, "udp": {}}
+	sniNames := make(map[string]string)
+
+	for i, stream := range streams {
+		path := "Stream_Service[" + strconv.Itoa(i) + "]"
+
+		if strings.TrimSpace(stream.Name) == "" {
+			v.add(path+".name", "обязательное поле")
+		} else if other, exists := names[stream.Name]; exists {
+			v.add(path+".name", "имя '%s' уже указано в %s", stream.Name, other)
+		} else {
+			names[stream.Name] = path
+		}
+
+		protocol := stream.Protocol
+		switch protocol {
+		case "":
+			protocol = "tcp"
+		case "tcp", "udp":
+		default:
+			v.add(path+".protocol", "должен быть 'tcp' или 'udp', получено '%s'", stream.Protocol)
+			continue
+		}
+
+		if host, port, err := net.SplitHostPort(stream.Upstream); err != nil || host == "" {
+			v.add(path+".upstream", "ожидается адрес вида '127.0.0.1:3306', получено '%s'", stream.Upstream)
+		} else if number, err := strconv.Atoi(port); err != nil {
+			v.add(path+".upstream", "'%s' не является номером порта", port)
+		} else {
+			v.checkPort(path+".upstream", number)
+		}
+
+		if protocol == "udp" {
+			if stream.Tls {
+				v.add(path+".tls", "не поддерживается для udp")
+			}
+			if len(stream.Sni) > 0 {
+				v.add(path+".sni", "не поддерживается для udp")
+			}
+		}
+
+		if stream.Listen == 0 && len(stream.Sni) == 0 {
+			v.add(path+".listen", "нужен listen или sni")
+		} else if stream.Listen != 0 && v.checkPort(path+".listen", stream.Listen) && stream.Enable {
+			port := stream.Listen
+			switch {
+			case ports[protocol][port] != "":
+				v.add(path+".listen", "порт %d/%s уже используется в %s", port, protocol, ports[protocol][port])
+			case protocol == "tcp" && listenPorts[port] != "":
+				v.add(path+".listen", "порт %d уже используется в %s", port, listenPorts[port])
+			case protocol == "tcp" && port == settings.Mysql_port:
+				v.add(path+".listen", "порт %d уже используется MySQL", port)
+			case protocol == "tcp" && settings.Php_port > 0 && port >= settings.Php_port && port < settings.Php_port+phpWorkers(settings):
+				v.add(path+".listen", "порт %d входит в пул PHP", port)
+			case protocol == "tcp" && strings.HasSuffix(settings.Metrics_listen, ":"+strconv.Itoa(port)):
+				v.add(path+".listen", "порт %d уже используется metrics_listen", port)
+			default:
+				ports[protocol][port] = path + ".listen"
+			}
+		}
+
+		for j, name := range stream.Sni {
+			sniPath := path + ".sni[" + strconv.Itoa(j) + "]"
+			name = strings.ToLower(strings.TrimSpace(name))
+
+			if name == "" {
+				v.add(sniPath, "пустой домен")
+				continue
+			}
+			if !stream.Enable || strings.Contains(name, "*") {
+				continue
+			}
+			if other, exists := sniNames[name]; exists {
+				v.add(sniPath, "домен '%s' уже указан в %s", name, other)
+			} else if hostPath, exists := hosts[name]; exists {
+				v.add(sniPath, "домен '%s' обслуживает сайт %s", name, strings.TrimSuffix(hostPath, ".host"))
+			} else if aliasPath, exists := aliases[name]; exists {
+				v.add(sniPath, "домен '%s' указан в alias %s", name, aliasPath)
+			} else if proxyPath, exists := domains[name]; exists {
+				v.add(sniPath, "домен '%s' обслуживает прокси %s", name, strings.TrimSuffix(proxyPath, ".ExternalDomain"))
+			} else {
+				sniNames[name] = sniPath
+			}
+		}
+
+		for name, value := range map[string]int{
+			"max_connections": stream.Max_connections,
+			"connect_timeout": stream.Connect_timeout,
+			"idle_timeout":    stream.Idle_timeout,
+		} {
+			if value < 0 {
+				v.add(path+"."+name, "не может быть отрицательным")
+			}
+		}
+	}
+}
+
+// checkProxyAliases проверяет alias прокси: regex должен компилироваться, точные имена -
+// не повторяться среди прокси и не перекрывать сайты. Wildcard и regex пересекаются намеренно
+func (v *validator) checkProxyAliases(path string, proxy Proxy_Service, domains, hosts, aliases map[string]string) {
+	for i, alias := range proxy.Alias {
+		aliasPath := path + ".alias[" + strconv.Itoa(i) + "]"
+		alias = strings.ToLower(strings.TrimSpace(alias))
+
+		switch {
+		case alias == "":
+			v.add(aliasPath, "пустой alias")
+			continue
+		case strings.HasPrefix(alias, "~"):
+			if _, err := regexp.Compile(alias[1:]); err != nil {
+				v.add(aliasPath, "некорректное регулярное выражение: %v", err)
+			}
+			continue
+		case strings.Contains(alias, "*"):
+			continue
+		}
+
+		if other, exists := domains[alias]; exists {
+			v.add(aliasPath, "alias '%s' уже используется в %s", alias, other)
+			continue
+		}
+		domains[alias] = aliasPath
+
+		if !proxy.Enable {
+			continue
+		}
+		if hostPath, exists := hosts[alias]; exists {
+			v.add(aliasPath, "alias прокси перекрывает сайт %s", strings.TrimSuffix(hostPath, ".host"))
+		} else if siteAliasPath, exists := aliases[alias]; exists {
+			v.add(aliasPath, "alias прокси перекрывает alias %s", siteAliasPath)
+		}
+	}
+}
+
+// checkPort проверяет диапазон порта
+func (v *validator) checkPort(path string, port int) bool {
+	if port < 1 || port > 65535 {
+		v.add(path, "порт %d вне диапазона 1-65535", port)
+		return false
+	}
+	return true
+}
+
+// checkListen проверяет, что сайт/прокси привязан только к реально открытым портам
+func (v *validator) checkListen(path string, listen []int, listenPorts map[int]string) {
+	for i, port := range listen {
+		if _, exists := listenPorts[port]; !exists {
+			v.add(path+"["+strconv.Itoa(i)+"]", "порт %d не указан в http_ports/https_ports", port)
+		}
+	}
+}
+
+// checkErrorPages проверяет ключи error_pages: код 400-599, класс 4xx/5xx или default
+func (v *validator) checkErrorPages(path string, pages map[string]string) {
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "." + key
+		switch key {
+		case "4xx", "5xx", "default":
+		default:
+			if code, err := strconv.Atoi(key); err != nil || code < 400 || code > 599 {
+				v.add(keyPath, "ожидается код ответа 400-599, 4xx, 5xx или default")
+				continue
+			}
+		}
+		if strings.TrimSpace(pages[key]) == "" {
+			v.add(keyPath, "пустой путь к странице ошибки")
+		}
+	}
+}
+
+// checkBalance проверяет стратегию балансировки
+func (v *validator) checkBalance(path string, balance string) {
+	switch balance {
+	case "", "round_robin", "least_conn", "ip_hash":
+	default:
+		v.add(path, "должен быть 'round_robin', 'least_conn' или 'ip_hash', получено '%s'", balance)
+	}
+}
+
+// checkUpstreams проверяет бэкенды балансировки: host:port без повторов, неотрицательный вес
+func (v *validator) checkUpstreams(path string, upstreams []Proxy_Upstream) {
+	seen := make(map[string]string)
+	for i, upstream := range upstreams {
+		upstreamPath := path + "[" + strconv.Itoa(i) + "]"
+
+		host, portStr, err := net.SplitHostPort(upstream.Address)
+		if err != nil || strings.TrimSpace(host) == "" {
+			v.add(upstreamPath+".address", "'%s' не является адресом host:port", upstream.Address)
+		} else if port, err := strconv.Atoi(portStr); err != nil {
+			v.add(upstreamPath+".address", "'%s' не является номером порта", portStr)
+		} else {
+			v.checkPort(upstreamPath+".address", port)
+		}
+
+		if other, exists := seen[upstream.Address]; exists {
+			v.add(upstreamPath+".address", "бэкенд '%s' уже указан в %s", upstream.Address, other)
+		} else {
+			seen[upstream.Address] = upstreamPath
+		}
+
+		if upstream.Weight < 0 {
+			v.add(upstreamPath+".weight", "не может быть отрицательным")
+		}
+	}
+}
+
+// checkRoutes проверяет маршруты прокси: путь, назначение (upstreams или существующий сайт) и переписывание пути
+func (v *validator) checkRoutes(path string, routes []Proxy_Route, hosts map[string]string) {
+	paths := make(map[string]string)
+	for i, route := range routes {
+		routePath := path + "[" + strconv.Itoa(i) + "]"
+
+		switch {
+		case !strings.HasPrefix(route.Path, "/"):
+			v.add(routePath+".path", "должен начинаться с '/', получено '%s'", route.Path)
+		case strings.Contains(strings.TrimSuffix(route.Path, "*"), "*"):
+			v.add(routePath+".path", "'*' допускается только в конце пути")
+		}
+		if other, exists := paths[route.Path]; exists {
+			v.add(routePath+".path", "маршрут '%s' уже указан в %s", route.Path, other)
+		} else {
+			paths[route.Path] = routePath
+		}
+
+		switch {
+		case route.Site != "" && len(route.Upstreams) > 0:
+			v.add(routePath, "укажите либо upstreams, либо site")
+		case route.Site == "" && len(route.Upstreams) == 0:
+			v.add(routePath, "нужны upstreams или site")
+		case route.Site != "":
+			if _, exists := hosts[strings.ToLower(route.Site)]; !exists {
+				v.add(routePath+".site", "сайт '%s' не найден в Site_www", route.Site)
+			}
+		}
+		v.checkUpstreams(routePath+".upstreams", route.Upstreams)
+
+		if route.Strip_prefix && route.Rewrite != "" {
+			v.add(routePath+".rewrite", "нельзя задавать вместе со strip_prefix")
+		}
+		if route.Rewrite != "" && !strings.HasPrefix(route.Rewrite, "/") {
+			v.add(routePath+".rewrite", "должен начинаться с '/', получено '%s'", route.Rewrite)
+		}
+	}
+}
+
+// Заголовки, которыми управляет сам прокси: соединение, длина тела и Host (см. preserve_host)
+var reservedProxyHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Upgrade", "Te", "Trailer",
+	"Transfer-Encoding", "Content-Length", "Host",
+}
+
+// checkHeaderRules проверяет имена заголовков в правилах add/set/remove
+func (v *validator) checkHeaderRules(path string, rules *Proxy_Headers) {
+	if rules == nil {
+		return
+	}
+
+	check := func(namePath string, name string) {
+		if !isHeaderName(name) {
+			v.add(namePath, "'%s' не является именем заголовка", name)
+			return
+		}
+		for _, reserved := range reservedProxyHeaders {
+			if strings.EqualFold(name, reserved) {
+				v.add(namePath, "заголовок '%s' нельзя менять правилами", name)
+				return
+			}
+		}
+	}
+
+	for _, section := range []struct {
+		key    string
+		values map[string]string
+	}{{"add", rules.Add}, {"set", rules.Set}} {
+		names := make([]string, 0, len(section.values))
+		for name := range section.values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			check(path+"."+section.key+"."+name, name)
+			if strings.ContainsAny(section.values[name], "\r\n") {
+				v.add(path+"."+section.key+"."+name, "значение не может содержать перевод строки")
+			}
+		}
+	}
+	for i, name := range rules.Remove {
+		check(path+".remove["+strconv.Itoa(i)+"]", name)
+	}
+}
+
+// isHeaderName: непустой token из RFC 7230
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		isAlnum := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !isAlnum && !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkResponseRewrite проверяет замены в теле ответа и список типов
+func (v *validator) checkResponseRewrite(path string, rewrite *Proxy_Rewrite) {
+	if rewrite == nil {
+		return
+	}
+
+	for i, rule := range rewrite.Body {
+		rulePath := path + ".body[" + strconv.Itoa(i) + "]"
+		if rule.Search == "" {
+			v.add(rulePath+".search", "не может быть пустым")
+			continue
+		}
+		if rule.Regex {
+			if _, err := regexp.Compile(rule.Search); err != nil {
+				v.add(rulePath+".search", "некорректное регулярное выражение: %v", err)
+			}
+		}
+	}
+	for i, contentType := range rewrite.Content_types {
+		if !strings.Contains(contentType, "/") || strings.ContainsAny(contentType, " ;") {
+			v.add(path+".content_types["+strconv.Itoa(i)+"]", "'%s' не является MIME-типом", contentType)
+		}
+	}
+}
+
+// checkUpstreamTLS проверяет версию TLS и пару клиентского сертификата.
+// Сами файлы читаются при первом запросе к бэкенду, ошибки чтения попадают в лог прокси
+func (v *validator) checkUpstreamTLS(path string, proxy Proxy_Service) {
+	settings := proxy.Upstream_tls
+	if settings == nil {
+		return
+	}
+
+	if !proxy.ServiceHTTPSuse {
+		v.add(path, "действует только при ServiceHTTPSuse: true")
+	}
+	switch settings.Min_version {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		v.add(path+".min_version", "неизвестная версия '%s', допустимо: 1.0, 1.1, 1.2, 1.3", settings.Min_version)
+	}
+	if settings.Cert_file != "" && settings.Key_file == "" {
+		v.add(path+".key_file", "обязателен вместе с cert_file")
+	}
+	if settings.Key_file != "" && settings.Cert_file == "" {
+		v.add(path+".cert_file", "обязателен вместе с key_file")
+	}
+	if settings.Ca_file != "" && !settings.Verify {
+		v.add(path+".ca_file", "используется только при verify: true")
+	}
+}
+
+// checkHealthCheck проверяет путь, интервалы и ожидаемый код активной проверки
+func (v *validator) checkHealthCheck(path string, check *Proxy_Health) {
+	if check == nil {
+		return
+	}
+
+	if !strings.HasPrefix(check.Path, "/") {
+		v.add(path+".path", "должен начинаться с '/', получено '%s'", check.Path)
+	}
+	if check.Interval < 0 {
+		v.add(path+".interval", "не может быть отрицательным")
+	}
+	if check.Timeout < 0 {
+		v.add(path+".timeout", "не может быть отрицательным")
+	}
+	if check.Expected_status != 0 && (check.Expected_status < 100 || check.Expected_status > 599) {
+		v.add(path+".expected_status", "код %d вне диапазона 100-599", check.Expected_status)
+	}
+}
+
+// checkAccessLog проверяет формат access-лога
+func (v *validator) checkAccessLog(path string, format string) {
+	switch format {
+	case "", "combined", "json", "off":
+	default:
+		v.add(path, "должен быть 'combined', 'json' или 'off', получено '%s'", format)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// offsetToLineCol переводит смещение в байтах в номер строки и позиции
+func offsetToLineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// checkLogRotation проверяет настройки ротации логов
+func (v *validator) checkLogRotation(rotation Log_Rotation) {
+	switch rotation.Interval {
+	case "", "daily", "hourly":
+	default:
+		v.add("Soft_Settings.log_rotation.interval", "должен быть 'daily', 'hourly' или пустым, получено '%s'", rotation.Interval)
+	}
+	if rotation.Max_size_mb < 0 {
+		v.add("Soft_Settings.log_rotation.max_size_mb", "не может быть отрицательным")
+	}
+	if rotation.Max_files < 0 {
+		v.add("Soft_Settings.log_rotation.max_files", "не может быть отрицательным")
+	}
+	if rotation.Max_age_days < 0 {
+		v.add("Soft_Settings.log_rotation.max_age_days", "не может быть отрицательным")
+	}
+}
+
+// checkMetricsListen проверяет адрес /metrics: host:port, порт не занят HTTP/HTTPS, PHP и MySQL
+func (v *validator) checkMetricsListen(settings Soft_Settings, listenPorts map[int]string) {
+	const path = "Soft_Settings.metrics_listen"
+	if settings.Metrics_listen == "" {
+		return
+	}
+
+	_, portText, err := net.SplitHostPort(settings.Metrics_listen)
+	if err != nil {
+		v.add(path, "ожидается адрес вида '127.0.0.1:9180', получено '%s'", settings.Metrics_listen)
+		return
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		v.add(path, "'%s' не является номером порта", portText)
+		return
+	}
+	if !v.checkPort(path, port) {
+		return
+	}
+
+	if other, exists := listenPorts[port]; exists {
+		v.add(path, "порт %d уже используется в %s", port, other)
+	} else if port == settings.Mysql_port {
+		v.add(path, "порт %d уже используется MySQL", port)
+	} else if settings.Php_port > 0 && port >= settings.Php_port && port < settings.Php_port+phpWorkers(settings) {
+		v.add(path, "порт %d входит в пул PHP", port)
+	}
+}
+
+// checkLogLevel проверяет имя уровня логирования
+func (v *validator) checkLogLevel(path string, level string) {
+	if _, err := tools.ParseLogLevel(level); err != nil {
+		v.add(path, "должен быть 'debug', 'info', 'warn' или 'error', получено '%s'", level)
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// validConfig - минимальная корректная конфигурация: сайт и включённый прокси
+func validConfig() Config {
+	return Config{
+		Config_version: CurrentConfigVersion(),
+		Site_www: []Site_www{
+			{Name: "Сайт", Host: "site.local", Status: "active", Root_file: "index.html"},
+		},
+		Soft_Settings: Soft_Settings{Php_port: 8000, Mysql_port: 3306},
+		Proxy_Service: []Proxy_Service{
+			{Enable: true, ExternalDomain: "app.local", LocalAddress: "127.0.0.1", LocalPort: "3000"},
+		},
+	}
+}
+
+// errorPaths возвращает отсортированные пути ошибок
+func errorPaths(errs ValidationErrors) []string {
+	paths := []string{}
+	for _, err := range errs {
+		paths = append(paths, err.Path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestValidateValidConfig(t *testing.T) {
+	if errs := ValidateConfig(validConfig()); errs != nil {
+		t.Fatalf("корректная конфигурация не прошла проверку: %v", errs)
+	}
+}
+
+func TestValidateProxy(t *testing.T) {
+	proxy := "Proxy_Service[0]"
+	cases := []struct {
+		name   string
+		change func(cfg *Config)
+		want   []string
+	}{
+		{"upstreams вместо LocalAddress", func(cfg *Config) {
+			p := &cfg.Proxy_Service[0]
+			p.LocalAddress, p.LocalPort = "", ""
+			p.Upstreams = []Proxy_Upstream{{Address: "127.0.0.1:3001"}, {Address: "127.0.0.1:3002", Weight: 3}}
+		}, nil},
+		{"некорректные upstreams", func(cfg *Config) {
+			cfg.Proxy_Service[0].Upstreams = []Proxy_Upstream{
+				{Address: "127.0.0.1"},
+				{Address: ":3000"},
+				{Address: "127.0.0.1:http"},
+				{Address: "127.0.0.1:70000"},
+				{Address: "127.0.0.1:3001", Weight: -1},
+				{Address: "127.0.0.1:3001"},
+			}
+		}, []string{
+			proxy + ".upstreams[0].address",
+			proxy + ".upstreams[1].address",
+			proxy + ".upstreams[2].address",
+			proxy + ".upstreams[3].address",
+			proxy + ".upstreams[4].weight",
+			proxy + ".upstreams[5].address",
+		}},
+		{"неизвестная балансировка", func(cfg *Config) { cfg.Proxy_Service[0].Balance = "random" }, []string{proxy + ".balance"}},
+		{"без бэкенда и маршрутов", func(cfg *Config) {
+			cfg.Proxy_Service[0].LocalAddress, cfg.Proxy_Service[0].LocalPort = "", ""
+		}, []string{proxy + ".LocalAddress", proxy + ".LocalPort"}},
+		{"только маршруты - LocalAddress не нужен", func(cfg *Config) {
+			p := &cfg.Proxy_Service[0]
+			p.LocalAddress, p.LocalPort = "", ""
+			p.Routes = []Proxy_Route{{Path: "/api/*", Upstreams: []Proxy_Upstream{{Address: "127.0.0.1:4000"}}}}
+		}, nil},
+		{"маршруты и частично заданный LocalAddress", func(cfg *Config) {
+			p := &cfg.Proxy_Service[0]
+			p.LocalPort = ""
+			p.Routes = []Proxy_Route{{Path: "/api/*", Upstreams: []Proxy_Upstream{{Address: "127.0.0.1:4000"}}}}
+		}, []string{proxy + ".LocalPort"}},
+		{"некорректные маршруты", func(cfg *Config) {
+			cfg.Proxy_Service[0].Routes = []Proxy_Route{
+				{Path: "api", Site: "site.local"},
+				{Path: "/a*b", Site: "site.local"},
+				{Path: "/ws"},
+				{Path: "/ws", Site: "site.local", Upstreams: []Proxy_Upstream{{Address: "127.0.0.1:4000"}}},
+				{Path: "/blog/*", Site: "missing.local"},
+				{Path: "/old/*", Site: "SITE.local", Strip_prefix: true, Rewrite: "/new"},
+				{Path: "/x/*", Site: "site.local", Rewrite: "new"},
+				{Path: "/up/*", Upstreams: []Proxy_Upstream{{Address: "bad"}}},
+			}
+		}, []string{
+			proxy + ".routes[0].path",
+			proxy + ".routes[1].path",
+			proxy + ".routes[2]",
+			proxy + ".routes[3]",
+			proxy + ".routes[3].path",
+			proxy + ".routes[4].site",
+			proxy + ".routes[5].rewrite",
+			proxy + ".routes[6].rewrite",
+			proxy + ".routes[7].upstreams[0].address",
+		}},
+		{"upstream_tls без HTTPS к бэкенду", func(cfg *Config) {
+			cfg.Proxy_Service[0].Upstream_tls = &Proxy_TLS{Verify: true}
+		}, []string{proxy + ".upstream_tls"}},
+		{"некорректный upstream_tls", func(cfg *Config) {
+			cfg.Proxy_Service[0].ServiceHTTPSuse = true
+			cfg.Proxy_Service[0].Upstream_tls = &Proxy_TLS{Min_version: "1.4", Cert_file: "client.pem", Ca_file: "ca.pem"}
+		}, []string{proxy + ".upstream_tls.ca_file", proxy + ".upstream_tls.key_file", proxy + ".upstream_tls.min_version"}},
+		{"ключ без сертификата", func(cfg *Config) {
+			cfg.Proxy_Service[0].ServiceHTTPSuse = true
+			cfg.Proxy_Service[0].Upstream_tls = &Proxy_TLS{Verify: true, Min_version: "1.3", Key_file: "client.key"}
+		}, []string{proxy + ".upstream_tls.cert_file"}},
+		{"регулярное выражение в замене тела", func(cfg *Config) {
+			cfg.Proxy_Service[0].Response_rewrite = &Proxy_Rewrite{Body: []Proxy_Replace{
+				{Search: "id=(\\d+", Regex: true},
+				{Search: ""},
+				{Search: "(", Replace: "x"},
+			}}
+		}, []string{proxy + ".response_rewrite.body[0].search", proxy + ".response_rewrite.body[1].search"}},
+		{"прокси перекрывает сайт", func(cfg *Config) { cfg.Proxy_Service[0].ExternalDomain = "Site.local" }, []string{proxy + ".ExternalDomain"}},
+		{"выключенный прокси сайт не перекрывает", func(cfg *Config) {
+			cfg.Proxy_Service[0].ExternalDomain = "site.local"
+			cfg.Proxy_Service[0].Enable = false
+		}, nil},
+		{"отрицательные таймауты", func(cfg *Config) {
+			cfg.Proxy_Service[0].Dial_timeout = -1
+			cfg.Proxy_Service[0].Max_tries = -1
+		}, []string{proxy + ".dial_timeout", proxy + ".max_tries"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validConfig()
+			tc.change(&cfg)
+			errs := ValidateConfig(cfg)
+			if got := errorPaths(errs); !slices.Equal(got, append([]string{}, tc.want...)) {
+				t.Errorf("получили %v\nожидали  %v\nошибки: %v", got, tc.want, errs)
+			}
+		})
+	}
+}
+
+func TestValidateStreams(t *testing.T) {
+	stream := func(name string, change func(s *Stream_Service)) Stream_Service {
+		s := Stream_Service{Enable: true, Name: name, Listen: 3307, Upstream: "127.0.0.1:3306"}
+		change(&s)
+		return s
+	}
+	cases := []struct {
+		name    string
+		streams []Stream_Service
+		want    []string
+	}{
+		{"tcp и udp на одном порту", []Stream_Service{
+			stream("db", func(s *Stream_Service) {}),
+			stream("dns", func(s *Stream_Service) { s.Protocol = "udp"; s.Upstream = "10.0.0.1:53" }),
+		}, nil},
+		{"повтор имени и порта", []Stream_Service{
+			stream("db", func(s *Stream_Service) {}),
+			stream("db", func(s *Stream_Service) {}),
+		}, []string{"Stream_Service[1].listen", "Stream_Service[1].name"}},
+		{"выключенный сервис порт не занимает", []Stream_Service{
+			stream("db", func(s *Stream_Service) {}),
+			stream("db2", func(s *Stream_Service) { s.Enable = false }),
+		}, nil},
+		{"некорректный upstream и протокол", []Stream_Service{
+			stream("a", func(s *Stream_Service) { s.Upstream = "3306" }),
+			stream("b", func(s *Stream_Service) { s.Upstream = "db:mysql"; s.Listen = 3308 }),
+			stream("c", func(s *Stream_Service) { s.Protocol = "sctp" }),
+		}, []string{"Stream_Service[0].upstream", "Stream_Service[1].upstream", "Stream_Service[2].protocol"}},
+		{"порты HTTP, MySQL и пула PHP", []Stream_Service{
+			stream("a", func(s *Stream_Service) { s.Listen = 80 }),
+			stream("b", func(s *Stream_Service) { s.Listen = 3306 }),
+			stream("c", func(s *Stream_Service) { s.Listen = 8001 }),
+		}, []string{"Stream_Service[0].listen", "Stream_Service[1].listen", "Stream_Service[2].listen"}},
+		{"udp без tls и sni", []Stream_Service{
+			stream("a", func(s *Stream_Service) { s.Protocol = "udp"; s.Tls = true; s.Sni = []string{"game.local"} }),
+		}, []string{"Stream_Service[0].sni", "Stream_Service[0].tls"}},
+		{"ни listen, ни sni", []Stream_Service{
+			stream("a", func(s *Stream_Service) { s.Listen = 0 }),
+		}, []string{"Stream_Service[0].listen"}},
+		{"sni занимает домены сайтов и прокси", []Stream_Service{
+			stream("a", func(s *Stream_Service) {
+				s.Listen = 0
+				s.Sni = []string{"site.local", "APP.local", "", "*.site.local", "game.local"}
+			}),
+			stream("b", func(s *Stream_Service) { s.Listen = 0; s.Sni = []string{"game.local"} }),
+		}, []string{"Stream_Service[0].sni[0]", "Stream_Service[0].sni[1]", "Stream_Service[0].sni[2]", "Stream_Service[1].sni[0]"}},
+		{"отрицательные лимиты", []Stream_Service{
+			stream("a", func(s *Stream_Service) { s.Max_connections = -1; s.Idle_timeout = -5 }),
+		}, []string{"Stream_Service[0].idle_timeout", "Stream_Service[0].max_connections"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Stream_Service = tc.streams
+			errs := ValidateConfig(cfg)
+			if got := errorPaths(errs); !slices.Equal(got, append([]string{}, tc.want...)) {
+				t.Errorf("получили %v\nожидали  %v\nошибки: %v", got, tc.want, errs)
+			}
+		})
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	base, _ := json.Marshal(validConfig())
+
+	cases := []struct {
+		name string
+		data string
+		path string
+		msg  string
+	}{
+		{"синтаксис", "{\n  \"Site_www\": [\n}", "", "строке 3"},
+		{"не объект", "[]", "", "JSON-объектом"},
+		{"неизвестный ключ", strings.Replace(string(base), `"Site_www"`, `"Sites":[],"Site_www"`, 1), "Sites", "неизвестный ключ"},
+		{"неизвестный ключ маршрута", strings.Replace(string(base), `"LocalPort":"3000"`, `"LocalPort":"3000","routes":[{"path":"/a","site":"site.local","prefix":true}]`, 1), "Proxy_Service[0].routes[0].prefix", "неизвестный ключ"},
+		{"тип значения", strings.Replace(string(base), `"php_port":8000`, `"php_port":"8000"`, 1), "Soft_Settings.php_port", "ожидается int"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := Validate([]byte(tc.data))
+			if len(errs) == 0 {
+				t.Fatal("ожидали ошибку")
+			}
+			if errs[0].Path != tc.path || !strings.Contains(errs[0].Message, tc.msg) {
+				t.Errorf("получили %v, ожидали %s: ...%s...", errs, tc.path, tc.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+	webserver "vServer/Backend/WebServer"
+	"vServer/Backend/WebServer/acme"
+	config "vServer/Backend/config"
+	"vServer/Backend/metrics"
+	tools "vServer/Backend/tools"
+)
+
+var daemonLog = tools.NewLogger("daemon", "logs_config.log")
+
+// Start запускает весь стек vServer: конфиг, handler, сертификаты, ACME, HTTP/HTTPS, PHP и MySQL
+// Если config.json не загрузился, сервисы не запускаются: без конфига им нечего обслуживать
+func Start() error {
+	// Инициализируем время запуска
+	tools.ServerUptime("start")
+
+	// Загружаем конфигурацию
+	if err := config.LoadConfig(); err != nil {
+		return fmt.Errorf("config.json не загружен, сервисы не запущены: %w", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Запускаем handler
+	webserver.StartHandler()
+	time.Sleep(50 * time.Millisecond)
+
+	// Загружаем сертификаты
+	webserver.Cert_start()
+	time.Sleep(50 * time.Millisecond)
+
+	// Инициализируем ACME менеджер (true = production, false = staging)
+	if err := acme.Init(true); err != nil {
+		daemonLog.Console().Error("Ошибка инициализации ACME", "error", err)
+	} else {
+		// Запускаем фоновую проверку сертификатов каждые 24 часа
+		acme.StartBackgroundRenewal(24 * time.Hour)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Запускаем серверы
+	go webserver.StartHTTPS()
+	time.Sleep(50 * time.Millisecond)
+
+	go webserver.StartHTTP()
+	time.Sleep(50 * time.Millisecond)
+
+	// TCP/UDP сервисы (Stream_Service)
+	webserver.StartStreams()
+
+	// Запускаем PHP
+	webserver.PHP_Start()
+	time.Sleep(50 * time.Millisecond)
+
+	// Запускаем MySQL асинхронно
+	go webserver.StartMySQLServer(false)
+
+	// Активные проверки бэкендов прокси (health_check)
+	webserver.StartHealthChecks()
+
+	// Prometheus метрики на отдельном адресе (если задан)
+	metrics.Start(config.Current().Soft_Settings.Metrics_listen)
+
+	// Следим за изменениями config.json на диске
+	WatchConfig(time.Second)
+
+	// Автоматическое получение SSL сертификатов для доменов с AutoCreateSSL=true
+	if config.Current().Soft_Settings.ACME_enabled {
+		go func() {
+			time.Sleep(2 * time.Second) // Ждём пока HTTP сервер полностью запустится
+			results := acme.ObtainAllCertificates()
+			if len(results) > 0 {
+				// Перезагружаем сертификаты после получения
+				webserver.ReloadCertificates()
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Stop останавливает все сервисы
+func Stop() {
+	webserver.StopHTTPServer()
+	webserver.StopHTTPSServer()
+	webserver.StopStreams()
+	webserver.PHP_Stop()
+	webserver.StopMySQLServer()
+	webserver.StopHealthChecks()
+	metrics.Stop()
+}
+
+// Run запускает vServer в headless-режиме и блокируется до получения сигнала остановки
+// SIGHUP перезагружает конфигурацию, SIGTERM/SIGINT корректно останавливают сервисы
+func Run() error {
+	// Решает блокировка: PID из файла мог достаться другому процессу, он нужен только для сообщения
+	if !tools.CheckSingleInstance() {
+		pid, _ := RunningPID()
+		return errAlreadyRunning(pid)
+	}
+	defer tools.ReleaseMutex()
+
+	// Сигналы перехватываются до запуска сервисов: остановка во время Start
+	// дождётся его окончания и корректно погасит уже запущенные PHP и MySQL
+	signals := make(chan os.Signal, 1)
+	notifySignals(signals)
+	defer signal.Stop(signals)
+
+	if err := writePID(); err != nil {
+		return err
+	}
+	defer removePID()
+
+	if err := Start(); err != nil {
+		return err
+	}
+	daemonLog.Console().Info("vServer запущен в headless-режиме", "pid", os.Getpid())
+
+	for sig := range signals {
+		if isReloadSignal(sig) {
+			Reload()
+			continue
+		}
+
+		daemonLog.Console().Info("Получен сигнал остановки, останавливаем сервисы", "signal", sig.String())
+		Stop()
+		break
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PIDPath путь к PID-файлу запущенного headless-сервера
+var PIDPath = "WebServer/tools/vserver.pid"
+
+func errAlreadyRunning(pid int) error {
+	if pid > 0 {
+		return fmt.Errorf("vServer уже запущен (PID %d)", pid)
+	}
+	return fmt.Errorf("vServer уже запущен")
+}
+
+// writePID записывает PID текущего процесса
+func writePID() error {
+	if err := os.MkdirAll(filepath.Dir(PIDPath), 0755); err != nil {
+		return fmt.Errorf("не удалось создать папку для PID-файла: %w", err)
+	}
+	return os.WriteFile(PIDPath, []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// removePID удаляет PID-файл
+func removePID() {
+	os.Remove(PIDPath)
+}
+
+// readPID читает PID из файла, 0 если файла нет или он повреждён
+func readPID() int {
+	data, err := os.ReadFile(PIDPath)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// RunningPID возвращает PID запущенного сервера и признак того, что процесс жив
+func RunningPID() (int, bool) {
+	pid := readPID()
+	if pid <= 0 {
+		return 0, false
+	}
+	return pid, processAlive(pid)
+}
+
+// SignalStop отправляет запущенному серверу сигнал остановки и ждёт завершения
+func SignalStop(timeout time.Duration) error {
+	pid, running := RunningPID()
+	if !running {
+		return fmt.Errorf("vServer не запущен")
+	}
+
+	if err := sendStop(pid); err != nil {
+		return fmt.Errorf("не удалось остановить процесс %d: %w", pid, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			removePID()
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	return fmt.Errorf("процесс %d не завершился за %s", pid, timeout)
+}
+
+// SignalReload отправляет запущенному серверу сигнал перезагрузки конфигурации
+func SignalReload() error {
+	pid, running := RunningPID()
+	if !running {
+		return fmt.Errorf("vServer не запущен")
+	}
+
+	if err := sendReload(pid); err != nil {
+		return fmt.Errorf("не удалось перезагрузить процесс %d: %w", pid, err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	webserver "vServer/Backend/WebServer"
+	"vServer/Backend/WebServer/acme"
+	config "vServer/Backend/config"
+	"vServer/Backend/metrics"
+	tools "vServer/Backend/tools"
+)
+
+var reloadLog = tools.NewLogger("reload", "logs_config.log")
+
+var (
+	reloadMutex   sync.Mutex
+	appliedHash   string // sha256 последнего применённого config.json
+	failedHash    string // sha256 config.json, который не удалось применить (не перечитываем его повторно)
+	watcherActive bool
+)
+
+// Reload перечитывает config.json и применяет только то, что изменилось
+// Если файл не читается или не прошёл проверку, продолжает работать прежняя конфигурация
+func Reload() (config.ConfigDiff, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	hash := configFileHash()
+	oldConfig := config.Snapshot()
+	if err := config.LoadConfig(); err != nil {
+		failedHash = hash
+		reloadLog.Console().Error("config.json не применён, работает прежняя конфигурация", "error", err)
+		return config.ConfigDiff{}, err
+	}
+	appliedHash, failedHash = hash, ""
+
+	diff := config.Diff(oldConfig, *config.Current())
+	applyDiff(diff)
+	return diff, nil
+}
+
+// applyDiff применяет изменения конфигурации к работающим сервисам
+func applyDiff(diff config.ConfigDiff) {
+	if diff.Empty() {
+		reloadLog.Console().Info("Конфигурация не изменилась")
+		return
+	}
+
+	// Сайты: кэш статусов и сертификаты для новых доменов
+	if diff.SitesTouched() {
+		webserver.UpdateSiteStatusCache()
+		logChanges("Сайты", diff.SitesAdded, diff.SitesRemoved, diff.SitesChanged)
+	}
+
+	// Прокси читают конфиг на каждый запрос - сбрасываем пулы соединений к старым бэкендам
+	if diff.ProxiesTouched() {
+		webserver.ResetProxyTransports()
+		logChanges("Прокси", diff.ProxiesAdded, diff.ProxiesRemoved, diff.ProxiesChanged)
+		if diff.ProxyToggled {
+			reloadLog.Console().Info("Прокси переключены", "proxy_enabled", config.Current().Soft_Settings.Proxy_enabled)
+		}
+	}
+
+	if diff.StreamsTouched() {
+		logChanges("Stream-сервисы", diff.StreamsAdded, diff.StreamsRemoved, diff.StreamsChanged)
+	}
+
+	if len(diff.SitesAdded) > 0 || len(diff.ProxiesAdded) > 0 {
+		webserver.ReloadCertificates()
+	}
+
+	// Адреса и порты - передаём сокеты или переоткрываем их
+	// Сервер, который не смог открыть прежние порты, запускается на новых
+	if diff.ListenChanged {
+		if webserver.HTTPSWanted() {
+			webserver.RestartHTTPSServer()
+		}
+		if webserver.HTTPWanted() {
+			webserver.RestartHTTPServer()
+		}
+	}
+
+	// Stream_Service: перезапускаются только изменённые (в том числе при смене listen_address)
+	if diff.StreamsTouched() || diff.ListenChanged {
+		webserver.ApplyStreams()
+	}
+
+	// Пул PHP перезапускаем только при изменении его настроек
+	if diff.PHPChanged && webserver.GetPHPStatus() {
+		reloadLog.Console().Info("Настройки PHP изменились, перезапускаем пул")
+		webserver.PHP_Stop()
+		time.Sleep(500 * time.Millisecond)
+		webserver.PHP_Start()
+	}
+
+	// MySQL не трогаем, если его настройки не менялись
+	if diff.MySQLChanged && webserver.GetMySQLStatus() {
+		reloadLog.Console().Info("Настройки MySQL изменились, перезапускаем")
+		webserver.StopMySQLServer()
+		time.Sleep(500 * time.Millisecond)
+		go webserver.StartMySQLServer(false)
+	}
+
+	// Слушатель метрик переносим на новый адрес или выключаем
+	if diff.MetricsChanged {
+		metrics.Start(config.Current().Soft_Settings.Metrics_listen)
+	}
+
+	// Новые домены с AutoCreateSSL - получаем сертификаты в фоне
+	if (diff.ACMEEnabled || diff.SSLRequested) && config.Current().Soft_Settings.ACME_enabled {
+		go func() {
+			results := acme.ObtainAllCertificates()
+			if len(results) > 0 {
+				webserver.ReloadCertificates()
+			}
+		}()
+	}
+
+	reloadLog.Console().Info("Изменения конфигурации применены")
+}
+
+func logChanges(section string, added, removed, changed []string) {
+	if len(added) > 0 {
+		reloadLog.Console().Info(section+" добавлены", "names", strings.Join(added, ","))
+	}
+	if len(removed) > 0 {
+		reloadLog.Console().Info(section+" удалены", "names", strings.Join(removed, ","))
+	}
+	if len(changed) > 0 {
+		reloadLog.Console().Info(section+" изменены", "names", strings.Join(changed, ","))
+	}
+}
+
+// configFileHash возвращает sha256 содержимого config.json, "" если файл не читается
+func configFileHash() string {
+	data, err := os.ReadFile(config.ConfigPath)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WatchConfig следит за config.json и применяет изменения с диска без участия GUI
+func WatchConfig(interval time.Duration) {
+	reloadMutex.Lock()
+	if watcherActive {
+		reloadMutex.Unlock()
+		return
+	}
+	watcherActive = true
+	appliedHash = configFileHash()
+	reloadMutex.Unlock()
+
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			hash := configFileHash()
+			reloadMutex.Lock()
+			changed := hash != "" && hash != appliedHash && hash != failedHash
+			reloadMutex.Unlock()
+			if !changed {
+				continue
+			}
+
+			// Ждём, пока редактор допишет файл
+			time.Sleep(300 * time.Millisecond)
+			if configFileHash() != hash {
+				continue
+			}
+
+			reloadLog.Console().Info("config.json изменён на диске, применяем")
+			Reload()
+		}
+	}()
+}
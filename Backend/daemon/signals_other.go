@@ -0,0 +1,35 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySignals подписывает канал на сигналы остановки и перезагрузки
+func notifySignals(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+}
+
+func isReloadSignal(sig os.Signal) bool {
+	return sig == syscall.SIGHUP
+}
+
+// processAlive проверяет существование процесса нулевым сигналом
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+func sendStop(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+func sendReload(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
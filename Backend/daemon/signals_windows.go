@@ -0,0 +1,93 @@
+//go:build windows
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32        = syscall.NewLazyDLL("kernel32.dll")
+	procCreateEvent = kernel32.NewProc("CreateEventW")
+	procOpenEvent   = kernel32.NewProc("OpenEventW")
+	procSetEvent    = kernel32.NewProc("SetEvent")
+)
+
+const eventModifyState = 0x0002
+
+// stopEventName - именованное событие, через которое vserver-cli stop просит процесс завершиться
+// (послать SIGTERM другому процессу на Windows нельзя)
+func stopEventName(pid int) string {
+	return `Local\vServer_Stop_` + strconv.Itoa(pid)
+}
+
+// notifySignals подписывает канал на сигналы остановки (Ctrl+C, закрытие консоли)
+// и на событие остановки от vserver-cli stop
+func notifySignals(c chan os.Signal) {
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	name, err := syscall.UTF16PtrFromString(stopEventName(os.Getpid()))
+	if err != nil {
+		return
+	}
+	handle, _, callErr := procCreateEvent.Call(0, 0, 0, uintptr(unsafe.Pointer(name)))
+	if handle == 0 {
+		daemonLog.Warn("Не удалось создать событие остановки, vserver-cli stop не сможет остановить процесс", "error", callErr)
+		return
+	}
+
+	go func() {
+		syscall.WaitForSingleObject(syscall.Handle(handle), syscall.INFINITE)
+		c <- syscall.SIGTERM
+	}()
+}
+
+// На Windows нет SIGHUP - перезагрузка доступна через админку
+func isReloadSignal(sig os.Signal) bool {
+	return false
+}
+
+// processAlive проверяет существование процесса через открытие его дескриптора
+func processAlive(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	const stillActive = 259
+
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == stillActive
+}
+
+// sendStop взводит событие остановки: процесс сам останавливает сервисы, PHP и MySQL
+func sendStop(pid int) error {
+	name, err := syscall.UTF16PtrFromString(stopEventName(pid))
+	if err != nil {
+		return err
+	}
+	handle, _, callErr := procOpenEvent.Call(eventModifyState, 0, uintptr(unsafe.Pointer(name)))
+	if handle == 0 {
+		return errors.New("процесс не принимает команду остановки: " + callErr.Error())
+	}
+	defer syscall.CloseHandle(syscall.Handle(handle))
+
+	if ok, _, callErr := procSetEvent.Call(handle); ok == 0 {
+		return callErr
+	}
+	return nil
+}
+
+func sendReload(pid int) error {
+	return errors.New("reload через сигнал не поддерживается на Windows, используйте админку")
+}
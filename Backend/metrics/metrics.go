@@ -0,0 +1,313 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Метрики в текстовом формате Prometheus 0.0.4 (без внешних зависимостей)
+
+// Семейство метрик с одним именем
+type family interface {
+	write(out *strings.Builder)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]family)
+)
+
+func register(name string, metric family) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("metrics: метрика " + name + " уже зарегистрирована")
+	}
+	registry[name] = metric
+}
+
+// WriteTo выводит все метрики, отсортированные по имени
+func WriteTo(w io.Writer) error {
+	registryMu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	families := make([]family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, registry[name])
+	}
+	registryMu.Unlock()
+
+	var out strings.Builder
+	for _, metric := range families {
+		metric.write(&out)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Значения меток хранятся под ключом, склеенным через \xff
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkLabels проверяет, что передано столько значений, сколько объявлено меток
+func checkLabels(name string, labels []string, values []string) {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("metrics: %s ожидает %d меток, передано %d", name, len(labels), len(values)))
+	}
+}
+
+func writeHeader(out *strings.Builder, name string, help string, kind string) {
+	out.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	out.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// writeSample: name{label="value",...} 1.5
+func writeSample(out *strings.Builder, name string, labels []string, values []string, extra string, value float64) {
+	out.WriteString(name)
+	if len(labels) > 0 || extra != "" {
+		out.WriteString("{")
+		for i, label := range labels {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+		}
+		if extra != "" {
+			if len(labels) > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString(extra)
+		}
+		out.WriteString("}")
+	}
+	out.WriteString(" " + formatValue(value) + "\n")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return helpReplacer.Replace(value)
+}
+
+// sortedKeys возвращает ключи меток в стабильном порядке
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func splitKey(key string, count int) []string {
+	if count == 0 {
+		return nil
+	}
+	return strings.SplitN(key, "\xff", count)
+}
+
+// ========================================
+// COUNTER / GAUGE
+// ========================================
+
+// Vec - счётчик или gauge с набором меток
+type Vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(kind string, name string, help string, labels []string) *Vec {
+	vec := &Vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	register(name, vec)
+	return vec
+}
+
+// NewCounter создаёт счётчик, который только растёт
+func NewCounter(name string, help string, labels ...string) *Vec {
+	return newVec("counter", name, help, labels)
+}
+
+// NewGauge создаёт значение, которое может расти и уменьшаться
+func NewGauge(name string, help string, labels ...string) *Vec {
+	return newVec("gauge", name, help, labels)
+}
+
+// Inc увеличивает значение на 1
+func (v *Vec) Inc(values ...string) {
+	v.Add(1, values...)
+}
+
+// Add прибавляет delta (для счётчика - только положительные значения)
+func (v *Vec) Add(delta float64, values ...string) {
+	checkLabels(v.name, v.labels, values)
+	if v.kind == "counter" && delta < 0 {
+		return
+	}
+	v.mu.Lock()
+	v.values[labelKey(values)] += delta
+	v.mu.Unlock()
+}
+
+// Set задаёт значение gauge
+func (v *Vec) Set(value float64, values ...string) {
+	checkLabels(v.name, v.labels, values)
+	v.mu.Lock()
+	v.values[labelKey(values)] = value
+	v.mu.Unlock()
+}
+
+// Delete удаляет значение с указанными метками (удалённый сайт, домен)
+func (v *Vec) Delete(values ...string) {
+	v.mu.Lock()
+	delete(v.values, labelKey(values))
+	v.mu.Unlock()
+}
+
+func (v *Vec) write(out *strings.Builder) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	writeHeader(out, v.name, v.help, v.kind)
+	if len(v.labels) == 0 && len(v.values) == 0 {
+		writeSample(out, v.name, nil, nil, "", 0)
+		return
+	}
+	for _, key := range sortedKeys(v.values) {
+		writeSample(out, v.name, v.labels, splitKey(key, len(v.labels)), "", v.values[key])
+	}
+}
+
+// ========================================
+// HISTOGRAM
+// ========================================
+
+// Границы по умолчанию для времени обработки запросов, сек
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogramValue struct {
+	counts []uint64 // По корзинам, без +Inf
+	count  uint64
+	sum    float64
+}
+
+// Histogram - распределение значений по корзинам с набором меток
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// NewHistogram создаёт гистограмму (buckets = nil - DefaultBuckets)
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	histogram := &Histogram{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+	register(name, histogram)
+	return histogram
+}
+
+// Observe добавляет значение
+func (h *Histogram) Observe(value float64, values ...string) {
+	checkLabels(h.name, h.labels, values)
+	key := labelKey(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	item := h.values[key]
+	if item == nil {
+		item = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = item
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			item.counts[i]++
+		}
+	}
+	item.count++
+	item.sum += value
+}
+
+func (h *Histogram) write(out *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(out, h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		item := h.values[key]
+		values := splitKey(key, len(h.labels))
+		for i, bound := range h.buckets {
+			writeSample(out, h.name+"_bucket", h.labels, values, `le="`+formatValue(bound)+`"`, float64(item.counts[i]))
+		}
+		writeSample(out, h.name+"_bucket", h.labels, values, `le="+Inf"`, float64(item.count))
+		writeSample(out, h.name+"_sum", h.labels, values, "", item.sum)
+		writeSample(out, h.name+"_count", h.labels, values, "", float64(item.count))
+	}
+}
+
+// ========================================
+// GAUGE FUNC
+// ========================================
+
+// Sample - одно значение, собранное в момент запроса /metrics
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type gaugeFunc struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() []Sample
+}
+
+// NewGaugeFunc регистрирует gauge, значения которого вычисляются при каждом запросе /metrics
+// (состояние пула PHP, сроки сертификатов)
+func NewGaugeFunc(name string, help string, labels []string, collect func() []Sample) {
+	register(name, &gaugeFunc{name: name, help: help, labels: labels, collect: collect})
+}
+
+func (g *gaugeFunc) write(out *strings.Builder) {
+	writeHeader(out, g.name, g.help, "gauge")
+	for _, sample := range g.collect() {
+		if len(sample.Labels) != len(g.labels) {
+			continue
+		}
+		writeSample(out, g.name, g.labels, sample.Labels, "", sample.Value)
+	}
+}
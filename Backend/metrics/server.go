@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+	tools "vServer/Backend/tools"
+)
+
+var metricsLog = tools.NewLogger("metrics", "logs_config.log")
+
+var (
+	serverMu      sync.Mutex
+	metricsServer *http.Server
+	serverAddress string
+)
+
+// Метрики процесса
+func init() {
+	NewGaugeFunc("vserver_uptime_seconds", "Время работы vServer, сек", nil, func() []Sample {
+		return []Sample{{Value: float64(tools.ServerUptime("get", true).(int64))}}
+	})
+	NewGaugeFunc("vserver_goroutines", "Количество горутин", nil, func() []Sample {
+		return []Sample{{Value: float64(runtime.NumGoroutine())}}
+	})
+	NewGaugeFunc("vserver_memory_bytes", "Память, полученная процессом от ОС", nil, func() []Sample {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return []Sample{{Value: float64(stats.Sys)}}
+	})
+}
+
+// Handler отдаёт метрики в формате Prometheus
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteTo(w)
+}
+
+// Start запускает отдельный HTTP-слушатель /metrics (address = "" - выключен)
+// Повторный вызов с другим адресом переносит слушатель
+func Start(address string) error {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+
+	if metricsServer != nil && address == serverAddress {
+		return nil
+	}
+	stopLocked()
+	if address == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		metricsLog.Console().Error("Не удалось открыть порт метрик", "listen", address, "error", err)
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", Handler)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	metricsServer = server
+	serverAddress = address
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			metricsLog.Console().Error("Ошибка сервера метрик", "listen", address, "error", err)
+		}
+	}()
+
+	metricsLog.Console().Info("Сервер метрик запущен", "listen", "http://"+address+"/metrics")
+	return nil
+}
+
+// Stop закрывает слушатель метрик
+func Stop() {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	stopLocked()
+}
+
+func stopLocked() {
+	if metricsServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	metricsServer.Shutdown(ctx)
+
+	metricsLog.Console().Info("Сервер метрик остановлен", "listen", serverAddress)
+	metricsServer = nil
+	serverAddress = ""
+}
+
+// Address возвращает адрес работающего слушателя ("" - выключен)
+func Address() string {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	return serverAddress
+}
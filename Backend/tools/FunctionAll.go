@@ -10,6 +10,8 @@ import (
 // Время запуска сервера
 var ServerStartTime time.Time
 
+var serverLog = NewLogger("server", "logs_error.log")
+
 // isPortInUse проверяет, занят ли указанный порт
 func Port_check(service string, host string, port string) bool {
 	conn, err := net.DialTimeout("tcp", host+":"+port, time.Millisecond*300)
@@ -17,7 +19,7 @@ func Port_check(service string, host string, port string) bool {
 		return false // порт свободен
 	}
 	conn.Close()
-	Logs_file(1, service, "⚠️ Порт "+port+" уже занят, сервис не запущен", "logs_error.log", true)
+	serverLog.Console().Error("Порт уже занят, сервис не запущен", "service", service, "port", port)
 	return true // порт занят
 }
 
@@ -111,14 +111,30 @@ func RunBatScript(script string) (string, error) {
 	return string(output), err
 }
 
-// Функция для логирования вывода процесса в консоль
-func Logs_console(process *exec.Cmd, check bool) error {
+// ExeName добавляет расширение исполняемого файла для текущей ОС
+func ExeName(name string) string {
+	return name + ".exe"
+}
 
-	// Скрываем окно процесса для GUI приложений
+// HideProcessWindow скрывает консольное окно дочернего процесса
+func HideProcessWindow(process *exec.Cmd) {
 	process.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow:    true,
 		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
 	}
+}
+
+// terminateProcess завершает процесс. Дочерние процессы запускаются без консоли (CREATE_NO_WINDOW),
+// мягко попросить их завершиться нечем - процесс убивается сразу
+func terminateProcess(process *os.Process) {
+	process.Kill()
+}
+
+// Функция для логирования вывода процесса в консоль
+func Logs_console(process *exec.Cmd, check bool) error {
+
+	// Скрываем окно процесса для GUI приложений
+	HideProcessWindow(process)
 
 	if check {
 		// Настраиваем pipes для захвата вывода
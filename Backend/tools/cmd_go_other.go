@@ -0,0 +1,109 @@
+//go:build !windows
+
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var lockFile *os.File
+
+// ExeName добавляет расширение исполняемого файла для текущей ОС
+func ExeName(name string) string {
+	return name
+}
+
+// HideProcessWindow на не-Windows системах ничего не делает - окон у процессов нет
+func HideProcessWindow(process *exec.Cmd) {
+}
+
+// terminateProcess просит процесс завершиться (SIGTERM)
+func terminateProcess(process *os.Process) {
+	process.Signal(syscall.SIGTERM)
+}
+
+func RunBatScript(script string) (string, error) {
+	// Создание временного файла
+	tmpFile, err := os.CreateTemp("", "script-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания temp-файла: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	// Запись скрипта в файл
+	if _, err := tmpFile.WriteString(script); err != nil {
+		return "", fmt.Errorf("ошибка записи в temp-файл: %w", err)
+	}
+	tmpFile.Close()
+
+	// Выполняем файл через sh
+	cmd := exec.Command("sh", tmpFile.Name())
+	output, err := cmd.CombinedOutput()
+
+	return string(output), err
+}
+
+// Функция для логирования вывода процесса в консоль
+func Logs_console(process *exec.Cmd, check bool) error {
+
+	if check {
+		// Настраиваем pipes для захвата вывода
+		stdout, err := process.StdoutPipe()
+		CheckError(err)
+		stderr, err := process.StderrPipe()
+		CheckError(err)
+
+		// Запускаем процесс
+		process.Start()
+
+		// Захватываем stdout и stderr для вывода логов
+		go func() {
+			scanner := bufio.NewScanner(stdout)
+			for scanner.Scan() {
+				fmt.Println(scanner.Text())
+			}
+		}()
+
+		go func() {
+			scanner := bufio.NewScanner(stderr)
+			for scanner.Scan() {
+				fmt.Println(scanner.Text())
+			}
+		}()
+	} else {
+		// Просто запускаем процесс без логирования
+		return process.Start()
+	}
+
+	return nil
+}
+
+// CheckSingleInstance проверяет, не запущена ли программа уже через блокировку lock-файла
+func CheckSingleInstance() bool {
+	file, err := os.OpenFile(os.TempDir()+"/vServer_SingleInstance.lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false
+	}
+
+	// Неблокирующая эксклюзивная блокировка - если занята, значит vServer уже запущен
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		return false
+	}
+
+	lockFile = file
+	return true
+}
+
+// ReleaseMutex освобождает блокировку при завершении программы
+func ReleaseMutex() {
+	if lockFile != nil {
+		syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		lockFile.Close()
+		lockFile = nil
+	}
+}
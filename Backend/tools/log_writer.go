@@ -0,0 +1,413 @@
+package tools
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Папка логов
+const LogsDir = "WebServer/tools/logs"
+
+// LogRotation - настройки ротации и хранения логов
+type LogRotation struct {
+	MaxSizeMB  int    // Размер сегмента, после которого файл ротируется (0 = без ограничения)
+	Interval   string // Ротация по времени: "daily", "hourly" или "" (выключена)
+	MaxFiles   int    // Сколько старых сегментов хранить на лог (0 = без ограничения)
+	MaxAgeDays int    // Сколько дней хранить старые сегменты (0 = без ограничения)
+	Compress   bool   // Сжимать старые сегменты в .gz
+}
+
+// Настройки по умолчанию (до загрузки конфигурации)
+var DefaultLogRotation = LogRotation{
+	MaxSizeMB:  10,
+	Interval:   "daily",
+	MaxFiles:   10,
+	MaxAgeDays: 30,
+	Compress:   true,
+}
+
+const (
+	logQueueSize     = 4096
+	logFlushInterval = time.Second
+	logRetryInterval = 10 * time.Second // Пауза перед повторным открытием файла после ошибки
+	rotateRetryDelay = time.Minute      // Пауза после неудачной ротации без ротации по времени
+)
+
+// Запись в очереди: строка для файла или запрос на сброс буферов
+type logRecord struct {
+	file string
+	text string
+	done chan struct{}
+}
+
+// Открытый файл лога
+type logFile struct {
+	name    string
+	file    *os.File
+	buf     *bufio.Writer
+	size    int64
+	opened  time.Time // Начало текущего сегмента (для ротации по времени)
+	retryAt time.Time // До этого момента пишем в stderr
+
+	rotateRetryAt time.Time // После неудачной ротации (файл занят другим процессом) не пробуем до этого момента
+}
+
+var (
+	logQueue     chan logRecord
+	logStartOnce sync.Once
+	logFiles     = make(map[string]*logFile) // Только из горутины logLoop
+	logSettings  = DefaultLogRotation
+	logSettingMu sync.RWMutex
+	logCleanupMu sync.Mutex   // Сжатие и очистка сегментов идут по одному
+	logDropped   atomic.Int64 // Строки, не попавшие в переполненную очередь
+)
+
+// SetLogRotation применяет настройки ротации (вызывается при загрузке конфигурации)
+func SetLogRotation(settings LogRotation) {
+	logSettingMu.Lock()
+	logSettings = settings
+	logSettingMu.Unlock()
+}
+
+func currentLogRotation() LogRotation {
+	logSettingMu.RLock()
+	defer logSettingMu.RUnlock()
+	return logSettings
+}
+
+// appendLogFile ставит текст в очередь на запись в WebServer/tools/logs/<log_file>
+// Если диск не успевает и очередь заполнена, строка отбрасывается: запросы не должны ждать логов
+func appendLogFile(log_file string, text string) {
+	logStartOnce.Do(startLogWriter)
+	select {
+	case logQueue <- logRecord{file: log_file, text: text}:
+	default:
+		logDropped.Add(1)
+	}
+}
+
+// FlushLogs дожидается записи всех строк из очереди на диск (перед выходом из программы)
+func FlushLogs() {
+	logStartOnce.Do(startLogWriter)
+	done := make(chan struct{})
+	logQueue <- logRecord{done: done}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		fmt.Fprintln(os.Stderr, "[LOGS] Не удалось дождаться записи логов")
+	}
+}
+
+func startLogWriter() {
+	logQueue = make(chan logRecord, logQueueSize)
+	go logLoop()
+}
+
+// logLoop - единственный писатель файлов логов: буферизует строки и сбрасывает их раз в секунду
+func logLoop() {
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record := <-logQueue:
+			if record.done != nil {
+				flushLogFiles()
+				close(record.done)
+				continue
+			}
+			writeLogRecord(record)
+
+		case <-ticker.C:
+			flushLogFiles()
+			rotateExpiredLogs()
+			if dropped := logDropped.Swap(0); dropped > 0 {
+				fmt.Fprintf(os.Stderr, "[LOGS] Очередь логов переполнена, пропущено строк: %d\n", dropped)
+			}
+		}
+	}
+}
+
+func writeLogRecord(record logRecord) {
+	lf := logFiles[record.file]
+	if lf == nil {
+		lf = &logFile{name: record.file}
+		logFiles[record.file] = lf
+	}
+
+	if lf.file == nil && !lf.open() {
+		fallbackToStderr(record.file, record.text)
+		return
+	}
+
+	settings := currentLogRotation()
+	if lf.needsRotation(settings, int64(len(record.text))) {
+		lf.rotate(settings)
+		if !lf.open() {
+			fallbackToStderr(record.file, record.text)
+			return
+		}
+	}
+
+	n, err := lf.buf.WriteString(record.text)
+	lf.size += int64(n)
+	if err != nil {
+		lf.fail(err)
+		fallbackToStderr(record.file, record.text)
+	}
+}
+
+// open открывает файл на дозапись. После ошибки повторная попытка - не раньше logRetryInterval
+func (lf *logFile) open() bool {
+	if time.Now().Before(lf.retryAt) {
+		return false
+	}
+
+	if err := os.MkdirAll(LogsDir, 0755); err != nil {
+		lf.fail(err)
+		return false
+	}
+
+	path := filepath.Join(LogsDir, lf.name)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		lf.fail(err)
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		lf.fail(err)
+		return false
+	}
+
+	lf.file = file
+	lf.buf = bufio.NewWriterSize(file, 64*1024)
+	lf.size = info.Size()
+	lf.opened = time.Now()
+	if info.Size() > 0 {
+		// Существующий файл относится к периоду последней записи в него
+		lf.opened = info.ModTime()
+	}
+	lf.retryAt = time.Time{}
+	return true
+}
+
+// fail закрывает файл и переводит лог на stderr до следующей попытки
+func (lf *logFile) fail(err error) {
+	if lf.file != nil {
+		lf.file.Close()
+	}
+	lf.file = nil
+	lf.buf = nil
+	lf.retryAt = time.Now().Add(logRetryInterval)
+	fmt.Fprintf(os.Stderr, "[LOGS] Ошибка записи %s: %v, вывод временно перенаправлен в stderr\n", lf.name, err)
+}
+
+func (lf *logFile) flush() {
+	if lf.buf == nil {
+		return
+	}
+	if err := lf.buf.Flush(); err != nil {
+		lf.fail(err)
+	}
+}
+
+func (lf *logFile) needsRotation(settings LogRotation, next int64) bool {
+	if time.Now().Before(lf.rotateRetryAt) {
+		return false
+	}
+	if settings.MaxSizeMB > 0 && lf.size > 0 && lf.size+next > int64(settings.MaxSizeMB)*1024*1024 {
+		return true
+	}
+	return lf.size > 0 && periodStart(settings.Interval, lf.opened) != periodStart(settings.Interval, time.Now())
+}
+
+// rotate переименовывает текущий файл в сегмент с отметкой времени и начинает новый
+func (lf *logFile) rotate(settings LogRotation) {
+	lf.flush()
+	if lf.file == nil {
+		return
+	}
+	lf.file.Close()
+	lf.file = nil
+	lf.buf = nil
+
+	path := filepath.Join(LogsDir, lf.name)
+	segment := segmentPath(lf.name, time.Now())
+	if err := os.Rename(path, segment); err != nil {
+		// Продолжаем писать в тот же файл, следующая попытка - в следующем периоде
+		lf.rotateRetryAt = nextPeriodStart(settings.Interval, time.Now())
+		fmt.Fprintf(os.Stderr, "[LOGS] Ошибка ротации %s: %v, следующая попытка после %s\n", lf.name, err, lf.rotateRetryAt.Format("15:04:05"))
+		return
+	}
+	lf.rotateRetryAt = time.Time{}
+
+	go compressAndCleanup(lf.name, segment, settings)
+}
+
+// periodStart возвращает начало периода ротации, в который попадает момент t
+func periodStart(interval string, t time.Time) time.Time {
+	switch interval {
+	case "hourly":
+		return t.Truncate(time.Hour)
+	case "daily":
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// nextPeriodStart возвращает начало следующего периода ротации (без ротации по времени - через rotateRetryDelay)
+func nextPeriodStart(interval string, t time.Time) time.Time {
+	switch interval {
+	case "hourly":
+		return periodStart(interval, t).Add(time.Hour)
+	case "daily":
+		return periodStart(interval, t).AddDate(0, 0, 1)
+	}
+	return t.Add(rotateRetryDelay)
+}
+
+// segmentPath: logs_http.log → logs_http-20261018-150405.log
+func segmentPath(name string, t time.Time) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	path := filepath.Join(LogsDir, base+"-"+t.Format("20060102-150405")+ext)
+
+	// Несколько ротаций за секунду (маленький max_size)
+	for i := 1; fileExists(path) || fileExists(path+".gz"); i++ {
+		path = filepath.Join(LogsDir, fmt.Sprintf("%s-%s.%d%s", base, t.Format("20060102-150405"), i, ext))
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func flushLogFiles() {
+	for _, lf := range logFiles {
+		lf.flush()
+	}
+}
+
+// rotateExpiredLogs ротирует файлы, в которые давно не писали, когда наступил новый период
+func rotateExpiredLogs() {
+	settings := currentLogRotation()
+	for _, lf := range logFiles {
+		if lf.file != nil && lf.needsRotation(settings, 0) {
+			lf.rotate(settings)
+		}
+	}
+}
+
+// compressAndCleanup сжимает сегмент и удаляет старые сегменты лога
+func compressAndCleanup(name string, segment string, settings LogRotation) {
+	logCleanupMu.Lock()
+	defer logCleanupMu.Unlock()
+
+	if settings.Compress {
+		if err := gzipFile(segment); err != nil {
+			fmt.Fprintf(os.Stderr, "[LOGS] Ошибка сжатия %s: %v\n", segment, err)
+		}
+	}
+	cleanupSegments(name, settings)
+}
+
+func gzipFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		writer.Close()
+		target.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	source.Close()
+	return os.Remove(path)
+}
+
+// cleanupSegments удаляет сегменты сверх max_files и старше max_age_days
+func cleanupSegments(name string, settings LogRotation) {
+	segments := LogSegments(name)
+	// Новые сегменты первыми
+	sort.Slice(segments, func(i, j int) bool { return segments[i].ModTime().After(segments[j].ModTime()) })
+
+	maxAge := time.Duration(settings.MaxAgeDays) * 24 * time.Hour
+	for i, segment := range segments {
+		tooMany := settings.MaxFiles > 0 && i >= settings.MaxFiles
+		tooOld := settings.MaxAgeDays > 0 && time.Since(segment.ModTime()) > maxAge
+		if tooMany || tooOld {
+			os.Remove(filepath.Join(LogsDir, segment.Name()))
+		}
+	}
+}
+
+// LogSegments возвращает старые сегменты лога (logs_http-*.log и logs_http-*.log.gz)
+func LogSegments(name string) []os.FileInfo {
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	entries, err := os.ReadDir(LogsDir)
+	if err != nil {
+		return nil
+	}
+
+	var segments []os.FileInfo
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(fileName, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(fileName, ext) && !strings.HasSuffix(fileName, ext+".gz") {
+			continue
+		}
+		// Только файлы с отметкой времени ротации
+		stamp := strings.TrimSuffix(strings.TrimSuffix(fileName[len(prefix):], ".gz"), ext)
+		if len(stamp) < len("20060102-150405") || stamp[8] != '-' {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			segments = append(segments, info)
+		}
+	}
+	return segments
+}
+
+// fallbackToStderr выводит строку в stderr, если файл лога недоступен
+func fallbackToStderr(log_file string, text string) {
+	fmt.Fprint(os.Stderr, "["+log_file+"] "+text)
+}
@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempLogs переносит LogsDir во временную папку и сбрасывает состояние писателя логов.
+// Тесты вызывают writeLogRecord напрямую, без горутины logLoop
+func useTempLogs(t *testing.T, settings LogRotation) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	savedSettings := currentLogRotation()
+	savedFiles := logFiles
+	SetLogRotation(settings)
+	logFiles = make(map[string]*logFile)
+
+	t.Cleanup(func() {
+		for _, lf := range logFiles {
+			lf.flush()
+			if lf.file != nil {
+				lf.file.Close()
+			}
+		}
+		// Ждём фоновое сжатие, пока временная папка не удалена
+		logCleanupMu.Lock()
+		logCleanupMu.Unlock()
+		logFiles = savedFiles
+		SetLogRotation(savedSettings)
+	})
+}
+
+func readLog(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(LogsDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestAppendLogFileDropsOnFullQueue(t *testing.T) {
+	// Очередь без писателя: logLoop не запускается
+	logStartOnce.Do(func() {})
+	savedQueue := logQueue
+	logQueue = make(chan logRecord, 2)
+	logDropped.Store(0)
+	defer func() {
+		logQueue = savedQueue
+		logDropped.Store(0)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			appendLogFile("logs_test.log", "строка\n")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("appendLogFile заблокировался на заполненной очереди")
+	}
+	if len(logQueue) != 2 || logDropped.Load() != 3 {
+		t.Errorf("в очереди %d, отброшено %d, ожидали 2 и 3", len(logQueue), logDropped.Load())
+	}
+}
+
+func TestWriteLogRecordRotatesBySize(t *testing.T) {
+	useTempLogs(t, LogRotation{MaxSizeMB: 1})
+
+	line := strings.Repeat("x", 300*1024) + "\n"
+	for i := 0; i < 4; i++ {
+		writeLogRecord(logRecord{file: "logs_test.log", text: line})
+	}
+	flushLogFiles()
+
+	// Три строки помещаются в 1 МБ, четвёртая начинает новый файл
+	segments := LogSegments("logs_test.log")
+	if len(segments) != 1 {
+		t.Fatalf("сегментов %d, ожидали 1", len(segments))
+	}
+	if size := segments[0].Size(); size != int64(3*len(line)) {
+		t.Errorf("размер сегмента %d, ожидали %d", size, 3*len(line))
+	}
+	if current := readLog(t, "logs_test.log"); current != line {
+		t.Errorf("в текущем файле %d байт, ожидали %d", len(current), len(line))
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+
+	cases := []struct {
+		name     string
+		settings LogRotation
+		lf       logFile
+		next     int64
+		want     bool
+	}{
+		{"пустой файл", LogRotation{MaxSizeMB: 1}, logFile{opened: now}, 2 << 20, false},
+		{"превышен размер", LogRotation{MaxSizeMB: 1}, logFile{size: 1 << 20, opened: now}, 1, true},
+		{"размер не ограничен", LogRotation{}, logFile{size: 1 << 30, opened: now}, 1, false},
+		{"новые сутки", LogRotation{Interval: "daily"}, logFile{size: 1, opened: yesterday}, 0, true},
+		{"те же сутки", LogRotation{Interval: "daily"}, logFile{size: 1, opened: now}, 0, false},
+		{"пауза после ошибки", LogRotation{MaxSizeMB: 1, Interval: "daily"}, logFile{size: 1 << 20, opened: yesterday, rotateRetryAt: now.Add(time.Minute)}, 1, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.lf.needsRotation(tc.settings, tc.next); got != tc.want {
+				t.Errorf("получили %v, ожидали %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRotateBackoffAfterFailure(t *testing.T) {
+	for _, interval := range []string{"", "hourly", "daily"} {
+		t.Run("interval="+interval, func(t *testing.T) {
+			settings := LogRotation{MaxSizeMB: 1, Interval: interval}
+			useTempLogs(t, settings)
+
+			line := strings.Repeat("x", 600*1024) + "\n"
+			writeLogRecord(logRecord{file: "logs_test.log", text: line})
+			flushLogFiles()
+
+			// Файл удалён из-под писателя: переименование при ротации не удастся
+			os.Remove(filepath.Join(LogsDir, "logs_test.log"))
+			before := time.Now()
+			writeLogRecord(logRecord{file: "logs_test.log", text: line})
+			flushLogFiles()
+
+			lf := logFiles["logs_test.log"]
+			if want := nextPeriodStart(interval, before); lf.rotateRetryAt.Before(want) || lf.rotateRetryAt.After(nextPeriodStart(interval, time.Now())) {
+				t.Errorf("следующая попытка %v, ожидали %v", lf.rotateRetryAt, want)
+			}
+			if lf.needsRotation(settings, 1<<30) {
+				t.Error("ротация повторяется до окончания паузы")
+			}
+
+			// Строка не потеряна, сегментов нет
+			if current := readLog(t, "logs_test.log"); current != line {
+				t.Errorf("в файле %d байт, ожидали %d", len(current), len(line))
+			}
+			if segments := LogSegments("logs_test.log"); len(segments) != 0 {
+				t.Errorf("сегментов %d, ожидали 0", len(segments))
+			}
+		})
+	}
+}
+
+func TestCompressAndCleanup(t *testing.T) {
+	useTempLogs(t, DefaultLogRotation)
+	os.MkdirAll(LogsDir, 0755)
+
+	// Три старых сегмента (один уже сжат) и только что ротированный
+	now := time.Now()
+	old := []string{"logs_test-20261015-120000.log", "logs_test-20261016-120000.log.gz", "logs_test-20261017-120000.log"}
+	for i, name := range old {
+		path := filepath.Join(LogsDir, name)
+		os.WriteFile(path, []byte("старый\n"), 0644)
+		modTime := now.Add(time.Duration(i-len(old)) * time.Hour)
+		os.Chtimes(path, modTime, modTime)
+	}
+	segment := filepath.Join(LogsDir, "logs_test-20261018-120000.log")
+	content := strings.Repeat("строка лога\n", 1000)
+	os.WriteFile(segment, []byte(content), 0644)
+
+	compressAndCleanup("logs_test.log", segment, LogRotation{MaxFiles: 2, Compress: true})
+
+	if fileExists(segment) {
+		t.Error("исходный сегмент не удалён после сжатия")
+	}
+	file, err := os.Open(segment + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(data, []byte(content)) {
+		t.Errorf("распакованный сегмент не совпадает с исходным: %v", err)
+	}
+
+	// Остаются два самых новых сегмента
+	var names []string
+	for _, info := range LogSegments("logs_test.log") {
+		names = append(names, info.Name())
+	}
+	want := []string{"logs_test-20261017-120000.log", "logs_test-20261018-120000.log.gz"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("получили %v, ожидали %v", names, want)
+	}
+}
+
+func TestSegmentPathUnique(t *testing.T) {
+	useTempLogs(t, DefaultLogRotation)
+	os.MkdirAll(LogsDir, 0755)
+
+	stamp := time.Date(2026, 10, 18, 15, 4, 5, 0, time.Local)
+	first := segmentPath("logs_http.log", stamp)
+	if filepath.Base(first) != "logs_http-20261018-150405.log" {
+		t.Fatalf("получили %s", first)
+	}
+
+	// Сжатый сегмент с тем же временем тоже занимает имя
+	os.WriteFile(first+".gz", nil, 0644)
+	if second := segmentPath("logs_http.log", stamp); filepath.Base(second) != "logs_http-20261018-150405.1.log" {
+		t.Errorf("получили %s", second)
+	}
+}
@@ -0,0 +1,259 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Уровни логирования
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (level LogLevel) String() string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "info"
+}
+
+// ParseLogLevel разбирает имя уровня: debug, info, warn, error
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("неизвестный уровень логирования '%s'", name)
+}
+
+// Метки уровней в тексте (как в старом формате логов)
+var levelTags = map[LogLevel]string{
+	LevelDebug: "[-DEBUG-]",
+	LevelInfo:  "[-INFOS-]",
+	LevelWarn:  "[WARNING]",
+	LevelError: "[-ERROR-]",
+}
+
+var levelColors = map[LogLevel]string{
+	LevelDebug: Серый,
+	LevelInfo:  Голубой,
+	LevelWarn:  Жёлтый,
+	LevelError: Красный,
+}
+
+// Настройки логирования из Soft_Settings
+var (
+	logLevelMu      sync.RWMutex
+	defaultLogLevel = LevelInfo
+	subsystemLevels = map[string]LogLevel{}
+	logFormat       = "text"
+)
+
+// SetLogLevels задаёт уровень по умолчанию и уровни отдельных подсистем
+func SetLogLevels(defaultLevel LogLevel, levels map[string]LogLevel) {
+	normalized := make(map[string]LogLevel, len(levels))
+	for subsystem, level := range levels {
+		normalized[strings.ToLower(subsystem)] = level
+	}
+
+	logLevelMu.Lock()
+	defaultLogLevel = defaultLevel
+	subsystemLevels = normalized
+	logLevelMu.Unlock()
+}
+
+// SetLogFormat задаёт формат файлов логов: text или json (консоль всегда текстовая)
+func SetLogFormat(format string) {
+	logLevelMu.Lock()
+	logFormat = format
+	logLevelMu.Unlock()
+}
+
+func subsystemLevel(subsystem string) LogLevel {
+	logLevelMu.RLock()
+	defer logLevelMu.RUnlock()
+	if level, ok := subsystemLevels[subsystem]; ok {
+		return level
+	}
+	return defaultLogLevel
+}
+
+func currentLogFormat() string {
+	logLevelMu.RLock()
+	defer logLevelMu.RUnlock()
+	return logFormat
+}
+
+// Logger пишет записи одной подсистемы в её файл лога
+// Поля передаются парами ключ/значение: log.Info("Сервер запущен", "addr", addr)
+type Logger struct {
+	subsystem string
+	file      string
+	console   bool
+	fields    []interface{}
+}
+
+// NewLogger создаёт логгер подсистемы. Имя подсистемы - ключ в Soft_Settings.log_levels
+func NewLogger(subsystem string, file string) *Logger {
+	return &Logger{subsystem: strings.ToLower(subsystem), file: file}
+}
+
+// Console возвращает логгер, который дублирует записи в консоль
+func (l *Logger) Console() *Logger {
+	clone := *l
+	clone.console = true
+	return &clone
+}
+
+// With возвращает логгер с полями, добавляемыми к каждой записи
+func (l *Logger) With(fields ...interface{}) *Logger {
+	clone := *l
+	clone.fields = append(append([]interface{}{}, l.fields...), fields...)
+	return &clone
+}
+
+// Enabled сообщает, будет ли записан уровень (чтобы не собирать дорогие поля зря)
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level >= subsystemLevel(l.subsystem)
+}
+
+func (l *Logger) Debug(message string, fields ...interface{}) {
+	l.log(LevelDebug, message, fields)
+}
+
+func (l *Logger) Info(message string, fields ...interface{}) {
+	l.log(LevelInfo, message, fields)
+}
+
+func (l *Logger) Warn(message string, fields ...interface{}) {
+	l.log(LevelWarn, message, fields)
+}
+
+func (l *Logger) Error(message string, fields ...interface{}) {
+	l.log(LevelError, message, fields)
+}
+
+func (l *Logger) log(level LogLevel, message string, fields []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	now := time.Now()
+	pairs := logPairs(append(append([]interface{}{}, l.fields...), fields...))
+
+	if l.console {
+		// Очищаем текущую строку (стираем промпт >) и выводим лог с новой строки
+		fmt.Print("\r\033[K")
+		fmt.Println(consoleLine(now, level, l.subsystem, message, pairs))
+	}
+
+	if currentLogFormat() == "json" {
+		appendLogFile(l.file, jsonLine(now, level, l.subsystem, message, pairs)+"\n")
+		return
+	}
+	appendLogFile(l.file, textLine(now, level, l.subsystem, message, pairs)+"\n")
+}
+
+// Пара ключ/значение записи
+type logPair struct {
+	key   string
+	value interface{}
+}
+
+// logPairs разбирает поля; непарное значение попадает под ключ "extra"
+func logPairs(fields []interface{}) []logPair {
+	pairs := make([]logPair, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 >= len(fields) {
+			pairs = append(pairs, logPair{"extra", fields[i]})
+			break
+		}
+		pairs = append(pairs, logPair{fmt.Sprint(fields[i]), fields[i+1]})
+	}
+	return pairs
+}
+
+// textLine: 2006-01-02 15:04:05 [-INFOS-] [HTTP] Сообщение key=value
+func textLine(now time.Time, level LogLevel, subsystem string, message string, pairs []logPair) string {
+	var line strings.Builder
+	line.WriteString(now.Format("2006-01-02 15:04:05") + " " + levelTags[level] + " [" + strings.ToUpper(subsystem) + "] " + message)
+	for _, pair := range pairs {
+		line.WriteString(" " + pair.key + "=" + formatLogValue(pair.value))
+	}
+	return line.String()
+}
+
+// consoleLine - textLine с цветами: уровень и сообщение по уровню, подсистема жёлтым, поля серым
+func consoleLine(now time.Time, level LogLevel, subsystem string, message string, pairs []logPair) string {
+	color := Зелёный
+	if level == LevelError {
+		color = Красный
+	}
+
+	var line strings.Builder
+	line.WriteString(Color(now.Format("2006-01-02 15:04:05")+" ", color))
+	line.WriteString(Color(levelTags[level], levelColors[level]))
+	line.WriteString(Color(" ["+strings.ToUpper(subsystem)+"] ", Жёлтый))
+	line.WriteString(Color(message, color))
+	for _, pair := range pairs {
+		line.WriteString(Color(" "+pair.key+"=", Серый) + formatLogValue(pair.value))
+	}
+	return line.String()
+}
+
+// jsonLine: {"time":...,"level":...,"subsystem":...,"msg":...,поля}
+func jsonLine(now time.Time, level LogLevel, subsystem string, message string, pairs []logPair) string {
+	var line strings.Builder
+	line.WriteString(`{"time":` + strconv.Quote(now.Format(time.RFC3339Nano)))
+	line.WriteString(`,"level":` + strconv.Quote(level.String()))
+	line.WriteString(`,"subsystem":` + strconv.Quote(subsystem))
+	line.WriteString(`,"msg":` + jsonValue(message))
+	for _, pair := range pairs {
+		line.WriteString("," + jsonValue(pair.key) + ":" + jsonValue(pair.value))
+	}
+	line.WriteString("}")
+	return line.String()
+}
+
+func jsonValue(value interface{}) string {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	if stringer, ok := value.(fmt.Stringer); ok {
+		value = stringer.String()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// formatLogValue выводит значение как есть, а строки с пробелами и кавычками - в кавычках
+func formatLogValue(value interface{}) string {
+	text := fmt.Sprint(value)
+	if text == "" || strings.ContainsAny(text, " \t\r\n\"=") {
+		return strconv.Quote(text)
+	}
+	return text
+}
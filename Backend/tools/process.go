@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"os/exec"
+	"time"
+)
+
+// ManagedProcess - дочерний процесс vServer (mysqld, php-cgi)
+// Останавливается только он сам, а не все процессы с таким же именем в системе
+type ManagedProcess struct {
+	Cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// WatchProcess следит за уже запущенным процессом: Wait вызывается здесь, повторно его вызывать нельзя
+func WatchProcess(cmd *exec.Cmd) *ManagedProcess {
+	process := &ManagedProcess{Cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(process.exited)
+	}()
+	return process
+}
+
+// Exited закрывается, когда процесс завершился
+func (p *ManagedProcess) Exited() <-chan struct{} {
+	return p.exited
+}
+
+// Stop просит процесс завершиться и ждёт его не дольше timeout, потом убивает
+// Возвращает true, если пришлось завершить процесс принудительно
+func (p *ManagedProcess) Stop(timeout time.Duration) bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+	}
+
+	terminateProcess(p.Cmd.Process)
+
+	select {
+	case <-p.exited:
+		return false
+	case <-time.After(timeout):
+	}
+
+	p.Cmd.Process.Kill()
+	<-p.exited
+	return true
+}
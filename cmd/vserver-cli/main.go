@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"vServer/Backend/config"
+	"vServer/Backend/daemon"
+	"vServer/Backend/tools"
+)
+
+const usage = `vServer - headless режим без GUI
+
+Использование:
+  vserver-cli [-dir путь] <команда> [аргументы]
+
+Команды:
+  start    запустить сервер в текущем процессе (SIGHUP - перезагрузка, SIGTERM - остановка)
+  stop     остановить запущенный сервер
+  status   показать состояние сервера
+  reload   перечитать config.json в запущенном сервере
+  migrate  обновить config.json до текущей версии схемы (с -dry-run только показать изменения)
+  history [файл]        список снимков config.json и vAccess.conf
+  diff <id> [id2]       сравнить снимок с другим снимком или с текущим файлом
+  restore <id>          восстановить файл из снимка
+
+Флаги:
+`
+
+func main() {
+	dir := flag.String("dir", "", "рабочая папка vServer (содержит каталог WebServer/)")
+	timeout := flag.Duration("timeout", 30*time.Second, "время ожидания остановки для команды stop")
+	dryRun := flag.Bool("dry-run", false, "для команды migrate: показать изменения без записи файла")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, "Ошибка: "+err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Дописываем буферизованные логи перед выходом
+	defer tools.FlushLogs()
+
+	switch flag.Arg(0) {
+	case "start":
+		exitOnError(daemon.Run())
+
+	case "stop":
+		exitOnError(daemon.SignalStop(*timeout))
+		fmt.Println("vServer остановлен")
+
+	case "status":
+		if pid, running := daemon.RunningPID(); running {
+			fmt.Printf("vServer запущен (PID %d)\n", pid)
+			return
+		}
+		fmt.Println("vServer остановлен")
+		os.Exit(3)
+
+	case "reload":
+		exitOnError(daemon.SignalReload())
+		fmt.Println("Сигнал перезагрузки отправлен")
+
+	case "migrate":
+		plan, err := config.MigrateFile(*dryRun)
+		exitOnError(err)
+		printMigrationPlan(plan, *dryRun)
+
+	case "history":
+		entries, err := config.History(flag.Arg(1))
+		exitOnError(err)
+		if len(entries) == 0 {
+			fmt.Println("Снимков нет")
+			return
+		}
+		for _, entry := range entries {
+			fmt.Printf("%-60s %s  %d байт\n", entry.ID, entry.Time.Format("2006-01-02 15:04:05"), entry.Size)
+		}
+
+	case "diff":
+		requireArgs(2)
+		diff, err := config.HistoryDiff(flag.Arg(1), flag.Arg(2))
+		exitOnError(err)
+		if diff == "" {
+			fmt.Println("Различий нет")
+			return
+		}
+		fmt.Print(diff)
+
+	case "restore":
+		requireArgs(2)
+		restored, err := config.RestoreSnapshot(flag.Arg(1))
+		exitOnError(err)
+		// Запущенный сервер сам заметит изменение config.json
+		fmt.Println("Восстановлен " + restored)
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка: "+err.Error())
+		tools.FlushLogs()
+		os.Exit(1)
+	}
+}
+
+// requireArgs завершает работу, если у команды не хватает аргументов
+func requireArgs(count int) {
+	if flag.NArg() < count {
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printMigrationPlan(plan config.MigrationPlan, dryRun bool) {
+	if !plan.NeedsUpgrade() {
+		fmt.Printf("config.json уже актуален (версия %d)\n", plan.FromVersion)
+		return
+	}
+
+	fmt.Printf("Миграция config.json: v%d → v%d\n", plan.FromVersion, plan.ToVersion)
+	for _, step := range plan.Steps {
+		fmt.Println("  шаг " + step)
+	}
+	for _, change := range plan.Changes {
+		fmt.Println("  + " + change)
+	}
+
+	if dryRun {
+		fmt.Println("Режим -dry-run: файл не изменён")
+		return
+	}
+	fmt.Println("config.json обновлён, резервная копия в " + config.BackupDir)
+}